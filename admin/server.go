@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/pkg/errors"
+)
+
+// admin REST API for managing datasources at runtime
+//
+//	GET    /datasources                 list datasources with status and counters
+//	POST   /datasources                 add datasource (JSON body in datasource config format)
+//	DELETE /datasources/{name}          stop and remove datasource
+//	POST   /datasources/{name}/pause    pause datasource
+//	POST   /datasources/{name}/resume   resume paused datasource
+//	POST   /datasources/{name}/trigger  run stopped datasource once
+//
+// every request require "Authorization: Bearer <token>" header
+type Server struct {
+	manager  *worker.Manager
+	token    string
+	validate ValidateFunc
+	logger   utils.Logger
+}
+
+// validate added datasource by the checks of datasources in config file, return resolved datasource
+// problems are reported by config.ValidationError
+type ValidateFunc func(datasource config.DataSourceConfig) (config.DataSourceConfig, error)
+
+func NewServer(manager *worker.Manager, token string, validate ValidateFunc, logger utils.Logger) *Server {
+	return &Server{
+		manager:  manager,
+		token:    token,
+		validate: validate,
+		logger:   logger,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	segments := strings.Split(path, "/")
+	if segments[0] != "datasources" || len(segments) > 3 {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch {
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.manager.List())
+	case len(segments) == 1 && r.Method == http.MethodPost:
+		s.add(w, r)
+	case len(segments) == 2 && r.Method == http.MethodDelete:
+		s.handle(w, "remove", segments[1], s.manager.Remove)
+	case len(segments) == 3 && r.Method == http.MethodPost && segments[2] == "pause":
+		s.handle(w, "pause", segments[1], s.manager.Pause)
+	case len(segments) == 3 && r.Method == http.MethodPost && segments[2] == "resume":
+		s.handle(w, "resume", segments[1], s.manager.Resume)
+	case len(segments) == 3 && r.Method == http.MethodPost && segments[2] == "trigger":
+		s.handle(w, "trigger", segments[1], s.manager.Trigger)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) add(w http.ResponseWriter, r *http.Request) {
+	var datasource config.DataSourceConfig
+	if err := json.NewDecoder(r.Body).Decode(&datasource); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid datasource"))
+		return
+	}
+	datasource, err := s.validate(datasource)
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid datasource", "problems": validationErr.Problems})
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.handle(w, "add", datasource.Name, func(string) error {
+		return s.manager.Add(datasource)
+	})
+}
+
+// run datasource operation and map manager error to HTTP status
+func (s *Server) handle(w http.ResponseWriter, operation string, name string, fn func(name string) error) {
+	err := fn(name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, worker.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, worker.ErrAlreadyExists), errors.Is(err, worker.ErrInvalidState):
+			status = http.StatusConflict
+		case errors.Is(err, worker.ErrNotImplemented):
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	s.logger.WithFields(utils.Fields{utils.FieldDatasource: name}).Infof("admin API %s datasource", operation)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// constant time comparison of bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
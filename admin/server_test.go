@@ -0,0 +1,195 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/admin"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/sirupsen/logrus"
+)
+
+func TestServer(t *testing.T) {
+	dep := newServerDependencies()
+
+	type testcase struct {
+		testcase       string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}
+
+	testcases := []testcase{
+		{
+			testcase:       "Missing token",
+			method:         http.MethodGet,
+			path:           "/datasources",
+			token:          "",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			testcase:       "Wrong token",
+			method:         http.MethodGet,
+			path:           "/datasources",
+			token:          "wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			testcase:       "Add datasource",
+			method:         http.MethodPost,
+			path:           "/datasources",
+			token:          "secret",
+			body:           `{"name": "source", "type": "mock", "transformer": "mock", "source": "mock://"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			testcase:       "Add duplicated datasource",
+			method:         http.MethodPost,
+			path:           "/datasources",
+			token:          "secret",
+			body:           `{"name": "source", "type": "mock", "transformer": "mock", "source": "mock://"}`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			testcase:       "Add not implemented datasource",
+			method:         http.MethodPost,
+			path:           "/datasources",
+			token:          "secret",
+			body:           `{"name": "other", "type": "ftp", "transformer": "mock", "source": "ftp://"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testcase:       "Add invalid datasource",
+			method:         http.MethodPost,
+			path:           "/datasources",
+			token:          "secret",
+			body:           `{"name": "other", "type": "mock", "transformer": "mock", "entity": "orders"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"problems":["Datasource (other).Entity \"orders\" not found","Datasource (other).Source is missing"]`,
+		},
+		{
+			testcase:       "Pause datasource",
+			method:         http.MethodPost,
+			path:           "/datasources/source/pause",
+			token:          "secret",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			testcase:       "Trigger datasource",
+			method:         http.MethodPost,
+			path:           "/datasources/source/trigger",
+			token:          "secret",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			testcase:       "Pause unknown datasource",
+			method:         http.MethodPost,
+			path:           "/datasources/unknown/pause",
+			token:          "secret",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			testcase:       "Remove datasource",
+			method:         http.MethodDelete,
+			path:           "/datasources/source",
+			token:          "secret",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			testcase:       "Unknown path",
+			method:         http.MethodGet,
+			path:           "/users",
+			token:          "secret",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+
+			dep.server.ServeHTTP(rec, req)
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %v, but got %v %s", tc.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.expectedBody) {
+				t.Errorf("expected %v, but got %v", tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerList(t *testing.T) {
+	dep := newServerDependencies()
+	dep.manager.Add(config.DataSourceConfig{Name: "source", Type: "mock", Transformer: "mock", Source: "mock://", Once: true})
+	dep.manager.Wait()
+
+	req := httptest.NewRequest(http.MethodGet, "/datasources", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	dep.server.ServeHTTP(rec, req)
+
+	var statuses []worker.Status
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "source" || statuses[0].Status != worker.StatusCompleted {
+		t.Errorf("expected completed datasource, but got %+v", statuses)
+	}
+}
+
+// extractor which run until context cancelled (or stop immediately if run once)
+type extractorMock struct{}
+
+func (e *extractorMock) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	if !datasource.Once {
+		<-ctx.Done()
+	}
+	return nil
+}
+
+type serverDependencies struct {
+	manager *worker.Manager
+	server  *admin.Server
+}
+
+func newServerDependencies() serverDependencies {
+	logger := utils.NewLogrusLogger(logrus.StandardLogger())
+	extractors := map[string]extraction.DataSourceExtration{
+		"mock": &extractorMock{},
+	}
+	transformers := map[string]transformation.TransformFunc{
+		"mock": func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+			return []transformation.Record{{}}, nil
+		},
+	}
+	schemas, _ := transformation.NewSchemas([]config.EntityConfig{config.UserEntity()})
+	manager := worker.NewManager(context.Background(), logger, extractors, transformers, nil, schemas, make(chan transformation.Record))
+	c := &config.Config{Entities: []config.EntityConfig{config.UserEntity()}}
+	validate := func(datasource config.DataSourceConfig) (config.DataSourceConfig, error) {
+		return c.ValidateDatasource(datasource, nil, []string{"mock"}, []string{"mock"})
+	}
+
+	return serverDependencies{
+		manager: manager,
+		server:  admin.NewServer(manager, "secret", validate, logger),
+	}
+}
@@ -0,0 +1,47 @@
+package checkpoint_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	store, err := checkpoint.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	position, err := store.Load(context.Background(), "source")
+	if err != nil || position != "" {
+		t.Errorf("expected empty position, but got %v %v", position, err)
+	}
+
+	if err := store.Save(context.Background(), "source", "42"); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	// reopen store to simulate restart
+	reopened, err := checkpoint.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	position, err = reopened.Load(context.Background(), "source")
+	if err != nil || position != "42" {
+		t.Errorf("expected position 42, but got %v %v", position, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := checkpoint.NewMemoryStore()
+
+	store.Save(context.Background(), "source", "cursor")
+	position, err := store.Load(context.Background(), "source")
+	if err != nil || position != "cursor" {
+		t.Errorf("expected position cursor, but got %v %v", position, err)
+	}
+}
@@ -0,0 +1,83 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// local file checkpoint store
+// keep position of all datasource in a single JSON file
+type FileStore struct {
+	mu        sync.Mutex
+	path      string
+	positions map[string]string
+}
+
+// create file checkpoint store and load existing positions from path
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{
+		path:      path,
+		positions: make(map[string]string),
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return nil, errors.Wrap(err, "unable to read checkpoint file")
+	}
+
+	if len(content) != 0 {
+		if err := json.Unmarshal(content, &f.positions); err != nil {
+			return nil, errors.Wrap(err, "unable to parse checkpoint file")
+		}
+	}
+
+	return f, nil
+}
+
+func (f *FileStore) Load(ctx context.Context, datasource string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.positions[datasource], nil
+}
+
+func (f *FileStore) Save(ctx context.Context, datasource string, position string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.positions[datasource] = position
+
+	content, err := json.Marshal(f.positions)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode checkpoint")
+	}
+
+	// write to temp file and rename to make sure checkpoint file won't be corrupted if crash in the middle of write
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temp checkpoint file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "unable to write checkpoint file")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "unable to sync checkpoint file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "unable to close checkpoint file")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), f.path), "unable to replace checkpoint file")
+}
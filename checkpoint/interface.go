@@ -0,0 +1,12 @@
+package checkpoint
+
+import "context"
+
+// store for keeping the extraction position (offset, cursor, file position etc) of each datasource
+// position is only saved after the corresponding records are committed by the loader
+type Store interface {
+	// load last committed position of datasource, empty string if no position saved before
+	Load(ctx context.Context, datasource string) (string, error)
+	// save committed position of datasource
+	Save(ctx context.Context, datasource string, position string) error
+}
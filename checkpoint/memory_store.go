@@ -0,0 +1,33 @@
+package checkpoint
+
+import (
+	"context"
+	"sync"
+)
+
+// in memory checkpoint store (position will be lost after restart)
+type MemoryStore struct {
+	mu        sync.RWMutex
+	positions map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		positions: make(map[string]string),
+	}
+}
+
+func (m *MemoryStore) Load(ctx context.Context, datasource string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.positions[datasource], nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, datasource string, position string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.positions[datasource] = position
+	return nil
+}
@@ -0,0 +1,48 @@
+package checkpoint
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// PostgreSQL checkpoint store
+// keep position of each datasource in checkpoints table
+type PostgreSQLStore struct {
+	db *sqlx.DB
+}
+
+func NewPostgreSQLStore(db *sqlx.DB) *PostgreSQLStore {
+	return &PostgreSQLStore{
+		db: db,
+	}
+}
+
+func (p *PostgreSQLStore) Load(ctx context.Context, datasource string) (string, error) {
+	var position string
+	err := p.db.GetContext(ctx, &position, `SELECT position FROM checkpoints WHERE datasource = $1`, datasource)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "unable to load checkpoint")
+	}
+
+	return position, nil
+}
+
+func (p *PostgreSQLStore) Save(ctx context.Context, datasource string, position string) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO
+			checkpoints (datasource, position, updated_at)
+		VALUES
+			($1, $2, now())
+		ON CONFLICT (datasource) DO UPDATE SET
+			position = EXCLUDED.position,
+			updated_at = EXCLUDED.updated_at
+	`, datasource, position)
+
+	return errors.Wrap(err, "unable to save checkpoint")
+}
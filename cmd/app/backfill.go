@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"slices"
+
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/sirupsen/logrus"
+)
+
+// run each datasource to completion (continue from last checkpoint), load data to datastore and exit
+// records are passed to loader directly without disk queue
+func backfillCommand(ctx context.Context, logger *logrus.Logger, args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	fs.Parse(args)
+
+	config := flags.mustLoadConfig(fs, logger)
+	appLogger := setupLogger(logger, config)
+	for _, datasource := range config.Datasource {
+		if slices.Contains(unboundedDatasourceTypes, datasource.Type) {
+			logger.Fatalf("datasource %s type %s runs until application stops and can't be backfilled", datasource.Name, datasource.Type)
+		}
+	}
+	schemas, err := newSchemas(config)
+	if err != nil {
+		logger.Fatal("Not able to create entity schemas ", err)
+	}
+
+	db, err := newDatabase(config)
+	if err != nil {
+		logger.Fatal("Not able to create new database connection ", err)
+	}
+	// entity tables must match entity schemas before loading
+	if err := evolveSchemas(ctx, config, db, appLogger); err != nil {
+		logger.Fatal("Not able to evolve entity schemas ", err)
+	}
+	checkpointStore, err := newCheckpointStore(config, db)
+	if err != nil {
+		logger.Fatal("Not able to create checkpoint store ", err)
+	}
+	sinks, closeSinks, err := newRepository(config, db, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create repository ", err)
+	}
+	defer closeSinks()
+	deduplicator, err := newDeduplicator(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create deduplicator ", err)
+	}
+	repo := loading.NewCheckpointRepository(loading.NewDeduplicationRepository(sinks, deduplicator), checkpointStore, appLogger)
+
+	validator, quarantine, err := newValidator(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create validator ", err)
+	}
+	defer quarantine.Close()
+
+	// transformers (including scripts) and transformation steps applied after parsing records of each datasource
+	transformers, pipeline, err := newTransformation(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create transformers ", err)
+	}
+
+	// loader return on error which is not recovered by retrying, every stage is stopped then as records are no longer loaded
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	transformedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	validatedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	structedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	go validator.Run(ctx, transformedDataChan, validatedDataChan)
+	go deduplicator.Run(ctx, validatedDataChan, structedDataChan)
+	loaderErr := make(chan error, 1)
+	go func() {
+		err := loading.SaveData(ctx, repo, structedDataChan, config.Application.BulkInsert, config.Application.BulkInsertSize, config.Application.BulkInsertInterval, appLogger)
+		if err != nil {
+			cancel()
+		}
+		loaderErr <- err
+	}()
+
+	manager := worker.NewManager(ctx, appLogger, newExtractors(appLogger, checkpointStore), transformers, pipeline, schemas, transformedDataChan)
+	for _, datasource := range config.Datasource {
+		datasource.Once = true
+		if err := manager.Add(datasource); err != nil {
+			logger.Fatalf("datasource %s type : %s, transformer: %s not started %v", datasource.Name, datasource.Type, datasource.Transformer, err)
+		}
+	}
+
+	// close data channel after all datasources completed, so validator and deduplicator close loader channel and loader flush buffered records and return
+	manager.Wait()
+	close(transformedDataChan)
+	if err := <-loaderErr; err != nil {
+		logger.Fatal("Not able to load data ", err)
+	}
+	if err := deduplicator.Close(); err != nil {
+		logger.Error("Not able to save bloom filter ", err)
+	}
+	validator.LogSummary()
+	deduplicator.LogSummary()
+
+	failed := false
+	for _, status := range manager.List() {
+		logger.Infof("datasource %s %s with %d records extracted, %d records failed and %d records dropped", status.Name, status.Status, status.Extracted, status.Failed, status.Dropped)
+		if status.Status == worker.StatusFailed {
+			failed = true
+		}
+	}
+	if failed {
+		logger.Fatal("Backfill failed")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/validation"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/sirupsen/logrus"
+)
+
+// extract and transform records and print them to stdout as JSON lines without loading
+// checkpoint is kept in memory so the real extraction position is not changed
+func dryRunCommand(ctx context.Context, logger *logrus.Logger, args []string) {
+	fs := flag.NewFlagSet("dry-run", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	records := fs.Int("records", 10, "number of records to extract before exit")
+	fs.Parse(args)
+
+	config := flags.mustLoadConfig(fs, logger)
+	// keep stdout for records only
+	logger.SetOutput(os.Stderr)
+	appLogger := setupLogger(logger, config)
+	schemas, err := newSchemas(config)
+	if err != nil {
+		logger.Fatal("Not able to create entity schemas ", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// quarantine is discarded as records are not loaded
+	rules, err := validation.CompileRules(config.Validation.Rules, schemas)
+	if err != nil {
+		logger.Fatal("Not able to create validator ", err)
+	}
+	validator := validation.NewValidator(rules, discardQuarantine{}, appLogger)
+	defer validator.LogSummary()
+
+	// duplicates are dropped but keys are never committed to bloom filter as records are not loaded
+	deduplicator, err := newDeduplicator(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create deduplicator ", err)
+	}
+	defer deduplicator.LogSummary()
+
+	// transformers (including scripts) and transformation steps applied after parsing records of each datasource
+	transformers, pipeline, err := newTransformation(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create transformers ", err)
+	}
+
+	transformedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	validatedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	structedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	go validator.Run(ctx, transformedDataChan, validatedDataChan)
+	go deduplicator.Run(ctx, validatedDataChan, structedDataChan)
+	manager := worker.NewManager(ctx, appLogger, newExtractors(appLogger, checkpoint.NewMemoryStore()), transformers, pipeline, schemas, transformedDataChan)
+	for _, datasource := range config.Datasource {
+		if err := manager.Add(datasource); err != nil {
+			logger.Fatalf("datasource %s type : %s, transformer: %s not started %v", datasource.Name, datasource.Type, datasource.Transformer, err)
+		}
+	}
+
+	// validator and deduplicator close record channel after all datasources stopped before enough records extracted
+	go func() {
+		manager.Wait()
+		close(transformedDataChan)
+	}()
+
+	encoder := json.NewEncoder(os.Stdout)
+	for printed := 0; printed < *records; printed++ {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-structedDataChan:
+			if !ok {
+				return
+			}
+			encoder.Encode(data)
+		}
+	}
+
+	cancel()
+	manager.Wait()
+}
+
+// quarantine which drop records
+type discardQuarantine struct{}
+
+func (discardQuarantine) Write(record transformation.Record) error {
+	return nil
+}
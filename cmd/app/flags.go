@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	configpkg "github.com/awcjack/ETL-sample/config"
+	"github.com/sirupsen/logrus"
+)
+
+// command line flags overriding config values, keyed by flag name
+var configFlagKeys = map[string]string{
+	"log-level":            "Application.LogLevel",
+	"log-format":           "Application.LogFormat",
+	"pipeline-size":        "Application.ProcessPipelineSize",
+	"bulk-insert":          "Application.BulkInsert",
+	"bulk-insert-size":     "Application.BulkInsertSize",
+	"bulk-insert-interval": "Application.BulkInsertInterval",
+	"connection-string":    "Database.ConnectionString",
+	"checkpoint":           "Checkpoint.Type",
+	"checkpoint-path":      "Checkpoint.Path",
+}
+
+// flags shared by all subcommands
+type commonFlags struct {
+	// config file path, config.json / config.yaml / config.toml in current path if empty
+	configPath string
+	// comma separated datasource names, all datasources in config if empty
+	datasources string
+}
+
+// register config override flags and datasource filter flag to flag set
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	for name, key := range configFlagKeys {
+		fs.String(name, "", fmt.Sprintf("override %s config", key))
+	}
+
+	f := &commonFlags{}
+	fs.StringVar(&f.configPath, "config", "", "config file path in JSON, YAML or TOML format (default config.json / config.yaml / config.toml in current path)")
+	fs.StringVar(&f.datasources, "datasource", "", "comma separated datasource names to run (default all datasources in config)")
+	return f
+}
+
+// load and validate config with values of flags explicitly set in command line
+func (f *commonFlags) loadConfig(fs *flag.FlagSet) (*configpkg.Config, error) {
+	fs.Visit(func(fl *flag.Flag) {
+		if key, ok := configFlagKeys[fl.Name]; ok {
+			configpkg.Set(key, fl.Value.String())
+		}
+	})
+
+	config, err := configpkg.LoadConfig(f.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// report every config problem before startup
+	// config is still returned with validation error for reporting further problems
+	if err := validateConfig(config); err != nil {
+		return config, err
+	}
+
+	if err := selectDatasources(config, f.datasources); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// only keep datasources of comma separated names in config, all datasources are kept if names is empty
+// filter is applied to reloaded config too, so datasources not selected are not started by reload
+func selectDatasources(config *configpkg.Config, names string) error {
+	if names == "" {
+		return nil
+	}
+
+	byName := make(map[string]configpkg.DataSourceConfig, len(config.Datasource))
+	for _, datasource := range config.Datasource {
+		byName[datasource.Name] = datasource
+	}
+	selected := make([]configpkg.DataSourceConfig, 0)
+	for _, name := range strings.Split(names, ",") {
+		datasource, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("datasource %s not found in config", name)
+		}
+		selected = append(selected, datasource)
+	}
+	config.Datasource = selected
+	return nil
+}
+
+// load config or exit with readable report of all config problems
+func (f *commonFlags) mustLoadConfig(fs *flag.FlagSet, logger *logrus.Logger) *configpkg.Config {
+	config, err := f.loadConfig(fs)
+	if err != nil {
+		var validationErr *configpkg.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Fprintln(os.Stderr, validationErr)
+			os.Exit(1)
+		}
+		logger.Fatal("Not able to load config ", err)
+	}
+
+	return config
+}
@@ -2,74 +2,61 @@ package main
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/awcjack/ETL-sample/config"
-	"github.com/awcjack/ETL-sample/extraction"
-	"github.com/awcjack/ETL-sample/loading"
-	"github.com/awcjack/ETL-sample/transformation"
-	"github.com/awcjack/ETL-sample/transformation/http"
-	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 )
 
+const usage = `Usage: app [command] [flags]
+
+Commands:
+  run              run all datasources continuously and load data to datastore (default)
+  validate-config  check datasources, transformers and datastore connection
+  dry-run          extract and transform records and print them without loading
+  backfill         run each datasource to completion, load data to datastore and exit
+  replay           load transformed records from file to datastore
+  encrypt-secret   encrypt secret from stdin for ${enc:...} config value
+
+Run "app [command] -h" for flags of each command.
+`
+
 func main() {
 	// Init logrus as logger
 	logger := logrus.New()
 
-	// Loading config from env
-	config, err := config.LoadConfig()
-	if err != nil {
-		logger.Fatal("Not able to load config ", err)
-	}
-
-	// set logger level from config
-	logLevel, err := logrus.ParseLevel(config.Application.LogLevel)
-	if err != nil {
-		logger.Errorf("Not able to parse log level %v, changing to default level info level", err)
-		logLevel = logrus.InfoLevel
-	}
-	logger.SetLevel(logLevel)
+	// stop extraction and loading on interrupt / terminate signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Create repository implementation (possible to switch to other datastore implementation)
-	var db *sqlx.DB
-	logger.Info("Loading datastore Reopsitory")
-	if config.Database.Type == "postgresql" {
-		// Start PostgreSQL connection
-		db, err = loading.NewPostgreSQLConnection(config.Database)
-		if err != nil {
-			logger.Fatal("Not able to create new postgresql connection", err)
-		}
-	} else {
-		logger.Fatal("Not implemented other datastore repository")
+	// run command is used if no command is given
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
 	}
-	// Create repostiroy based on PostgreSQL db connection
-	repo := loading.NewPostgreSQLRepository(db, logger)
 
-	// create data channel for passing data from transformer to data store
-	structedDataChan := make(chan transformation.TransformedData, config.Application.ProcessPipelineSize)
-	// dedicate go routine for storing processed data to datastore
-	go loading.SaveData(context.Background(), repo, structedDataChan, config.Application.BulkInsert, config.Application.BulkInsertSize, config.Application.BulkInsertInterval)
-
-	// data extraction processor
-	httpExtractionProcessor := extraction.NewHttpExtraction(logger)
-	// waitgroup to make sure the application won't close before all extraction processor fail
-	var wg sync.WaitGroup
-	// for loop to create extract go routine based on config
-	for _, datasource := range config.Datasource {
-		wg.Add(1)
-		if datasource.Type == "http" && datasource.Transformer == "random-data-api" {
-			logger.Debugf("datasource %s is starting", datasource.Name)
-			// create transformer based on type
-			randomDataAPITransformer := http.NewRandomDataAPITransformer(logger)
-			// dedicate go routine for starting extract data from data source which allow getting data from different data source simultaneously
-			go httpExtractionProcessor.Extract(datasource.Source, randomDataAPITransformer.Transform, structedDataChan, &wg)
-		} else {
-			// close wait group if no extract function started or failed
-			wg.Done()
-			logger.Errorf("datasource %s type : %s, transformer: %s not yet implemented\n", datasource.Name, datasource.Type, datasource.Transformer)
-		}
+	switch command {
+	case "run":
+		runCommand(ctx, logger, args)
+	case "validate-config":
+		validateConfigCommand(ctx, logger, args)
+	case "dry-run":
+		dryRunCommand(ctx, logger, args)
+	case "backfill":
+		backfillCommand(ctx, logger, args)
+	case "replay":
+		replayCommand(ctx, logger, args)
+	case "encrypt-secret":
+		encryptSecretCommand(logger, args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", command, usage)
+		os.Exit(2)
 	}
-
-	wg.Wait()
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/sirupsen/logrus"
+)
+
+// apply config file changes to running application without restart
+type reloader struct {
+	mu      sync.Mutex
+	logger  *logrus.Logger
+	manager *worker.Manager
+	loader  *loading.Loader
+	current *config.Config
+	// comma separated datasource names selected by command line flag, all datasources if empty
+	datasources string
+}
+
+func newReloader(logger *logrus.Logger, manager *worker.Manager, loader *loading.Loader, current *config.Config, datasources string) *reloader {
+	return &reloader{
+		logger:      logger,
+		manager:     manager,
+		loader:      loader,
+		current:     current,
+		datasources: datasources,
+	}
+}
+
+// apply new config
+// log level, log format, bulk insert size and interval and datasources are applied immediately
+// other changes require restart
+// only datasources selected by command line flag are applied
+func (r *reloader) apply(c *config.Config, err error) {
+	if err == nil {
+		err = validateConfig(c)
+	}
+	if err == nil {
+		err = selectDatasources(c, r.datasources)
+	}
+	if err != nil {
+		r.logger.Errorf("Not able to reload config, keep using previous config %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logger.Info("Reloading config")
+
+	if c.Application.LogLevel != r.current.Application.LogLevel {
+		logLevel, err := logrus.ParseLevel(c.Application.LogLevel)
+		if err != nil {
+			r.logger.Errorf("Not able to parse log level %v, keep using previous level", err)
+		} else {
+			r.logger.SetLevel(logLevel)
+			r.logger.Infof("Log level changed to %s", logLevel)
+		}
+	}
+
+	if c.Application.LogFormat != r.current.Application.LogFormat {
+		if err := utils.SetLogFormat(r.logger, c.Application.LogFormat); err != nil {
+			r.logger.Errorf("Not able to set log format %v, keep using previous format", err)
+		}
+	}
+
+	if c.Application.BulkInsertSize != r.current.Application.BulkInsertSize || c.Application.BulkInsertInterval != r.current.Application.BulkInsertInterval {
+		r.loader.UpdateBulkInsert(c.Application.BulkInsertSize, c.Application.BulkInsertInterval)
+		r.logger.Infof("Bulk insert size changed to %d and interval changed to %d", c.Application.BulkInsertSize, c.Application.BulkInsertInterval)
+	}
+
+	// only start, stop or restart the affected datasources
+	added, removed, changed := config.DiffDatasources(r.current.Datasource, c.Datasource)
+	for _, datasource := range removed {
+		if err := r.manager.Remove(datasource.Name); err != nil {
+			r.logger.Errorf("Not able to remove datasource %s %v", datasource.Name, err)
+		}
+	}
+	for _, datasource := range changed {
+		if err := r.manager.Remove(datasource.Name); err != nil {
+			r.logger.Errorf("Not able to stop datasource %s %v", datasource.Name, err)
+		}
+	}
+	for _, datasource := range append(added, changed...) {
+		if err := r.manager.Add(datasource); err != nil {
+			r.logger.Errorf("datasource %s type : %s, transformer: %s not started %v", datasource.Name, datasource.Type, datasource.Transformer, err)
+		}
+	}
+
+	if c.Application.ProcessPipelineSize != r.current.Application.ProcessPipelineSize ||
+		c.Application.BulkInsert != r.current.Application.BulkInsert ||
+		c.Database != r.current.Database ||
+		c.Checkpoint != r.current.Checkpoint ||
+		c.Queue != r.current.Queue ||
+		c.Admin != r.current.Admin ||
+		!reflect.DeepEqual(c.Entities, r.current.Entities) ||
+		c.Schema != r.current.Schema ||
+		!reflect.DeepEqual(c.Scripts, r.current.Scripts) ||
+		!reflect.DeepEqual(c.Transformation, r.current.Transformation) ||
+		!reflect.DeepEqual(c.Validation, r.current.Validation) ||
+		c.Archive != r.current.Archive ||
+		c.Kafka != r.current.Kafka ||
+		!reflect.DeepEqual(c.Privacy, r.current.Privacy) ||
+		!reflect.DeepEqual(c.Deduplication, r.current.Deduplication) {
+		r.logger.Warn("Application, database, checkpoint, queue, admin, entity, schema, script, transformation, validation, archive, kafka, privacy and deduplication config changes other than log and bulk insert settings require restart")
+	}
+
+	r.current = c
+}
+
+// validate datasource added by admin API against current config and datasources of manager
+func (r *reloader) validateDatasource(datasource config.DataSourceConfig) (config.DataSourceConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := r.manager.List()
+	running := make([]config.DataSourceConfig, 0, len(statuses))
+	for _, status := range statuses {
+		running = append(running, config.DataSourceConfig{Name: status.Name, Type: status.Type, Source: status.Source})
+	}
+	datasourceTypes, transformers := implementedNames(r.current)
+	return r.current.ValidateDatasource(datasource, running, datasourceTypes, transformers)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	configpkg "github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/sirupsen/logrus"
+)
+
+func TestReloaderApplyDatasourceFilter(t *testing.T) {
+	type testcase struct {
+		testcase string
+		// datasources of reloaded config file
+		reloaded []string
+		// datasources selected by command line flag
+		selected        string
+		expectedRunning []string
+	}
+
+	testcases := []testcase{
+		{
+			testcase:        "Datasource not selected is not started",
+			reloaded:        []string{"orders", "users", "payments"},
+			selected:        "orders",
+			expectedRunning: []string{"orders"},
+		},
+		{
+			testcase:        "Selected datasource removed from config keep previous config",
+			reloaded:        []string{"users"},
+			selected:        "orders",
+			expectedRunning: []string{"orders"},
+		},
+		{
+			testcase:        "Every datasource started without filter",
+			reloaded:        []string{"orders", "users", "payments"},
+			expectedRunning: []string{"orders", "payments", "users"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newReloaderDependencies(t, tc.selected)
+			defer dep.cancel()
+
+			c, err := configpkg.LoadConfig(dep.writeConfig(t, tc.reloaded))
+			dep.reloader.apply(c, err)
+
+			running := make([]string, 0)
+			for _, status := range dep.manager.List() {
+				running = append(running, status.Name)
+			}
+			sort.Strings(running)
+			if !reflect.DeepEqual(running, tc.expectedRunning) {
+				t.Errorf("expected %v, but got %v", tc.expectedRunning, running)
+			}
+		})
+	}
+}
+
+// extractor running until context cancelled
+type extractorMock struct{}
+
+func (e *extractorMock) Extract(ctx context.Context, datasource configpkg.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	defer wg.Done()
+	<-ctx.Done()
+	return nil
+}
+
+type reloaderDependencies struct {
+	dir      string
+	manager  *worker.Manager
+	reloader *reloader
+	cancel   context.CancelFunc
+}
+
+// reloader of running application started with orders and users datasources in config file
+func newReloaderDependencies(t *testing.T, selected string) reloaderDependencies {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	appLogger := utils.NewLogrusLogger(logger)
+	dep := reloaderDependencies{dir: t.TempDir()}
+
+	c, err := configpkg.LoadConfig(dep.writeConfig(t, []string{"orders", "users"}))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if err := selectDatasources(c, selected); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	schemas, err := newSchemas(c)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dep.cancel = cancel
+	extractors := map[string]extraction.DataSourceExtration{"file": &extractorMock{}}
+	transformers := map[string]transformation.TransformFunc{"fields": transformation.FieldsTransformer}
+	dep.manager = worker.NewManager(ctx, appLogger, extractors, transformers, nil, schemas, make(chan transformation.Record))
+	for _, datasource := range c.Datasource {
+		if err := dep.manager.Add(datasource); err != nil {
+			t.Fatalf("not expected error, but got %v", err)
+		}
+	}
+	loader := loading.NewLoader(nil, false, c.Application.BulkInsertSize, c.Application.BulkInsertInterval, appLogger)
+	dep.reloader = newReloader(logger, dep.manager, loader, c, selected)
+	return dep
+}
+
+// write config file of file datasources archived to local file
+func (d reloaderDependencies) writeConfig(t *testing.T, datasources []string) string {
+	config := `{"Database": {"Type": "none"}, "Checkpoint": {"Type": "memory"}, "Archive": {"Enable": true, "Path": "` + filepath.Join(d.dir, "archive.jsonl") + `"}, "Datasource": [`
+	for i, name := range datasources {
+		if i != 0 {
+			config += ", "
+		}
+		config += `{"Name": "` + name + `", "Type": "file", "Transformer": "fields", "Source": "` + filepath.Join(d.dir, name+".jsonl") + `"}`
+	}
+	config += `]}`
+
+	path := filepath.Join(d.dir, "config.json")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	return path
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/sirupsen/logrus"
+)
+
+// load transformed records from file (JSON lines, e.g. dry-run output or dead letter file) to datastore
+// checkpoint is not updated as replayed records are not extracted in order
+func replayCommand(ctx context.Context, logger *logrus.Logger, args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	path := fs.String("file", "", "file of transformed records in JSON lines format (required)")
+	fs.Parse(args)
+
+	if *path == "" {
+		logger.Fatal("Missing replay file")
+	}
+
+	config := flags.mustLoadConfig(fs, logger)
+	appLogger := setupLogger(logger, config)
+	schemas, err := newSchemas(config)
+	if err != nil {
+		logger.Fatal("Not able to create entity schemas ", err)
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		logger.Fatal("Not able to open replay file ", err)
+	}
+	defer file.Close()
+
+	db, err := newDatabase(config)
+	if err != nil {
+		logger.Fatal("Not able to create new database connection ", err)
+	}
+	// entity tables must match entity schemas before loading
+	if err := evolveSchemas(ctx, config, db, appLogger); err != nil {
+		logger.Fatal("Not able to evolve entity schemas ", err)
+	}
+	// records are not deduplicated as replay file is already deduplicated before it was written
+	repo, closeSinks, err := newRepository(config, db, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create repository ", err)
+	}
+	defer closeSinks()
+
+	// loader return on error which is not recovered by retrying, replay is stopped then as records are no longer loaded
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	structedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	loaderErr := make(chan error, 1)
+	go func() {
+		err := loading.SaveData(ctx, repo, structedDataChan, config.Application.BulkInsert, config.Application.BulkInsertSize, config.Application.BulkInsertInterval, appLogger)
+		if err != nil {
+			cancel()
+		}
+		loaderErr <- err
+	}()
+
+	replayed := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// record written before entities were introduced is replayed as users record
+		var data transformation.Record
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			logger.Fatalf("Not able to parse record at line %d %v", line, err)
+		}
+		if err := schemas.Conform(&data); err != nil {
+			logger.Fatalf("Not able to parse record at line %d %v", line, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			// loader stopped on error or signal received
+			if err := <-loaderErr; err != nil {
+				logger.Fatal("Not able to load data ", err)
+			}
+			return
+		case structedDataChan <- data:
+			replayed++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Fatal("Not able to read replay file ", err)
+	}
+
+	close(structedDataChan)
+	if err := <-loaderErr; err != nil {
+		logger.Fatal("Not able to load data ", err)
+	}
+	logger.Infof("Replayed %d records", replayed)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	nethttp "net/http"
+	"os"
+
+	"github.com/awcjack/ETL-sample/admin"
+	configpkg "github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/queue"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/sirupsen/logrus"
+)
+
+// run all datasources continuously and load data to datastore until signal received
+func runCommand(ctx context.Context, logger *logrus.Logger, args []string) {
+	// exit with non zero code after deferred cleanup if loading stopped on error
+	failed := false
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	fs.Parse(args)
+
+	// Loading config from config file and command line flags
+	config := flags.mustLoadConfig(fs, logger)
+	appLogger := setupLogger(logger, config)
+	schemas, err := newSchemas(config)
+	if err != nil {
+		logger.Fatal("Not able to create entity schemas ", err)
+	}
+
+	// Create repository implementation (possible to switch to other datastore implementation)
+	logger.Info("Loading datastore Reopsitory")
+	db, err := newDatabase(config)
+	if err != nil {
+		logger.Fatal("Not able to create new database connection ", err)
+	}
+	// entity tables must match entity schemas before loading
+	if err := evolveSchemas(ctx, config, db, appLogger); err != nil {
+		logger.Fatal("Not able to evolve entity schemas ", err)
+	}
+	checkpointStore, err := newCheckpointStore(config, db)
+	if err != nil {
+		logger.Fatal("Not able to create checkpoint store ", err)
+	}
+
+	// Create repostiroy based on PostgreSQL db connection (and archive and kafka sinks)
+	// checkpoint is only saved after records are committed to repository
+	sinks, closeSinks, err := newRepository(config, db, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create repository ", err)
+	}
+	defer closeSinks()
+
+	// drop duplicated records before loading, keys are added to bloom filter after records are committed
+	deduplicator, err := newDeduplicator(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create deduplicator ", err)
+	}
+	defer func() {
+		if err := deduplicator.Close(); err != nil {
+			logger.Errorf("Not able to save bloom filter %v", err)
+		}
+	}()
+	defer deduplicator.LogSummary()
+	var repo loading.Repository = loading.NewCheckpointRepository(loading.NewDeduplicationRepository(sinks, deduplicator), checkpointStore, appLogger)
+
+	// validate transformed records before passing them to data store
+	validator, quarantine, err := newValidator(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create validator ", err)
+	}
+	defer quarantine.Close()
+	defer validator.LogSummary()
+
+	// create data channel for passing data from transformer to data store
+	// transformers (including scripts) and transformation steps applied after parsing records of each datasource
+	transformers, pipeline, err := newTransformation(config, schemas, appLogger)
+	if err != nil {
+		logger.Fatal("Not able to create transformers ", err)
+	}
+
+	// loader retry failed writes during datastore downtime (extractors keep ingesting to disk queue meanwhile)
+	// loader and disk queue only return error which is not recovered by retrying, every stage is stopped then as records are no longer loaded
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stageErr := make(chan error, 3)
+	runStage := func(name string, run func() error) {
+		go func() {
+			if err := run(); err != nil {
+				stageErr <- fmt.Errorf("%s: %w", name, err)
+				cancel()
+			}
+		}()
+	}
+
+	transformedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	validatedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	structedDataChan := make(chan transformation.Record, config.Application.ProcessPipelineSize)
+	go validator.Run(ctx, transformedDataChan, validatedDataChan)
+	go deduplicator.Run(ctx, validatedDataChan, structedDataChan)
+	loadingDataChan := structedDataChan
+	switch config.Queue.Type {
+	case "memory":
+	case "disk":
+		// durable queue between extraction and loading
+		// extractors keep ingesting to disk during datastore downtime and loader replay unacknowledged records after restart
+		diskQueue, err := queue.NewDiskQueue(config.Queue.Path, int64(config.Queue.SegmentSize), schemas, appLogger)
+		if err != nil {
+			logger.Fatal("Not able to open disk queue ", err)
+		}
+		defer diskQueue.Close()
+
+		loadingDataChan = make(chan transformation.Record, config.Application.ProcessPipelineSize)
+		runStage("disk queue ingest", func() error { return diskQueue.Ingest(ctx, structedDataChan) })
+		runStage("disk queue consume", func() error { return diskQueue.Consume(ctx, loadingDataChan) })
+		repo = loading.NewAckRepository(repo, diskQueue, appLogger)
+	default:
+		logger.Fatalf("Not implemented queue %s", config.Queue.Type)
+	}
+	// dedicate go routine for storing processed data to datastore
+	loader := loading.NewLoader(repo, config.Application.BulkInsert, config.Application.BulkInsertSize, config.Application.BulkInsertInterval, appLogger)
+	runStage("loader", func() error { return loader.Run(ctx, loadingDataChan) })
+
+	// manager for running each datasource in dedicated go routine which allow getting data from different data source simultaneously
+	manager := worker.NewManager(ctx, appLogger, newExtractors(appLogger, checkpointStore), transformers, pipeline, schemas, transformedDataChan)
+	for _, datasource := range config.Datasource {
+		if err := manager.Add(datasource); err != nil {
+			logger.Errorf("datasource %s type : %s, transformer: %s not started %v", datasource.Name, datasource.Type, datasource.Transformer, err)
+		}
+	}
+
+	// apply config file changes without restart
+	reloader := newReloader(logger, manager, loader, config, flags.datasources)
+	configpkg.WatchConfig(reloader.apply)
+
+	if config.Admin.Enable {
+		// admin HTTP API for managing datasources at runtime
+		server := &nethttp.Server{
+			Addr:    config.Admin.Address,
+			Handler: admin.NewServer(manager, config.Admin.Token, reloader.validateDatasource, appLogger),
+		}
+		go func() {
+			logger.Infof("Admin API listening on %s", config.Admin.Address)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+				logger.Errorf("Admin API stopped %v", err)
+			}
+		}()
+		defer server.Close()
+
+		// datasource can be added at runtime, so keep running until signal received
+		<-ctx.Done()
+	}
+
+	// make sure the application won't close before all extraction processor stopped
+	manager.Wait()
+
+	select {
+	case err := <-stageErr:
+		logger.Errorf("Stopped as not able to load data %v", err)
+		failed = true
+	default:
+	}
+}
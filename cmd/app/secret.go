@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	configpkg "github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// encrypt secret read from stdin with local key file
+// print ${enc:...} reference which can be used as config value
+func encryptSecretCommand(logger *logrus.Logger, args []string) {
+	fs := flag.NewFlagSet("encrypt-secret", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "file of base64 encoded AES-256 key (same as Secrets.KeyFile config)")
+	generateKey := fs.Bool("generate-key", false, "generate new key file before encrypting (fail if key file already exist)")
+	fs.Parse(args)
+
+	if *keyFile == "" {
+		logger.Fatal("Missing -key-file")
+	}
+	if *generateKey {
+		if err := utils.GenerateKey(*keyFile); err != nil {
+			logger.Fatal("Not able to generate key ", err)
+		}
+	}
+
+	secret, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && secret == "" {
+		logger.Fatal("Not able to read secret from stdin ", err)
+	}
+
+	reference, err := configpkg.EncryptSecret(*keyFile, strings.TrimRight(secret, "\r\n"))
+	if err != nil {
+		logger.Fatal("Not able to encrypt secret ", err)
+	}
+	fmt.Println(reference)
+}
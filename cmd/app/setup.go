@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	configpkg "github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/deduplication"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/privacy"
+	"github.com/awcjack/ETL-sample/schema"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/http"
+	"github.com/awcjack/ETL-sample/transformation/script"
+	"github.com/awcjack/ETL-sample/transformation/steps"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/validation"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+// set logger level and format from config
+// return structured logger shared by extraction, transformation and loading
+func setupLogger(logger *logrus.Logger, config *configpkg.Config) utils.Logger {
+	// set logger level from config
+	logLevel, err := logrus.ParseLevel(config.Application.LogLevel)
+	if err != nil {
+		logger.Errorf("Not able to parse log level %v, changing to default level info level", err)
+		logLevel = logrus.InfoLevel
+	}
+	logger.SetLevel(logLevel)
+
+	// set logger output format (text / json) from config
+	if err := utils.SetLogFormat(logger, config.Application.LogFormat); err != nil {
+		logger.Errorf("Not able to set log format %v, changing to default text format", err)
+	}
+
+	return utils.NewLogrusLogger(logger)
+}
+
+// Create database connection (possible to switch to other datastore implementation)
+// no connection (nil) if records are written to archive and kafka sinks only
+func newDatabase(config *configpkg.Config) (*sqlx.DB, error) {
+	if config.Database.Type == "postgresql" {
+		// Start PostgreSQL connection
+		return loading.NewPostgreSQLConnection(config.Database)
+	}
+	if config.Database.Type == "none" {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("not implemented datastore repository %s", config.Database.Type)
+}
+
+// Create schema of each entity from Entities config
+// date field values are parsed by layouts and timezone of Transformation.Dates config
+func newSchemas(config *configpkg.Config) (transformation.Schemas, error) {
+	dates, err := transformation.NewDateParser(config.Transformation.Dates)
+	if err != nil {
+		return nil, err
+	}
+	return transformation.NewSchemasWithDates(config.Entities, dates)
+}
+
+// Register entity schemas to versioned registry (if enabled) and check entity tables against database
+// additive changes are applied to tables if Schema.Migrate is enabled, incompatible changes are refused
+// tables are not checked without database
+func evolveSchemas(ctx context.Context, config *configpkg.Config, db *sqlx.DB, logger utils.Logger) error {
+	if config.Schema.RegistryPath != "" {
+		registry := schema.NewRegistry(config.Schema.RegistryPath)
+		for _, entity := range config.Entities {
+			version, registered, err := registry.Register(entity)
+			if err != nil {
+				return err
+			}
+			if registered {
+				logger.Infof("registered schema v%d of entity %s", version.Version, entity.Name)
+			}
+		}
+	}
+	if db == nil {
+		return nil
+	}
+
+	return schema.Evolve(ctx, schema.NewPostgreSQLCatalog(db), config.Entities, config.Schema.Migrate, logger)
+}
+
+// check entity schemas against latest registered versions without registering new version
+func checkRegistry(config *configpkg.Config) []string {
+	problems := make([]string, 0)
+	if config.Schema.RegistryPath == "" {
+		return problems
+	}
+	registry := schema.NewRegistry(config.Schema.RegistryPath)
+	for _, entity := range config.Entities {
+		if _, err := registry.Check(entity); err != nil {
+			problems = append(problems, fmt.Sprintf("schema: %v", err))
+		}
+	}
+	return problems
+}
+
+// check entity tables against entity schemas without migrating them
+func checkDrift(ctx context.Context, config *configpkg.Config, db *sqlx.DB) []string {
+	problems := make([]string, 0)
+	catalog := schema.NewPostgreSQLCatalog(db)
+	for _, entity := range config.Entities {
+		drift, err := schema.DetectDrift(ctx, catalog, entity)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("schema: %v", err))
+			continue
+		}
+		for _, problem := range drift.Problems(config.Schema.Migrate) {
+			problems = append(problems, "schema: "+problem)
+		}
+	}
+	return problems
+}
+
+// Create repository writing records to database (if any), archive file and kafka topics (if enabled)
+// records of each entity are written to table of entity config
+// privacy policy of each sink is applied before records are written to the sink
+// returned close function closes archive file and kafka producer after loading finished
+func newRepository(config *configpkg.Config, db *sqlx.DB, schemas transformation.Schemas, logger utils.Logger) (loading.Repository, func(), error) {
+	policies, err := privacy.NewPolicies(config.Privacy, schemas)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repos := make([]loading.Repository, 0, 3)
+	if db != nil {
+		repos = append(repos, loading.NewPrivacyRepository(loading.NewPostgreSQLRepository(db, config.Entities, logger), policies[privacy.SinkDatabase]))
+	}
+	var archive *loading.FileRepository
+	if config.Archive.Enable {
+		archive = loading.NewFileRepository(config.Archive.Path, logger)
+		repos = append(repos, loading.NewPrivacyRepository(archive, policies[privacy.SinkArchive]))
+	}
+	var kafka *loading.KafkaRepository
+	if config.Kafka.Enable {
+		if kafka, err = loading.NewKafkaRepository(config.Kafka, schemas, logger); err != nil {
+			return nil, nil, err
+		}
+		repos = append(repos, loading.NewPrivacyRepository(kafka, policies[privacy.SinkKafka]))
+	}
+	closeSinks := func() {
+		archive.Close()
+		kafka.Close()
+	}
+
+	if len(repos) == 1 {
+		return repos[0], closeSinks, nil
+	}
+	return loading.NewMultiRepository(repos...), closeSinks, nil
+}
+
+// Create checkpoint store for resuming extraction after restart
+func newCheckpointStore(config *configpkg.Config, db *sqlx.DB) (checkpoint.Store, error) {
+	switch config.Checkpoint.Type {
+	case "memory":
+		return checkpoint.NewMemoryStore(), nil
+	case "file":
+		return checkpoint.NewFileStore(config.Checkpoint.Path)
+	case "postgresql":
+		return checkpoint.NewPostgreSQLStore(db), nil
+	default:
+		return nil, fmt.Errorf("not implemented checkpoint store %s", config.Checkpoint.Type)
+	}
+}
+
+// data extraction processor factory keyed by datasource type
+var extractorFactories = map[string]func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration{
+	"http": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewHttpExtraction(logger, checkpointStore)
+	},
+	"file": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewFileExtraction(logger, checkpointStore)
+	},
+	// pushed records have no position, so checkpoint store is not used
+	"http-listener": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewListenerExtraction(logger)
+	},
+	// id of last event is checkpointed for resuming stream
+	"sse": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewSSEExtraction(logger, checkpointStore)
+	},
+	// messages have no position, so checkpoint store is not used
+	"websocket": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewWebSocketExtraction(logger)
+	},
+	// watermark of last committed row is checkpointed for incremental query
+	"sql": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewSQLExtraction(logger, checkpointStore, extraction.NewSQLQuery)
+	},
+	// end of last committed transaction is checkpointed and confirmed to replication slot
+	"postgres-cdc": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewPostgresCDCExtraction(logger, checkpointStore)
+	},
+	// loaded offsets are checkpointed and committed to consumer group
+	"kafka": func(logger utils.Logger, checkpointStore checkpoint.Store) extraction.DataSourceExtration {
+		return extraction.NewKafkaExtraction(logger, checkpointStore)
+	},
+}
+
+// datasource types which run until the application stops regardless of Once, so they can't be backfilled
+var unboundedDatasourceTypes = []string{"http-listener", "kafka", "postgres-cdc"}
+
+// transformer factory keyed by transformer name
+var transformerFactories = map[string]func(config *configpkg.Config, logger utils.Logger) (transformation.TransformFunc, error){
+	"random-data-api": func(config *configpkg.Config, logger utils.Logger) (transformation.TransformFunc, error) {
+		dates, err := transformation.NewDateParser(config.Transformation.Dates)
+		if err != nil {
+			return nil, err
+		}
+		return transformation.Single(http.NewRandomDataAPITransformer(dates).Transform), nil
+	},
+	// token key is required by config validation only if a datasource use the transformer
+	"random-data-api-v2": func(config *configpkg.Config, logger utils.Logger) (transformation.TransformFunc, error) {
+		dates, err := transformation.NewDateParser(config.Transformation.Dates)
+		if err != nil {
+			return nil, err
+		}
+		var key []byte
+		if config.Transformation.TokenKeyFile != "" {
+			if key, err = utils.LoadKey(config.Transformation.TokenKeyFile); err != nil {
+				return nil, fmt.Errorf("Transformation.TokenKeyFile %w", err)
+			}
+		}
+		return http.NewRandomDataAPIV2Transformer(key, dates).Transform, nil
+	},
+	// JSON object keyed by field names (e.g. sql datasource row with columns aliased to field names)
+	"fields": func(config *configpkg.Config, logger utils.Logger) (transformation.TransformFunc, error) {
+		return transformation.FieldsTransformer, nil
+	},
+	// record published by kafka sink (JSON or avro of entity schema)
+	"record": func(config *configpkg.Config, logger utils.Logger) (transformation.TransformFunc, error) {
+		schemas, err := newSchemas(config)
+		if err != nil {
+			return nil, err
+		}
+		codecs, err := transformation.NewAvroCodecs(schemas)
+		if err != nil {
+			return nil, err
+		}
+		return transformation.NewRecordTransformer(codecs), nil
+	},
+}
+
+// data extraction processor keyed by datasource type
+func newExtractors(logger utils.Logger, checkpointStore checkpoint.Store) map[string]extraction.DataSourceExtration {
+	extractors := make(map[string]extraction.DataSourceExtration, len(extractorFactories))
+	for name, factory := range extractorFactories {
+		extractors[name] = factory(logger, checkpointStore)
+	}
+	return extractors
+}
+
+// transformer keyed by transformer name (including scripts) and transformation steps of each datasource
+func newTransformation(config *configpkg.Config, schemas transformation.Schemas, logger utils.Logger) (map[string]transformation.TransformFunc, *steps.Pipeline, error) {
+	transformers := make(map[string]transformation.TransformFunc, len(transformerFactories)+len(config.Scripts))
+	for name, factory := range transformerFactories {
+		transformer, err := factory(config, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		transformers[name] = transformer
+	}
+
+	// script can be used as transformer or script transformation step
+	scriptSteps := make(map[string]transformation.Step, len(config.Scripts))
+	for _, scriptConfig := range config.Scripts {
+		s, err := script.NewScript(scriptConfig, schemas, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		transformers[scriptConfig.Name] = s.Transform
+		scriptSteps[scriptConfig.Name] = s.Step()
+	}
+
+	pipeline, err := steps.NewPipeline(config.Transformation.Steps, scriptSteps, schemas)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return transformers, pipeline, nil
+}
+
+// implemented datasource types and transformer names (script is also a transformer)
+func implementedNames(config *configpkg.Config) ([]string, []string) {
+	datasourceTypes := make([]string, 0, len(extractorFactories))
+	for name := range extractorFactories {
+		datasourceTypes = append(datasourceTypes, name)
+	}
+	sort.Strings(datasourceTypes)
+
+	transformers := make([]string, 0, len(transformerFactories)+len(config.Scripts))
+	for name := range transformerFactories {
+		transformers = append(transformers, name)
+	}
+	for _, scriptConfig := range config.Scripts {
+		transformers = append(transformers, scriptConfig.Name)
+	}
+	sort.Strings(transformers)
+
+	return datasourceTypes, transformers
+}
+
+// validate config against implemented datasource types and transformers
+func validateConfig(config *configpkg.Config) error {
+	datasourceTypes, transformers := implementedNames(config)
+	err := config.Validate(datasourceTypes, transformers)
+
+	// fields of steps, rules, policies and deduplication are resolved by entity schemas
+	// invalid entity is already reported by config validation
+	schemas, schemaErr := newSchemas(config)
+	if schemaErr != nil {
+		return mergeValidationErrors(err, &configpkg.ValidationError{Problems: []string{schemaErr.Error()}})
+	}
+
+	// transformer key files are checked by creating the transformers
+	for _, name := range transformers {
+		if factory, ok := transformerFactories[name]; ok {
+			if _, factoryErr := factory(config, utils.NewLogrusLogger(logrus.StandardLogger())); factoryErr != nil {
+				err = mergeValidationErrors(err, &configpkg.ValidationError{Problems: []string{factoryErr.Error()}})
+			}
+		}
+	}
+
+	// script syntax is checked by loading the scripts
+	scriptSteps := make(map[string]transformation.Step, len(config.Scripts))
+	for _, scriptConfig := range config.Scripts {
+		scriptSteps[scriptConfig.Name] = nil
+		if _, scriptErr := script.NewScript(scriptConfig, schemas, utils.NewLogrusLogger(logrus.StandardLogger())); scriptErr != nil {
+			err = mergeValidationErrors(err, &configpkg.ValidationError{Problems: []string{scriptErr.Error()}})
+		}
+	}
+
+	// fields, regex and date bounds of transformation steps and validation rules are checked by compiling them
+	if _, stepErr := steps.NewPipeline(config.Transformation.Steps, scriptSteps, schemas); stepErr != nil {
+		err = mergeValidationErrors(err, stepErr)
+	}
+	if _, ruleErr := validation.CompileRules(config.Validation.Rules, schemas); ruleErr != nil {
+		err = mergeValidationErrors(err, ruleErr)
+	}
+	// privacy policy fields and key files are checked by compiling the policies
+	if _, policyErr := privacy.NewPolicies(config.Privacy, schemas); policyErr != nil {
+		err = mergeValidationErrors(err, policyErr)
+	}
+
+	// deduplication fields are checked by compiling the key
+	if config.Deduplication.Enable {
+		if _, keyErr := deduplication.CompileKey(config.Deduplication.Fields, schemas); keyErr != nil {
+			err = mergeValidationErrors(err, keyErr)
+		}
+	}
+
+	return err
+}
+
+// merge problems of both validation errors into single validation error
+func mergeValidationErrors(err error, other error) error {
+	var validationErr, otherValidationErr *configpkg.ValidationError
+	if err == nil {
+		return other
+	}
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	if !errors.As(other, &otherValidationErr) {
+		return other
+	}
+
+	validationErr.Problems = append(validationErr.Problems, otherValidationErr.Problems...)
+	return validationErr
+}
+
+// Create validation stage between transformation and loading
+func newValidator(config *configpkg.Config, schemas transformation.Schemas, logger utils.Logger) (*validation.Validator, *validation.FileQuarantine, error) {
+	rules, err := validation.CompileRules(config.Validation.Rules, schemas)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quarantine := validation.NewFileQuarantine(config.Validation.QuarantinePath)
+	return validation.NewValidator(rules, quarantine, logger), quarantine, nil
+}
+
+// Create deduplication stage between validation and loading
+// bloom filter file should be saved by closing deduplicator after loading finished
+func newDeduplicator(config *configpkg.Config, schemas transformation.Schemas, logger utils.Logger) (*deduplication.Deduplicator, error) {
+	return deduplication.NewDeduplicator(config.Deduplication, schemas, logger)
+}
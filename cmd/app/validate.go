@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	configpkg "github.com/awcjack/ETL-sample/config"
+	"github.com/sirupsen/logrus"
+)
+
+// check config (including datasources and transformers) and datastore connection
+// exit with non zero code if any problem found
+func validateConfigCommand(ctx context.Context, logger *logrus.Logger, args []string) {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	skipDatabase := fs.Bool("skip-database", false, "skip checking datastore connection")
+	printConfig := fs.Bool("print", false, "print effective config with secrets redacted")
+	fs.Parse(args)
+
+	problems := make([]string, 0)
+
+	config, err := flags.loadConfig(fs)
+	var validationErr *configpkg.ValidationError
+	if errors.As(err, &validationErr) {
+		problems = append(problems, validationErr.Problems...)
+	} else if err != nil {
+		logger.Fatal("Not able to load config ", err)
+	}
+	// config can't be parsed if secret can't be resolved
+	if config == nil {
+		reportProblems(problems)
+	}
+	setupLogger(logger, config)
+
+	if *printConfig {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(config.Redacted())
+	}
+
+	// schema changes are reported without registering version or migrating table
+	problems = append(problems, checkRegistry(config)...)
+
+	// nothing to check without database
+	if !*skipDatabase && config.Database.Type != "none" {
+		db, err := newDatabase(config)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("database: %v", err))
+		} else {
+			if err := db.PingContext(ctx); err != nil {
+				problems = append(problems, fmt.Sprintf("database: %v", err))
+			} else {
+				problems = append(problems, checkDrift(ctx, config, db)...)
+			}
+			db.Close()
+		}
+	}
+
+	if len(problems) != 0 {
+		reportProblems(problems)
+	}
+
+	fmt.Printf("config is valid (%d datasources)\n", len(config.Datasource))
+}
+
+// print all problems and exit with non zero code
+func reportProblems(problems []string) {
+	fmt.Fprintf(os.Stderr, "config is invalid:\n")
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+	}
+	os.Exit(1)
+}
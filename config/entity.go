@@ -0,0 +1,197 @@
+package config
+
+import (
+	"strings"
+	"unicode"
+)
+
+// entity of records produced by datasource if not configured
+const DefaultEntity = "users"
+
+// entity of parent records produced by random-data-api-v2 transformer, child records are employments and subscriptions
+const ProfileEntity = "profiles"
+
+// transformer producing profiles with child employments and subscriptions
+const randomDataAPIV2 = "random-data-api-v2"
+
+// Entity config describing fields of records and the table they are loaded to
+type EntityConfig struct {
+	// entity name referenced by datasource (e.g. users, orders)
+	Name string
+	// table of entity (default entity name)
+	Table string
+	// fields of records in order of table columns
+	Fields []EntityFieldConfig
+	// names of fields identifying a row (e.g. primary key), required to load updates and deletes of postgres-cdc datasource
+	Key []string
+}
+
+// Field of entity
+type EntityFieldConfig struct {
+	// field name, nested field is separated by dot (e.g. Address.City)
+	Name string
+	// field type ["string", "float", "int", "bool", "date"]
+	Type string
+	// column of field (default snake case field name, e.g. address_city)
+	Column string
+	// keep calendar date only of date field (in source timezone of Transformation.Dates), stored in DATE column
+	DateOnly bool
+}
+
+// built-in users entity produced by random-data-api transformer and loaded to users table (docker/postgresql/init.sql)
+func UserEntity() EntityConfig {
+	field := func(name string, fieldType string, column string) EntityFieldConfig {
+		return EntityFieldConfig{Name: name, Type: fieldType, Column: column, DateOnly: fieldType == "date"}
+	}
+
+	return EntityConfig{
+		Name:  DefaultEntity,
+		Table: "users",
+		Fields: []EntityFieldConfig{
+			field("FirstName", "string", "first_name"),
+			field("LastName", "string", "last_name"),
+			field("DateOfBirth", "date", "date_of_birth"),
+			field("Address.City", "string", "city"),
+			field("Address.StreetName", "string", "street_name"),
+			field("Address.StreetAddress", "string", "street_address"),
+			field("Address.ZipCode", "string", "zip_code"),
+			field("Address.State", "string", "state"),
+			// ISO 3166-2 subdivision code of State (e.g. US-TX), set by iso3166 transformation step
+			field("Address.StateCode", "string", "state_code"),
+			field("Address.Country", "string", "country"),
+			// ISO 3166-1 alpha-2 code of Country (e.g. US), set by iso3166 transformation step
+			field("Address.CountryCode", "string", "country_code"),
+			field("Address.Latitude", "float", "latitude"),
+			field("Address.Longitude", "float", "longitude"),
+			// country, coordinates check, timezone and geohash of coordinates, set by geo transformation step
+			field("Address.GeoCountryCode", "string", "geo_country_code"),
+			field("Address.GeoCheck", "string", "geo_check"),
+			field("Address.Timezone", "string", "timezone"),
+			field("Address.Geohash", "string", "geohash"),
+		},
+	}
+}
+
+// built-in entities produced by random-data-api-v2 transformer (docker/postgresql/init.sql)
+// employments and subscriptions are child records of profile joined by Uid
+func ProfileEntities() []EntityConfig {
+	field := func(name string, fieldType string, column string) EntityFieldConfig {
+		return EntityFieldConfig{Name: name, Type: fieldType, Column: column, DateOnly: fieldType == "date"}
+	}
+
+	// address fields are same as users, so iso3166 and geo transformation steps apply to profiles
+	profile := EntityConfig{
+		Name:  ProfileEntity,
+		Table: "profiles",
+		Fields: []EntityFieldConfig{
+			field("Uid", "string", "uid"),
+			field("Id", "int", "api_id"),
+			field("Username", "string", "username"),
+			field("Email", "string", "email"),
+			field("FirstName", "string", "first_name"),
+			field("LastName", "string", "last_name"),
+			field("Gender", "string", "gender"),
+			field("PhoneNumber", "string", "phone_number"),
+			field("SocialInsuranceNumber", "string", "social_insurance_number"),
+			field("Avatar", "string", "avatar"),
+			field("DateOfBirth", "date", "date_of_birth"),
+		},
+	}
+	for _, f := range UserEntity().Fields {
+		if strings.HasPrefix(f.Name, "Address.") {
+			profile.Fields = append(profile.Fields, f)
+		}
+	}
+	// credit card number is never kept, only its token and last 4 digits
+	profile.Fields = append(profile.Fields,
+		field("CreditCard.Token", "string", "credit_card_token"),
+		field("CreditCard.Last4", "string", "credit_card_last4"),
+	)
+
+	return []EntityConfig{
+		profile,
+		{
+			Name:  "employments",
+			Table: "employments",
+			Fields: []EntityFieldConfig{
+				field("Uid", "string", "profile_uid"),
+				field("Title", "string", "title"),
+				field("KeySkill", "string", "key_skill"),
+			},
+		},
+		{
+			Name:  "subscriptions",
+			Table: "subscriptions",
+			Fields: []EntityFieldConfig{
+				field("Uid", "string", "profile_uid"),
+				field("Plan", "string", "plan"),
+				field("Status", "string", "status"),
+				field("PaymentMethod", "string", "payment_method"),
+				field("Term", "string", "term"),
+			},
+		},
+	}
+}
+
+// fill default table and columns, built-in users entity is added if not configured
+// built-in profile entities are added if not configured and any datasource use random-data-api-v2 transformer
+func withDefaultEntities(entities []EntityConfig, datasources []DataSourceConfig) []EntityConfig {
+	result := make([]EntityConfig, 0, len(entities)+1)
+	configured := make(map[string]bool, len(entities))
+	hasDefault := false
+	for _, entity := range entities {
+		configured[entity.Name] = true
+		hasDefault = hasDefault || entity.Name == DefaultEntity
+		if entity.Table == "" {
+			entity.Table = entity.Name
+		}
+		fields := make([]EntityFieldConfig, 0, len(entity.Fields))
+		for _, field := range entity.Fields {
+			if field.Column == "" {
+				field.Column = columnName(field.Name)
+			}
+			fields = append(fields, field)
+		}
+		entity.Fields = fields
+		result = append(result, entity)
+	}
+	if !hasDefault {
+		result = append(result, UserEntity())
+	}
+	for _, datasource := range datasources {
+		if datasource.Transformer != randomDataAPIV2 {
+			continue
+		}
+		for _, entity := range ProfileEntities() {
+			if !configured[entity.Name] {
+				configured[entity.Name] = true
+				result = append(result, entity)
+			}
+		}
+		break
+	}
+
+	return result
+}
+
+// snake case of field name with dot replaced by underscore (e.g. Address.ZipCode -> address_zip_code)
+func columnName(field string) string {
+	var b strings.Builder
+	runes := []rune(field)
+	for i, r := range runes {
+		switch {
+		case r == '.':
+			b.WriteRune('_')
+		case unicode.IsUpper(r):
+			// word boundary before upper case letter following lower case letter or digit (e.g. ZipCode, Line2Name)
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
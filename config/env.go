@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// prefix of environment variables overriding config values
+const envPrefix = "ETL"
+
+// ${VAR} or ${VAR:-default} reference to environment variable
+// or ${provider:reference} / ${provider:reference:-default} reference to secret (provider: env, file, enc)
+var envReferencePattern = regexp.MustCompile(`\$\{(?:([A-Za-z_][A-Za-z0-9_]*)|(env|file|enc):([^}]*?))(:-([^}]*))?\}`)
+
+// replace ${VAR} in value by environment variable VAR
+// ${VAR:-default} is replaced by default if VAR is not set
+// ${provider:reference} is replaced by secret, which is redacted in Config.Redacted
+func interpolate(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+
+	return envReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		match := envReferencePattern.FindStringSubmatch(reference)
+		if match[2] != "" {
+			return resolver.resolve(match[2], match[3], match[5], match[4] != "")
+		}
+		if v, ok := os.LookupEnv(match[1]); ok {
+			return v
+		}
+		return match[5]
+	})
+}
+
+// replace references in every string field of struct or slice of struct
+func interpolateStrings(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.String:
+		if value.CanSet() {
+			value.SetString(interpolate(value.String()))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			interpolateStrings(value.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			interpolateStrings(value.Index(i))
+		}
+	}
+}
+
+// override datasource fields from indexed environment variables (e.g. ETL_DATASOURCE_0_SOURCE)
+// index after last datasource in config file add a new datasource
+// ${VAR} in string fields is replaced by environment variable VAR
+func overrideDatasourcesFromEnv(datasources []DataSourceConfig) ([]DataSourceConfig, error) {
+	result := make([]DataSourceConfig, 0, len(datasources))
+	for i := 0; ; i++ {
+		var datasource DataSourceConfig
+		if i < len(datasources) {
+			datasource = datasources[i]
+		}
+
+		found := false
+		value := reflect.ValueOf(&datasource).Elem()
+		for j := 0; j < value.NumField(); j++ {
+			field := value.Field(j)
+			name := fmt.Sprintf("%s_DATASOURCE_%d_%s", envPrefix, i, strings.ToUpper(value.Type().Field(j).Name))
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				if field.Kind() == reflect.String {
+					field.SetString(interpolate(field.String()))
+				}
+				continue
+			}
+
+			found = true
+			env = interpolate(env)
+			switch field.Kind() {
+			case reflect.String:
+				field.SetString(env)
+			case reflect.Bool:
+				b, err := strconv.ParseBool(env)
+				if err != nil {
+					return nil, fmt.Errorf("invalid bool in %s: %w", name, err)
+				}
+				field.SetBool(b)
+			case reflect.Int, reflect.Int64:
+				n, err := strconv.ParseInt(env, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number in %s: %w", name, err)
+				}
+				field.SetInt(n)
+			}
+		}
+
+		if i >= len(datasources) && !found {
+			break
+		}
+		result = append(result, datasource)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// secret providers usable in ${provider:reference} of config values
+const (
+	// ${env:VAR} environment variable
+	secretProviderEnv = "env"
+	// ${file:/path} file content without trailing newline (e.g. kubernetes secret mount)
+	secretProviderFile = "file"
+	// ${enc:base64} AES-256-GCM encrypted value decrypted by key in Secrets.KeyFile
+	secretProviderEncrypted = "enc"
+)
+
+// resolve secret references while parsing config
+// resolved values are kept for redaction, resolve errors and values which can't be parsed are collected until parsing finished
+type secretResolver struct {
+	keyFile string
+	key     []byte
+	secrets []string
+	errs    []string
+}
+
+var (
+	// only one config is parsed at a time (initial load and reload on config file change)
+	parseMu  sync.Mutex
+	resolver = &secretResolver{}
+
+	// secrets resolved by every parsed config and validated datasource
+	// kept after reload, as source of running datasource may still contain them
+	knownSecretsMu sync.RWMutex
+	knownSecrets   []string
+)
+
+func newSecretResolver(keyFile string) *secretResolver {
+	return &secretResolver{
+		keyFile: keyFile,
+	}
+}
+
+// resolve secret reference, default value is used if secret can't be resolved
+func (r *secretResolver) resolve(provider, reference string, defaultValue string, hasDefault bool) string {
+	value, err := r.lookup(provider, reference)
+	if err != nil {
+		if !hasDefault {
+			r.errs = append(r.errs, err.Error())
+		}
+		return defaultValue
+	}
+
+	r.secrets = append(r.secrets, value)
+	return value
+}
+
+func (r *secretResolver) lookup(provider, reference string) (string, error) {
+	switch provider {
+	case secretProviderEnv:
+		value, ok := os.LookupEnv(reference)
+		if !ok {
+			return "", fmt.Errorf("secret env:%s is not set", reference)
+		}
+		return value, nil
+	case secretProviderFile:
+		content, err := os.ReadFile(reference)
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file:%s %v", reference, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case secretProviderEncrypted:
+		value, err := r.decrypt(reference)
+		if err != nil {
+			// reference is ciphertext which is safe to log
+			return "", fmt.Errorf("unable to decrypt secret enc:%s %v", reference, err)
+		}
+		return value, nil
+	}
+
+	return "", fmt.Errorf("unknown secret provider %s", provider)
+}
+
+func (r *secretResolver) decrypt(reference string) (string, error) {
+	if r.key == nil {
+		if r.keyFile == "" {
+			return "", fmt.Errorf("Secrets.KeyFile is missing")
+		}
+		key, err := utils.LoadKey(r.keyFile)
+		if err != nil {
+			return "", err
+		}
+		r.key = key
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(reference)
+	if err != nil {
+		return "", fmt.Errorf("ciphertext is not base64 encoded")
+	}
+	plaintext, err := utils.Decrypt(r.key, ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// keep resolved secrets to be masked by RedactSecrets
+func recordSecrets(secrets []string) {
+	knownSecretsMu.Lock()
+	defer knownSecretsMu.Unlock()
+
+	for _, secret := range secrets {
+		if !slices.Contains(knownSecrets, secret) {
+			knownSecrets = append(knownSecrets, secret)
+		}
+	}
+}
+
+// mask every resolved secret in value (e.g. source url in log message or http client error)
+func RedactSecrets(value string) string {
+	knownSecretsMu.RLock()
+	defer knownSecretsMu.RUnlock()
+
+	return utils.RedactSecrets(value, knownSecrets)
+}
+
+func (r *secretResolver) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Problems: r.errs}
+}
+
+// encrypt secret with key in key file
+// return reference which can be used in config value
+func EncryptSecret(keyFile string, secret string) (string, error) {
+	key, err := utils.LoadKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := utils.Encrypt(key, []byte(secret))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("${%s:%s}", secretProviderEncrypted, base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// copy of config with secrets masked, safe to be logged or printed
+// masked values are resolved secret references, database password, admin token, datasource secrets and sql and postgres-cdc datasource password
+func (c *Config) Redacted() Config {
+	// every slice is copied, so masking values doesn't change the config
+	redacted := deepCopy(reflect.ValueOf(*c)).Interface().(Config)
+
+	for i := range redacted.Datasource {
+		if redacted.Datasource[i].Secret != "" {
+			redacted.Datasource[i].Secret = utils.Redacted
+		}
+		if redacted.Datasource[i].Type == "sql" || redacted.Datasource[i].Type == "postgres-cdc" {
+			redacted.Datasource[i].Source = utils.RedactConnectionString(c.Datasource[i].Source)
+		}
+	}
+	redacted.Database.ConnectionString = utils.RedactConnectionString(c.Database.ConnectionString)
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = utils.Redacted
+	}
+
+	redactStrings(reflect.ValueOf(&redacted).Elem(), c.secrets)
+
+	return redacted
+}
+
+// copy of value whose slices, maps and pointers don't share memory with value
+// unexported fields are shallow copied
+func deepCopy(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(deepCopy(value.Field(i)))
+			}
+		}
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(deepCopy(value.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+		}
+		return copied
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(deepCopy(value.Elem()))
+		return copied
+	}
+	return value
+}
+
+// mask secrets in every string field of struct or slice of struct
+func redactStrings(value reflect.Value, secrets []string) {
+	switch value.Kind() {
+	case reflect.String:
+		if value.CanSet() {
+			value.SetString(utils.RedactSecrets(value.String(), secrets))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			redactStrings(value.Field(i), secrets)
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			redactStrings(value.Index(i), secrets)
+		}
+	}
+}
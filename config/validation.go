@@ -0,0 +1,495 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	// timezone is available without system timezone database (e.g. scratch container)
+	_ "time/tzdata"
+)
+
+var (
+	logLevels        = []string{"panic", "fatal", "error", "warn", "warning", "info", "debug", "trace"}
+	logFormats       = []string{"text", "json"}
+	databaseTypes    = []string{"postgresql", "none"}
+	checkpointTypes  = []string{"memory", "file", "postgresql"}
+	queueTypes       = []string{"memory", "disk"}
+	httpSourceScheme = []string{"http", "https"}
+	wsSourceScheme   = []string{"ws", "wss"}
+	sqlSourceScheme  = []string{"postgres", "postgresql", "mysql"}
+	cdcSourceScheme  = []string{"postgres", "postgresql"}
+	ruleActions      = []string{"reject", "warn", "quarantine"}
+	privacySinks     = []string{"database", "archive", "kafka"}
+	kafkaFormats     = []string{"json", "avro"}
+	privacyActions   = []string{"redact", "hash", "truncate", "encrypt"}
+	fieldTypes       = []string{"string", "float", "int", "bool", "date"}
+	stepNames        = []string{"trim", "normalize-space", "title-case", "uppercase", "lowercase", "default", "derive", "drop", "iso3166", "geo", "script"}
+	// replication slot and publication name, which are not quoted in replication commands
+	replicationName = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+)
+
+// all problems found in config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validate config and collect every problem into a single error
+// datasourceTypes and transformers are the implemented datasource types and transformer names
+func (c *Config) Validate(datasourceTypes []string, transformers []string) error {
+	v := &validator{}
+
+	// Application Config
+	v.oneOf("Application.LogLevel", strings.ToLower(c.Application.LogLevel), logLevels)
+	v.oneOf("Application.LogFormat", c.Application.LogFormat, logFormats)
+	v.positive("Application.ProcessPipelineSize", c.Application.ProcessPipelineSize)
+	if c.Application.BulkInsert {
+		v.positive("Application.BulkInsertSize", c.Application.BulkInsertSize)
+		v.positive("Application.BulkInsertInterval", c.Application.BulkInsertInterval)
+	}
+
+	// Database Config
+	v.oneOf("Database.Type", c.Database.Type, databaseTypes)
+	if c.Database.Type == "none" {
+		// records must be written to at least one sink
+		if !c.Archive.Enable && !c.Kafka.Enable {
+			v.add("Database.Type none requires Archive.Enable or Kafka.Enable")
+		}
+	} else if c.Database.ConnectionString == "" {
+		v.add("Database.ConnectionString is missing")
+	} else if strings.Contains(c.Database.ConnectionString, "://") {
+		// key=value connection string can't be validated as URL
+		if _, err := url.Parse(c.Database.ConnectionString); err != nil {
+			v.add("Database.ConnectionString is malformed URL")
+		}
+	}
+
+	// Checkpoint Config
+	v.oneOf("Checkpoint.Type", c.Checkpoint.Type, checkpointTypes)
+	if c.Checkpoint.Type == "file" && c.Checkpoint.Path == "" {
+		v.add("Checkpoint.Path is missing")
+	}
+	if c.Checkpoint.Type == "postgresql" && c.Database.Type == "none" {
+		v.add("Checkpoint.Type postgresql requires database")
+	}
+
+	// Queue Config
+	v.oneOf("Queue.Type", c.Queue.Type, queueTypes)
+	if c.Queue.Type == "disk" {
+		if c.Queue.Path == "" {
+			v.add("Queue.Path is missing")
+		}
+		v.positive("Queue.SegmentSize", c.Queue.SegmentSize)
+	}
+
+	// Admin Config
+	if c.Admin.Enable {
+		if c.Admin.Address == "" {
+			v.add("Admin.Address is missing")
+		}
+		if c.Admin.Token == "" {
+			v.add("Admin.Token is missing")
+		}
+	}
+
+	// Entities Config
+	entities := make(map[string]bool, len(c.Entities))
+	keyed := make(map[string]bool, len(c.Entities))
+	for i, entity := range c.Entities {
+		key := fmt.Sprintf("Entities[%d]", i)
+		if entity.Name == "" {
+			v.add(key + ".Name is missing")
+		} else {
+			key = fmt.Sprintf("Entities[%d] (%s)", i, entity.Name)
+			if entities[entity.Name] {
+				v.add(key + ".Name is duplicated")
+			}
+			entities[entity.Name] = true
+		}
+		if entity.Table == "" {
+			v.add(key + ".Table is missing")
+		}
+		if len(entity.Fields) == 0 {
+			v.add(key + ".Fields is missing")
+		}
+		fields := make(map[string]bool, len(entity.Fields))
+		columns := make(map[string]bool, len(entity.Fields))
+		for j, field := range entity.Fields {
+			fieldKey := fmt.Sprintf("%s.Fields[%d]", key, j)
+			if field.Name == "" {
+				v.add(fieldKey + ".Name is missing")
+			} else if fields[strings.ToLower(field.Name)] {
+				v.add(fmt.Sprintf("%s (%s).Name is duplicated", fieldKey, field.Name))
+			}
+			fields[strings.ToLower(field.Name)] = true
+			v.oneOf(fieldKey+".Type", field.Type, fieldTypes)
+			if field.DateOnly && field.Type != "date" {
+				v.add(fieldKey + ".DateOnly is only supported by date field")
+			}
+			if field.Column == "" {
+				v.add(fieldKey + ".Column is missing")
+			} else if columns[strings.ToLower(field.Column)] {
+				v.add(fmt.Sprintf("%s (%s).Column is duplicated", fieldKey, field.Column))
+			}
+			columns[strings.ToLower(field.Column)] = true
+		}
+		for j, name := range entity.Key {
+			if !fields[strings.ToLower(name)] {
+				v.add(fmt.Sprintf("%s.Key[%d] %q is not a field of entity", key, j, name))
+			}
+		}
+		keyed[entity.Name] = len(entity.Key) != 0
+	}
+
+	// Data source Config
+	names := make(map[string]bool, len(c.Datasource))
+	listenAddresses := make(map[string]string)
+	if c.Admin.Enable && c.Admin.Address != "" {
+		listenAddresses[c.Admin.Address] = "Admin.Address"
+	}
+	for i, datasource := range c.Datasource {
+		key := fmt.Sprintf("Datasource[%d]", i)
+		if datasource.Name == "" {
+			v.add(key + ".Name is missing")
+		} else {
+			key = fmt.Sprintf("Datasource[%d] (%s)", i, datasource.Name)
+			if names[datasource.Name] {
+				v.add(key + ".Name is duplicated")
+			}
+			names[datasource.Name] = true
+		}
+		v.datasource(c, key, datasource, datasourceTypes, transformers, keyed, listenAddresses)
+	}
+
+	// Scripts Config (script file is checked when script is compiled)
+	scripts := make(map[string]bool, len(c.Scripts))
+	for i, script := range c.Scripts {
+		key := fmt.Sprintf("Scripts[%d]", i)
+		if script.Name == "" {
+			v.add(key + ".Name is missing")
+		} else {
+			key = fmt.Sprintf("Scripts[%d] (%s)", i, script.Name)
+			if scripts[script.Name] {
+				v.add(key + ".Name is duplicated")
+			}
+			scripts[script.Name] = true
+		}
+		if script.Path == "" {
+			v.add(key + ".Path is missing")
+		}
+		v.positive(key+".MaxSteps", script.MaxSteps)
+		v.positive(key+".Timeout", script.Timeout)
+	}
+
+	// Transformation Config (fields and regex are checked when steps are compiled)
+	for i, step := range c.Transformation.Steps {
+		key := fmt.Sprintf("Transformation.Steps[%d]", i)
+		if step.Datasource != "" && !names[step.Datasource] {
+			v.add(fmt.Sprintf("%s.Datasource %q not found", key, step.Datasource))
+		}
+		v.oneOf(key+".Step", step.Step, stepNames)
+		if step.Step == "script" && !scripts[step.Script] {
+			v.add(fmt.Sprintf("%s.Script %q not found", key, step.Script))
+		}
+	}
+
+	if c.Transformation.Dates.Timezone != "" {
+		if _, err := time.LoadLocation(c.Transformation.Dates.Timezone); err != nil {
+			v.add(fmt.Sprintf("Transformation.Dates.Timezone %q is invalid", c.Transformation.Dates.Timezone))
+		}
+	}
+	for i, layout := range c.Transformation.Dates.Layouts {
+		if strings.TrimSpace(layout) == "" {
+			v.add(fmt.Sprintf("Transformation.Dates.Layouts[%d] is empty", i))
+		}
+	}
+
+	// Validation Config (field, regex and date bounds are checked when rules are compiled)
+	for i, rule := range c.Validation.Rules {
+		key := fmt.Sprintf("Validation.Rules[%d]", i)
+		if rule.Field == "" {
+			v.add(key + ".Field is missing")
+		}
+		if rule.Datasource != "" && !names[rule.Datasource] {
+			v.add(fmt.Sprintf("%s.Datasource %q not found", key, rule.Datasource))
+		}
+		v.oneOf(key+".Action", rule.Action, ruleActions)
+		if rule.Action == "quarantine" && c.Validation.QuarantinePath == "" {
+			v.add("Validation.QuarantinePath is missing")
+		}
+	}
+
+	// Archive Config
+	if c.Archive.Enable && c.Archive.Path == "" {
+		v.add("Archive.Path is missing")
+	}
+
+	// Kafka Config (key field may be missing in some entities, so it is not checked)
+	if c.Kafka.Enable {
+		if c.Kafka.Brokers == "" {
+			v.add("Kafka.Brokers is missing")
+		} else {
+			v.brokers("Kafka.Brokers", c.Kafka.Brokers)
+		}
+		if c.Kafka.Topic == "" {
+			v.add("Kafka.Topic is missing")
+		}
+		v.oneOf("Kafka.Format", c.Kafka.Format, kafkaFormats)
+	}
+
+	// Privacy Config (field and its type are checked when policies are compiled)
+	hash, encrypt := false, false
+	for i, policy := range c.Privacy.Policies {
+		key := fmt.Sprintf("Privacy.Policies[%d]", i)
+		if policy.Field == "" {
+			v.add(key + ".Field is missing")
+		}
+		v.oneOf(key+".Sink", policy.Sink, privacySinks)
+		v.oneOf(key+".Action", policy.Action, privacyActions)
+		hash = hash || policy.Action == "hash"
+		encrypt = encrypt || policy.Action == "encrypt"
+	}
+	if hash && c.Privacy.HashKeyFile == "" {
+		v.add("Privacy.HashKeyFile is missing")
+	}
+	if encrypt && c.Privacy.EncryptionKeyFile == "" {
+		v.add("Privacy.EncryptionKeyFile is missing")
+	}
+
+	// Deduplication Config (fields are checked when deduplication key is compiled)
+	if c.Deduplication.Enable {
+		if c.Deduplication.WindowSize <= 0 {
+			v.add(fmt.Sprintf("Deduplication.WindowSize must be positive but got %d", c.Deduplication.WindowSize))
+		}
+		if c.Deduplication.TTL < 0 {
+			v.add(fmt.Sprintf("Deduplication.TTL must not be negative but got %d", c.Deduplication.TTL))
+		}
+		if c.Deduplication.BloomFilterPath != "" {
+			if c.Deduplication.BloomFilterCapacity <= 0 {
+				v.add(fmt.Sprintf("Deduplication.BloomFilterCapacity must be positive but got %d", c.Deduplication.BloomFilterCapacity))
+			}
+			if rate := c.Deduplication.BloomFilterFalsePositiveRate; rate <= 0 || rate >= 1 {
+				v.add(fmt.Sprintf("Deduplication.BloomFilterFalsePositiveRate must be between 0 and 1 but got %v", rate))
+			}
+		}
+	}
+
+	if len(v.problems) != 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validate datasource added at runtime by the same checks as datasources of config file
+// references in string fields are resolved like config file and the resolved datasource is returned
+// listen address can't be shared with running datasources, datasource with same name is refused when it is added
+func (c *Config) ValidateDatasource(datasource DataSourceConfig, running []DataSourceConfig, datasourceTypes []string, transformers []string) (DataSourceConfig, error) {
+	v := &validator{}
+
+	parseMu.Lock()
+	resolver = newSecretResolver(c.Secrets.KeyFile)
+	interpolateStrings(reflect.ValueOf(&datasource).Elem())
+	v.problems = append(v.problems, resolver.errs...)
+	// secrets of added datasource are redacted as secrets of config file
+	c.secrets = append(c.secrets, resolver.secrets...)
+	recordSecrets(resolver.secrets)
+	resolver = &secretResolver{}
+	parseMu.Unlock()
+	datasource.Entity = datasourceEntity(datasource)
+
+	keyed := make(map[string]bool, len(c.Entities))
+	for _, entity := range c.Entities {
+		keyed[entity.Name] = len(entity.Key) != 0
+	}
+	listenAddresses := make(map[string]string)
+	if c.Admin.Enable && c.Admin.Address != "" {
+		listenAddresses[c.Admin.Address] = "Admin.Address"
+	}
+	for _, other := range running {
+		if other.Type == "http-listener" {
+			address, _, _ := strings.Cut(other.Source, "/")
+			listenAddresses[address] = fmt.Sprintf("Datasource (%s)", other.Name)
+		}
+	}
+
+	key := "Datasource"
+	if datasource.Name == "" {
+		v.add(key + ".Name is missing")
+	} else {
+		key = fmt.Sprintf("Datasource (%s)", datasource.Name)
+	}
+	v.datasource(c, key, datasource, datasourceTypes, transformers, keyed, listenAddresses)
+
+	if len(v.problems) != 0 {
+		return datasource, &ValidationError{Problems: v.problems}
+	}
+	return datasource, nil
+}
+
+// problem collector
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(problem string) {
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) oneOf(key string, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(fmt.Sprintf("%s %q is not supported (supported: %s)", key, value, strings.Join(allowed, ", ")))
+}
+
+func (v *validator) positive(key string, value int) {
+	if value <= 0 {
+		v.add(fmt.Sprintf("%s must be positive but got %d", key, value))
+	}
+}
+
+// checks of a single datasource, keyed is whether each entity has Key
+// listen address of http-listener datasource is added to listenAddresses used by other datasources
+func (v *validator) datasource(c *Config, key string, datasource DataSourceConfig, datasourceTypes []string, transformers []string, keyed map[string]bool, listenAddresses map[string]string) {
+	v.oneOf(key+".Type", datasource.Type, datasourceTypes)
+	v.oneOf(key+".Transformer", datasource.Transformer, transformers)
+	if _, ok := keyed[datasource.Entity]; !ok {
+		v.add(fmt.Sprintf("%s.Entity %q not found", key, datasource.Entity))
+	}
+	if datasource.Transformer == randomDataAPIV2 && c.Transformation.TokenKeyFile == "" {
+		v.add(key + ".Transformer requires Transformation.TokenKeyFile for tokenizing credit card numbers")
+	}
+	if datasource.Source == "" {
+		v.add(key + ".Source is missing")
+	} else if datasource.Type == "http" || datasource.Type == "sse" {
+		v.url(key+".Source", datasource.Source, httpSourceScheme)
+	} else if datasource.Type == "websocket" {
+		v.url(key+".Source", datasource.Source, wsSourceScheme)
+	} else if datasource.Type == "sql" {
+		// mysql data source name (e.g. user:password@tcp(host:3306)/db) is not URL, so only scheme is checked
+		// and source is not reported as it contains password
+		scheme, _, _ := strings.Cut(datasource.Source, "://")
+		if !slices.Contains(sqlSourceScheme, scheme) {
+			v.add(fmt.Sprintf("%s.Source is not connection string with scheme %s", key, strings.Join(sqlSourceScheme, ", ")))
+		}
+	} else if datasource.Type == "postgres-cdc" {
+		scheme, _, _ := strings.Cut(datasource.Source, "://")
+		if !slices.Contains(cdcSourceScheme, scheme) {
+			v.add(fmt.Sprintf("%s.Source is not connection string with scheme %s", key, strings.Join(cdcSourceScheme, ", ")))
+		}
+	} else if datasource.Type == "kafka" {
+		v.brokers(key+".Source", datasource.Source)
+	} else if datasource.Type == "http-listener" {
+		// every listener starts its own server, so listen address can't be shared
+		address, _, _ := strings.Cut(datasource.Source, "/")
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			v.add(fmt.Sprintf("%s.Source %q is not listen address and path (e.g. :8090/webhooks/orders)", key, datasource.Source))
+		} else if other, ok := listenAddresses[address]; ok {
+			v.add(fmt.Sprintf("%s.Source address %s is used by %s", key, address, other))
+		}
+		listenAddresses[address] = key
+	}
+	if datasource.Type == "kafka" {
+		if datasource.Topic == "" {
+			v.add(key + ".Topic is missing")
+		}
+		if datasource.Group == "" {
+			v.add(key + ".Group is missing")
+		}
+	}
+	if datasource.Type == "sql" {
+		v.sqlQuery(key, datasource)
+	}
+	if datasource.Type == "postgres-cdc" {
+		v.replication(key, datasource)
+		// updates and deletes are applied to rows by entity key
+		if hasKey, ok := keyed[datasource.Entity]; ok && !hasKey {
+			v.add(fmt.Sprintf("%s.Entity %q has no Key for updates and deletes", key, datasource.Entity))
+		}
+	}
+	if datasource.MaxBodySize < 0 {
+		v.add(key + ".MaxBodySize must not be negative")
+	}
+	if datasource.ReconnectDelay < 0 {
+		v.add(key + ".ReconnectDelay must not be negative")
+	}
+	// last rows of full chunk are queried again with next chunk, so chunk of single row can't progress
+	if datasource.ChunkSize < 0 || datasource.ChunkSize == 1 {
+		v.add(key + ".ChunkSize must be at least 2 (0 for default)")
+	}
+	if datasource.PollInterval < 0 {
+		v.add(key + ".PollInterval must not be negative")
+	}
+}
+
+// query of sql datasource must bind watermark and chunk size
+func (v *validator) sqlQuery(key string, datasource DataSourceConfig) {
+	if datasource.Query == "" {
+		v.add(key + ".Query is missing")
+	} else {
+		for _, parameter := range []string{":last_value", ":limit"} {
+			if !strings.Contains(datasource.Query, parameter) {
+				v.add(fmt.Sprintf("%s.Query is missing %s parameter", key, parameter))
+			}
+		}
+	}
+	if datasource.WatermarkColumn == "" {
+		v.add(key + ".WatermarkColumn is missing")
+	}
+	if datasource.InitialWatermark == "" {
+		v.add(key + ".InitialWatermark is missing")
+	}
+}
+
+// replication slot and publication of postgres-cdc datasource
+func (v *validator) replication(key string, datasource DataSourceConfig) {
+	if datasource.Slot == "" {
+		v.add(key + ".Slot is missing")
+	} else if !replicationName.MatchString(datasource.Slot) {
+		v.add(fmt.Sprintf("%s.Slot %q must be lower case letters, digits and underscores", key, datasource.Slot))
+	}
+	if datasource.Publication == "" {
+		v.add(key + ".Publication is missing")
+	} else if !replicationName.MatchString(datasource.Publication) {
+		v.add(fmt.Sprintf("%s.Publication %q must be lower case letters, digits and underscores", key, datasource.Publication))
+	}
+}
+
+// comma separated host:port of kafka brokers
+func (v *validator) brokers(key string, value string) {
+	for _, broker := range strings.Split(value, ",") {
+		if _, _, err := net.SplitHostPort(strings.TrimSpace(broker)); err != nil {
+			v.add(fmt.Sprintf("%s broker %q is not host:port", key, strings.TrimSpace(broker)))
+		}
+	}
+}
+
+func (v *validator) url(key string, value string, schemes []string) {
+	u, err := url.Parse(value)
+	// url may contain resolved secret (e.g. api key in query)
+	value = RedactSecrets(value)
+	if err != nil {
+		v.add(fmt.Sprintf("%s is malformed URL: %v", key, RedactSecrets(err.Error())))
+		return
+	}
+	if u.Host == "" {
+		v.add(fmt.Sprintf("%s %q is missing host", key, value))
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	v.add(fmt.Sprintf("%s %q has unsupported scheme (supported: %s)", key, value, strings.Join(schemes, ", ")))
+}
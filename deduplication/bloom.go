@@ -0,0 +1,122 @@
+package deduplication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// header of bloom filter file
+var bloomMagic = []byte("ETLBLOOM")
+
+// bloom filter of committed keys which can be persisted to file
+// key may be reported as seen wrongly with false positive rate, but seen key is never reported as not seen
+type bloomFilter struct {
+	bits   []uint64
+	m      uint64
+	hashes uint32
+}
+
+// size bloom filter for expected number of keys and false positive rate
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint32(math.Max(1, math.Round(float64(m)/float64(capacity)*math.Ln2)))
+	return &bloomFilter{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: hashes,
+	}
+}
+
+// double hashing with two halves of the key (key is already a cryptographic hash)
+func (b *bloomFilter) positions(key Key, fn func(position uint64) bool) bool {
+	h1 := binary.LittleEndian.Uint64(key[0:8])
+	h2 := binary.LittleEndian.Uint64(key[8:16])
+	for i := uint32(0); i < b.hashes; i++ {
+		if !fn((h1 + uint64(i)*h2) % b.m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) add(key Key) {
+	b.positions(key, func(position uint64) bool {
+		b.bits[position/64] |= 1 << (position % 64)
+		return true
+	})
+}
+
+func (b *bloomFilter) contains(key Key) bool {
+	return b.positions(key, func(position uint64) bool {
+		return b.bits[position/64]&(1<<(position%64)) != 0
+	})
+}
+
+// load bloom filter from file, return false if file not exist
+// file must be created with same capacity and false positive rate
+func (b *bloomFilter) load(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read bloom filter file")
+	}
+
+	r := bytes.NewReader(content)
+	magic := make([]byte, len(bloomMagic))
+	var m uint64
+	var hashes uint32
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, bloomMagic) {
+		return false, errors.New("invalid bloom filter file")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &m); err != nil {
+		return false, errors.New("invalid bloom filter file")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hashes); err != nil {
+		return false, errors.New("invalid bloom filter file")
+	}
+	if m != b.m || hashes != b.hashes {
+		return false, errors.Errorf("bloom filter file has %d bits and %d hashes but %d bits and %d hashes are expected by capacity and false positive rate, remove the file to rebuild it", m, hashes, b.m, b.hashes)
+	}
+	if err := binary.Read(r, binary.LittleEndian, b.bits); err != nil {
+		return false, errors.New("invalid bloom filter file")
+	}
+
+	return true, nil
+}
+
+// write to temp file and rename to make sure bloom filter file won't be corrupted if crash in the middle of write
+func (b *bloomFilter) save(path string) error {
+	var buf bytes.Buffer
+	buf.Write(bloomMagic)
+	binary.Write(&buf, binary.LittleEndian, b.m)
+	binary.Write(&buf, binary.LittleEndian, b.hashes)
+	binary.Write(&buf, binary.LittleEndian, b.bits)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temp bloom filter file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "unable to write bloom filter file")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "unable to sync bloom filter file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "unable to close bloom filter file")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "unable to replace bloom filter file")
+}
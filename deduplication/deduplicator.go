@@ -0,0 +1,215 @@
+package deduplication
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// minimum interval between saving bloom filter file while records are committed
+const bloomFilterSaveInterval = 10 * time.Second
+
+// deduplication result of a datasource in current run
+type Summary struct {
+	Datasource string `json:"datasource"`
+	// records passed to loading
+	Unique int64 `json:"unique"`
+	// records dropped as duplicate of record in memory window or bloom filter
+	Duplicates int64 `json:"duplicates"`
+}
+
+// deduplication stage between validation and loading
+// key of record is checked against recently seen keys in memory window and keys of loaded records in bloom filter
+// keys are only added to bloom filter after records are committed, so records failed to load are not dropped after restart
+type Deduplicator struct {
+	enable bool
+	key    KeyFunc
+	path   string
+	logger utils.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	window    *window
+	bloom     *bloomFilter
+	dirty     bool
+	savedAt   time.Time
+	summaries map[string]*Summary
+}
+
+// create deduplicator and load bloom filter file if exist
+// every record is passed if deduplication is disabled
+func NewDeduplicator(c config.DeduplicationConfig, schemas transformation.Schemas, logger utils.Logger) (*Deduplicator, error) {
+	d := &Deduplicator{
+		enable:    c.Enable,
+		path:      c.BloomFilterPath,
+		logger:    logger,
+		now:       time.Now,
+		summaries: make(map[string]*Summary),
+	}
+	if !c.Enable {
+		return d, nil
+	}
+
+	key, err := CompileKey(c.Fields, schemas)
+	if err != nil {
+		return nil, err
+	}
+	d.key = key
+	d.window = newWindow(c.WindowSize, time.Duration(c.TTL)*time.Second)
+
+	if c.BloomFilterPath != "" {
+		d.bloom = newBloomFilter(c.BloomFilterCapacity, c.BloomFilterFalsePositiveRate)
+		loaded, err := d.bloom.load(c.BloomFilterPath)
+		if err != nil {
+			return nil, err
+		}
+		if loaded {
+			logger.Infof("bloom filter loaded from %s", c.BloomFilterPath)
+		}
+		d.savedAt = d.now()
+	}
+
+	return d, nil
+}
+
+// drop duplicated records from input channel and pass unique records to output channel
+// output channel is closed after input channel is closed
+func (d *Deduplicator) Run(ctx context.Context, in <-chan transformation.Record, out chan<- transformation.Record) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case record, ok := <-in:
+			if !ok {
+				close(out)
+				return
+			}
+			if d.IsDuplicate(record) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+	}
+}
+
+// return if record is duplicate of recently seen record or loaded record, key of record is recorded as seen
+// update and delete are never duplicate as the same change may be applied again (e.g. field changed back)
+func (d *Deduplicator) IsDuplicate(record transformation.Record) bool {
+	if !d.enable || !record.IsInsert() {
+		return false
+	}
+
+	key := d.key(record)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// key is always added to window so repeated duplicates keep it as recently seen
+	inWindow := d.window.seen(key, d.now())
+	inBloom := !inWindow && d.bloom != nil && d.bloom.contains(key)
+
+	summary, ok := d.summaries[record.Metadata.Datasource]
+	if !ok {
+		summary = &Summary{Datasource: record.Metadata.Datasource}
+		d.summaries[record.Metadata.Datasource] = summary
+	}
+	if !inWindow && !inBloom {
+		summary.Unique++
+		return false
+	}
+
+	summary.Duplicates++
+	logger := d.logger.WithFields(utils.Fields{
+		utils.FieldDatasource: record.Metadata.Datasource,
+		utils.FieldRecordID:   record.Metadata.CorrelationID,
+	})
+	if inWindow {
+		logger.Debugf("record dropped as duplicate of recently seen record")
+	} else {
+		logger.Debugf("record dropped as duplicate of loaded record (bloom filter)")
+	}
+	return true
+}
+
+// add keys of committed records to bloom filter, bloom filter file is saved periodically
+// failure of saving is only logged as keys are saved again by next save
+func (d *Deduplicator) Commit(records []transformation.Record) {
+	if !d.enable || d.bloom == nil || len(records) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, record := range records {
+		if record.IsInsert() {
+			d.bloom.add(d.key(record))
+		}
+	}
+	d.dirty = true
+
+	if d.now().Sub(d.savedAt) >= bloomFilterSaveInterval {
+		if err := d.save(); err != nil {
+			d.logger.Errorf("unable to save bloom filter %v", err)
+		}
+	}
+}
+
+// save bloom filter file if keys are added since last save
+func (d *Deduplicator) Close() error {
+	if !d.enable || d.bloom == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.save()
+}
+
+func (d *Deduplicator) save() error {
+	if !d.dirty {
+		return nil
+	}
+	d.savedAt = d.now()
+	if err := d.bloom.save(d.path); err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
+
+// deduplication summary of each datasource sorted by datasource name
+func (d *Deduplicator) Summaries() []Summary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(d.summaries))
+	for _, summary := range d.summaries {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Datasource < summaries[j].Datasource })
+
+	return summaries
+}
+
+// log deduplication summary of each datasource
+func (d *Deduplicator) LogSummary() {
+	for _, summary := range d.Summaries() {
+		d.logger.WithFields(utils.Fields{utils.FieldDatasource: summary.Datasource}).Infof(
+			"deduplication summary: %d unique, %d duplicates, %d keys in memory window",
+			summary.Unique, summary.Duplicates, d.windowLen())
+	}
+}
+
+func (d *Deduplicator) windowLen() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.window.len()
+}
@@ -0,0 +1,252 @@
+package deduplication_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/deduplication"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	type testcase struct {
+		testcase           string
+		config             config.DeduplicationConfig
+		records            []transformation.Record
+		expectedDuplicates []bool
+		expectedSummary    []deduplication.Summary
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Content hash ignore metadata",
+			config:   config.DeduplicationConfig{Enable: true, WindowSize: 10},
+			records: []transformation.Record{
+				record("a", "1", "John", "Doe", "Boston"),
+				record("a", "2", "John", "Doe", "Boston"),
+				record("b", "3", "John", "Doe", "Austin"),
+			},
+			expectedDuplicates: []bool{false, true, false},
+			expectedSummary:    []deduplication.Summary{{Datasource: "a", Unique: 1, Duplicates: 1}, {Datasource: "b", Unique: 1}},
+		},
+		{
+			testcase: "Configured fields",
+			config:   config.DeduplicationConfig{Enable: true, Fields: []string{"FirstName", "LastName", "DateOfBirth"}, WindowSize: 10},
+			records: []transformation.Record{
+				record("a", "1", "John", "Doe", "Boston"),
+				record("a", "2", "John", "Doe", "Austin"),
+				record("a", "3", "John", "Smith", "Boston"),
+			},
+			expectedDuplicates: []bool{false, true, false},
+			expectedSummary:    []deduplication.Summary{{Datasource: "a", Unique: 2, Duplicates: 1}},
+		},
+		{
+			testcase: "Field values are separated",
+			config:   config.DeduplicationConfig{Enable: true, Fields: []string{"FirstName", "LastName"}, WindowSize: 10},
+			records: []transformation.Record{
+				record("a", "1", "Jo", "hnDoe", "Boston"),
+				record("a", "2", "John", "Doe", "Boston"),
+			},
+			expectedDuplicates: []bool{false, false},
+			expectedSummary:    []deduplication.Summary{{Datasource: "a", Unique: 2}},
+		},
+		{
+			testcase: "Least recently seen key evicted",
+			config:   config.DeduplicationConfig{Enable: true, WindowSize: 2},
+			records: []transformation.Record{
+				record("a", "1", "John", "Doe", "Boston"),
+				record("a", "2", "Jane", "Doe", "Boston"),
+				record("a", "3", "John", "Doe", "Boston"),
+				record("a", "4", "Mary", "Doe", "Boston"),
+				record("a", "5", "Jane", "Doe", "Boston"),
+				record("a", "6", "Mary", "Doe", "Boston"),
+			},
+			expectedDuplicates: []bool{false, false, true, false, false, true},
+			expectedSummary:    []deduplication.Summary{{Datasource: "a", Unique: 4, Duplicates: 2}},
+		},
+		{
+			testcase: "Update and delete",
+			config:   config.DeduplicationConfig{Enable: true, WindowSize: 10},
+			records: []transformation.Record{
+				record("a", "1", "John", "Doe", "Boston"),
+				change(record("a", "2", "John", "Doe", "Boston"), transformation.OperationUpdate),
+				change(record("a", "3", "John", "Doe", "Boston"), transformation.OperationDelete),
+				change(record("a", "4", "John", "Doe", "Boston"), transformation.OperationInsert),
+			},
+			expectedDuplicates: []bool{false, false, false, true},
+			expectedSummary:    []deduplication.Summary{{Datasource: "a", Unique: 1, Duplicates: 1}},
+		},
+		{
+			testcase: "Disabled",
+			config:   config.DeduplicationConfig{Enable: false},
+			records: []transformation.Record{
+				record("a", "1", "John", "Doe", "Boston"),
+				record("a", "2", "John", "Doe", "Boston"),
+			},
+			expectedDuplicates: []bool{false, false},
+			expectedSummary:    []deduplication.Summary{},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			d, err := deduplication.NewDeduplicator(tc.config, schemas, newLogger())
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+
+			duplicates := make([]bool, 0, len(tc.records))
+			for _, r := range tc.records {
+				duplicates = append(duplicates, d.IsDuplicate(r))
+			}
+			if !reflect.DeepEqual(duplicates, tc.expectedDuplicates) {
+				t.Errorf("expected %v, but got %v", tc.expectedDuplicates, duplicates)
+			}
+			if summary := d.Summaries(); !reflect.DeepEqual(summary, tc.expectedSummary) {
+				t.Errorf("expected %+v, but got %+v", tc.expectedSummary, summary)
+			}
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	d, err := deduplication.NewDeduplicator(config.DeduplicationConfig{Enable: true, WindowSize: 10, TTL: 1}, schemas, newLogger())
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	r := record("a", "1", "John", "Doe", "Boston")
+	if d.IsDuplicate(r) {
+		t.Errorf("expected %v, but got %v", false, true)
+	}
+	if !d.IsDuplicate(r) {
+		t.Errorf("expected %v, but got %v", true, false)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if d.IsDuplicate(r) {
+		t.Errorf("expected expired key not duplicate, but got duplicate")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	c := config.DeduplicationConfig{
+		Enable:                       true,
+		WindowSize:                   10,
+		BloomFilterPath:              filepath.Join(t.TempDir(), "bloom.bin"),
+		BloomFilterCapacity:          1000,
+		BloomFilterFalsePositiveRate: 0.001,
+	}
+	john := record("a", "1", "John", "Doe", "Boston")
+	jane := record("a", "2", "Jane", "Doe", "Boston")
+
+	// key is not added to bloom filter before record is committed
+	d, err := deduplication.NewDeduplicator(c, schemas, newLogger())
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	d.IsDuplicate(john)
+	d.IsDuplicate(jane)
+	d.Commit([]transformation.Record{john})
+	if err := d.Close(); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	// committed key is loaded from bloom filter file after restart
+	d, err = deduplication.NewDeduplicator(c, schemas, newLogger())
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if !d.IsDuplicate(john) {
+		t.Errorf("expected committed record duplicate, but got unique")
+	}
+	if d.IsDuplicate(jane) {
+		t.Errorf("expected uncommitted record unique, but got duplicate")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	// bloom filter file created with different size can't be loaded
+	c.BloomFilterCapacity = 2000
+	if _, err := deduplication.NewDeduplicator(c, schemas, newLogger()); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+func TestCompileKey(t *testing.T) {
+	_, err := deduplication.CompileKey([]string{"FirstName", "Unknown", "Metadata.Datasource"}, schemas)
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected validation error, but got %v", err)
+	}
+	if len(validationErr.Problems) != 2 {
+		t.Errorf("expected %v, but got %v", 2, validationErr.Problems)
+	}
+}
+
+func TestRun(t *testing.T) {
+	d, err := deduplication.NewDeduplicator(config.DeduplicationConfig{Enable: true, WindowSize: 10}, schemas, newLogger())
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	in := make(chan transformation.Record, 3)
+	out := make(chan transformation.Record, 3)
+	in <- record("a", "1", "John", "Doe", "Boston")
+	in <- record("a", "2", "John", "Doe", "Boston")
+	in <- record("a", "3", "Jane", "Doe", "Boston")
+	close(in)
+	d.Run(context.Background(), in, out)
+
+	ids := make([]string, 0)
+	for r := range out {
+		ids = append(ids, r.Metadata.CorrelationID)
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "3"}) {
+		t.Errorf("expected %v, but got %v", []string{"1", "3"}, ids)
+	}
+}
+
+var schemas = func() transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{config.UserEntity()})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+func record(datasource string, id string, firstName string, lastName string, city string) transformation.Record {
+	record := transformation.Record{
+		Metadata: transformation.RecordMetadata{Datasource: datasource, CorrelationID: id},
+		Entity:   config.DefaultEntity,
+		Fields: map[string]interface{}{
+			"FirstName":    firstName,
+			"LastName":     lastName,
+			"DateOfBirth":  time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC),
+			"Address.City": city,
+		},
+	}
+	if err := schemas.Conform(&record); err != nil {
+		panic(err)
+	}
+	return record
+}
+
+// record with operation
+func change(record transformation.Record, operation string) transformation.Record {
+	record.Operation = operation
+	return record
+}
+
+func newLogger() utils.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return utils.NewLogrusLogger(logger)
+}
@@ -0,0 +1,79 @@
+package deduplication
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// identity of record, records with same key are duplicates
+type Key [sha256.Size]byte
+
+// compute key of record
+type KeyFunc func(record transformation.Record) Key
+
+// key of configured fields, or content hash of every field except metadata if fields is empty
+// entity is part of key, so records of different entities are never duplicates
+// every problem (unknown field, metadata field) is collected into a single error
+func CompileKey(fields []string, schemas transformation.Schemas) (KeyFunc, error) {
+	if len(fields) == 0 {
+		return contentKey, nil
+	}
+
+	problems := make([]string, 0)
+	names := make([]string, 0, len(fields))
+	for i, field := range fields {
+		if first, _, _ := strings.Cut(field, "."); strings.EqualFold(first, "Metadata") {
+			problems = append(problems, fmt.Sprintf("Deduplication.Fields[%d] (%s) metadata field is not supported", i, field))
+			continue
+		}
+		f, err := schemas.Field(field)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Deduplication.Fields[%d] %v", i, err))
+			continue
+		}
+		names = append(names, f.Name)
+	}
+	if len(problems) != 0 {
+		return nil, &config.ValidationError{Problems: problems}
+	}
+
+	return func(record transformation.Record) Key {
+		h := sha256.New()
+		h.Write([]byte(record.Entity))
+		// separator so that ("ab", "c") and ("a", "bc") have different key
+		h.Write([]byte{0})
+		for _, name := range names {
+			h.Write([]byte(format(record.Get(name))))
+			h.Write([]byte{0})
+		}
+		var key Key
+		h.Sum(key[:0])
+		return key
+	}, nil
+}
+
+// hash of record content, metadata is different in every extraction so it is excluded
+func contentKey(record transformation.Record) Key {
+	// map keys are marshaled in sorted order
+	content, _ := json.Marshal(record.Fields)
+	h := sha256.New()
+	h.Write([]byte(record.Entity))
+	h.Write([]byte{0})
+	h.Write(content)
+	var key Key
+	h.Sum(key[:0])
+	return key
+}
+
+func format(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,60 @@
+package deduplication
+
+import (
+	"container/list"
+	"time"
+)
+
+// bounded in-memory window of recently seen keys
+// least recently seen key is evicted when window is full, and key expires after ttl (never if ttl is 0)
+type window struct {
+	size  int
+	ttl   time.Duration
+	keys  map[Key]*list.Element
+	order *list.List
+}
+
+type windowEntry struct {
+	key  Key
+	seen time.Time
+}
+
+func newWindow(size int, ttl time.Duration) *window {
+	return &window{
+		size:  size,
+		ttl:   ttl,
+		keys:  make(map[Key]*list.Element),
+		order: list.New(),
+	}
+}
+
+// return if key is seen within window and record key as seen now
+func (w *window) seen(key Key, now time.Time) bool {
+	if element, ok := w.keys[key]; ok {
+		entry := element.Value.(*windowEntry)
+		expired := w.ttl > 0 && now.Sub(entry.seen) >= w.ttl
+		entry.seen = now
+		w.order.MoveToFront(element)
+		return !expired
+	}
+
+	w.keys[key] = w.order.PushFront(&windowEntry{key: key, seen: now})
+	w.evict(now)
+	return false
+}
+
+// remove expired keys and keys exceeding window size from least recently seen
+func (w *window) evict(now time.Time) {
+	for back := w.order.Back(); back != nil; back = w.order.Back() {
+		entry := back.Value.(*windowEntry)
+		if w.order.Len() <= w.size && (w.ttl == 0 || now.Sub(entry.seen) < w.ttl) {
+			return
+		}
+		w.order.Remove(back)
+		delete(w.keys, entry.key)
+	}
+}
+
+func (w *window) len() int {
+	return w.order.Len()
+}
@@ -3,15 +3,84 @@ create table at init
 */
 CREATE TABLE users (
   user_id serial PRIMARY KEY,
-  first_name VARCHAR(50),
-  last_name VARCHAR(50),
-  date_of_birth TIMESTAMP,
-  city VARCHAR(50),
-  street_name VARCHAR(50),
-  street_address VARCHAR(50),
-  zip_code VARCHAR(50),
-  state VARCHAR(50),
-  country VARCHAR(50),
+  first_name VARCHAR(255),
+  last_name VARCHAR(255),
+  date_of_birth DATE,
+  city VARCHAR(255),
+  street_name VARCHAR(255),
+  street_address VARCHAR(255),
+  zip_code VARCHAR(255),
+  state VARCHAR(255),
+  state_code VARCHAR(10),
+  country VARCHAR(255),
+  country_code CHAR(2),
   latitude double precision,
-  longitude double precision
-);
\ No newline at end of file
+  longitude double precision,
+  geo_country_code CHAR(2),
+  geo_check VARCHAR(10),
+  timezone VARCHAR(50),
+  geohash VARCHAR(12)
+);
+
+/*
+profiles of random-data-api-v2 transformer with child employments and subscriptions (joined by profile uid)
+credit card number is not kept, only its token and last 4 digits
+*/
+CREATE TABLE profiles (
+  profile_id serial PRIMARY KEY,
+  uid VARCHAR(36),
+  api_id BIGINT,
+  username VARCHAR(255),
+  email VARCHAR(255),
+  first_name VARCHAR(255),
+  last_name VARCHAR(255),
+  gender VARCHAR(50),
+  phone_number VARCHAR(50),
+  social_insurance_number VARCHAR(50),
+  avatar TEXT,
+  date_of_birth DATE,
+  city VARCHAR(255),
+  street_name VARCHAR(255),
+  street_address VARCHAR(255),
+  zip_code VARCHAR(255),
+  state VARCHAR(255),
+  state_code VARCHAR(10),
+  country VARCHAR(255),
+  country_code CHAR(2),
+  latitude double precision,
+  longitude double precision,
+  geo_country_code CHAR(2),
+  geo_check VARCHAR(10),
+  timezone VARCHAR(50),
+  geohash VARCHAR(12),
+  credit_card_token VARCHAR(68),
+  credit_card_last4 CHAR(4)
+);
+CREATE INDEX profiles_uid ON profiles (uid);
+
+CREATE TABLE employments (
+  employment_id serial PRIMARY KEY,
+  profile_uid VARCHAR(36),
+  title VARCHAR(255),
+  key_skill VARCHAR(255)
+);
+CREATE INDEX employments_profile_uid ON employments (profile_uid);
+
+CREATE TABLE subscriptions (
+  subscription_id serial PRIMARY KEY,
+  profile_uid VARCHAR(36),
+  plan VARCHAR(50),
+  status VARCHAR(50),
+  payment_method VARCHAR(50),
+  term VARCHAR(50)
+);
+CREATE INDEX subscriptions_profile_uid ON subscriptions (profile_uid);
+
+/*
+extraction position of each datasource (only updated after records are committed)
+*/
+CREATE TABLE checkpoints (
+  datasource VARCHAR(100) PRIMARY KEY,
+  position TEXT NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
@@ -1,25 +1,90 @@
 package extraction
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strconv"
 	"sync"
 
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
 	"github.com/awcjack/ETL-sample/transformation"
 	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
 )
 
 type FileExtraction struct {
-	logger utils.Logger
+	logger     utils.Logger
+	checkpoint checkpoint.Store
 }
 
-func NewFileExtraction(logger utils.Logger) *HttpExtraction {
-	return &HttpExtraction{
-		logger: logger,
+func NewFileExtraction(logger utils.Logger, checkpoint checkpoint.Store) *FileExtraction {
+	return &FileExtraction{
+		logger:     logger,
+		checkpoint: checkpoint,
 	}
 }
 
-func (h *FileExtraction) Extract(path string, transformer func(data []byte) (transformation.TransformedData, error), dataPipeline chan<- transformation.TransformedData, wg *sync.WaitGroup) error {
-	// to be implement
-	h.logger.Errorf("Not yet implemented")
-	wg.Done()
-	return errorNotImplemented
+// extract function to read newline delimited records from file path
+// resume from the file position of last checkpoint
+// transform data using transformer function in params
+// pass data to data channel
+// stop without error when context is cancelled
+func (f *FileExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := f.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("File source: %s", datasource.Source)
+
+	defer wg.Done()
+
+	file, err := os.Open(datasource.Source)
+	if err != nil {
+		logger.Errorf("unable to open file %v", err)
+		return err
+	}
+	defer file.Close()
+
+	var offset int64
+	position, err := f.checkpoint.Load(ctx, datasource.Name)
+	if err != nil {
+		logger.Errorf("unable to load checkpoint %v", err)
+		return err
+	}
+	if position != "" {
+		offset, err = strconv.ParseInt(position, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid checkpoint %s", position)
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek to checkpoint")
+		}
+	}
+	logger.Infof("File source start from offset %d", offset)
+
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		offset += int64(len(line))
+
+		// skip empty line
+		if rawData := bytes.TrimSpace(line); len(rawData) != 0 {
+			err = transformRecord(ctx, logger, datasource, rawData, strconv.FormatInt(offset, 10), transformer, dataPipeline)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			logger.Infof("File source reached end of file at offset %d", offset)
+			return nil
+		}
+	}
 }
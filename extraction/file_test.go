@@ -0,0 +1,181 @@
+package extraction_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestFileExtract(t *testing.T) {
+	type testcase struct {
+		testcase          string
+		content           string
+		checkpoint        string
+		expectedNames     []string
+		expectedPositions []string
+		// index of raw line of each record, records of same line share correlation id
+		expectedLines []int
+	}
+
+	testcases := []testcase{
+		{
+			testcase:          "From beginning",
+			content:           "alice\n\nbob\ncarol",
+			checkpoint:        "",
+			expectedNames:     []string{"alice", "bob", "carol"},
+			expectedPositions: []string{"6", "11", "16"},
+			expectedLines:     []int{0, 2, 3},
+		},
+		{
+			testcase:          "Resume from checkpoint",
+			content:           "alice\n\nbob\ncarol",
+			checkpoint:        "6",
+			expectedNames:     []string{"bob", "carol"},
+			expectedPositions: []string{"11", "16"},
+			expectedLines:     []int{0, 1},
+		},
+		{
+			testcase:          "Skip dropped record",
+			content:           "alice\ndropped\nbob",
+			checkpoint:        "",
+			expectedNames:     []string{"alice", "bob"},
+			expectedPositions: []string{"6", "17"},
+			expectedLines:     []int{0, 2},
+		},
+		{
+			testcase:          "Position on last record of line",
+			content:           "alice\nbob,carol\ndave",
+			checkpoint:        "",
+			expectedNames:     []string{"alice", "bob", "carol", "dave"},
+			expectedPositions: []string{"6", "", "16", "20"},
+			expectedLines:     []int{0, 1, 1, 2},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newFileExtractionDependencies(t, tc.content)
+			if tc.checkpoint != "" {
+				dep.checkpoint.Save(context.Background(), dep.datasource.Name, tc.checkpoint)
+			}
+
+			dataChan := make(chan transformation.Record, 10)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			err := dep.fileExtraction.Extract(context.Background(), dep.datasource, nameTransformer, dataChan, &wg)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			close(dataChan)
+
+			i := 0
+			ids := make(map[int]string)
+			for data := range dataChan {
+				if data.String("FirstName") != tc.expectedNames[i] {
+					t.Errorf("expected %v, but got %v", tc.expectedNames[i], data.String("FirstName"))
+				}
+				if data.Metadata.Position != tc.expectedPositions[i] {
+					t.Errorf("expected position %v, but got %v", tc.expectedPositions[i], data.Metadata.Position)
+				}
+				if data.Metadata.Datasource != dep.datasource.Name || data.Metadata.CorrelationID == "" {
+					t.Errorf("expected metadata to be attached, but got %+v", data.Metadata)
+				}
+				if id, ok := ids[tc.expectedLines[i]]; ok && id != data.Metadata.CorrelationID {
+					t.Errorf("expected correlation id %v, but got %v", id, data.Metadata.CorrelationID)
+				}
+				ids[tc.expectedLines[i]] = data.Metadata.CorrelationID
+				i++
+			}
+			if i != len(tc.expectedNames) {
+				t.Errorf("expected %v records, but got %v", len(tc.expectedNames), i)
+			}
+			// records of different lines get different correlation ids
+			distinct := make(map[string]bool)
+			for _, id := range ids {
+				distinct[id] = true
+			}
+			if len(distinct) != len(ids) {
+				t.Errorf("expected %v correlation ids, but got %v", len(ids), len(distinct))
+			}
+		})
+	}
+}
+
+func TestFileExtractTransformerLogger(t *testing.T) {
+	dep := newFileExtractionDependencies(t, "alice\nbob")
+	logger, hook := logrustest.NewNullLogger()
+	fileExtraction := extraction.NewFileExtraction(utils.NewLogrusLogger(logger), dep.checkpoint)
+	// transformer log the raw data by logger passed by extraction
+	transformer := func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+		logger.Infof("transforming %s", strings.TrimSpace(string(data)))
+		return nameTransformer(logger, data)
+	}
+
+	dataChan := make(chan transformation.Record, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if err := fileExtraction.Extract(context.Background(), dep.datasource, transformer, dataChan, &wg); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	close(dataChan)
+
+	ids := make(map[string]string)
+	for _, entry := range hook.AllEntries() {
+		if name, ok := strings.CutPrefix(entry.Message, "transforming "); ok {
+			ids[name], _ = entry.Data[utils.FieldRecordID].(string)
+		}
+	}
+	for record := range dataChan {
+		name := record.String("FirstName")
+		if ids[name] != record.Metadata.CorrelationID {
+			t.Errorf("expected transformer log of %v with record id %v, but got %v", name, record.Metadata.CorrelationID, ids[name])
+		}
+	}
+}
+
+// record named "dropped" is transformed to no record, comma separated names are transformed to one record of each name
+func nameTransformer(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+	var records []transformation.Record
+	for _, name := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		if name == "dropped" {
+			continue
+		}
+		records = append(records, transformation.Record{Fields: map[string]interface{}{"FirstName": name}})
+	}
+	return records, nil
+}
+
+type fileExtractionDependencies struct {
+	datasource     config.DataSourceConfig
+	checkpoint     checkpoint.Store
+	fileExtraction *extraction.FileExtraction
+}
+
+func newFileExtractionDependencies(t *testing.T, content string) fileExtractionDependencies {
+	path := filepath.Join(t.TempDir(), "users.jsonl")
+	os.WriteFile(path, []byte(content), 0o644)
+
+	store := checkpoint.NewMemoryStore()
+	logger := utils.NewLogrusLogger(logrus.StandardLogger())
+
+	return fileExtractionDependencies{
+		datasource: config.DataSourceConfig{
+			Name:   "file",
+			Type:   "file",
+			Source: path,
+		},
+		checkpoint:     store,
+		fileExtraction: extraction.NewFileExtraction(logger, store),
+	}
+}
@@ -1,43 +1,96 @@
 package extraction
 
 import (
+	"context"
 	"io"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
 	"github.com/awcjack/ETL-sample/transformation"
 	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
 )
 
+// placeholder in http source url which will be replaced by page number for paginated api
+// e.g. https://example.com/api/users?page={page}
+const pagePlaceholder = "{page}"
+
 type HttpExtraction struct {
-	logger utils.Logger
+	logger     utils.Logger
+	checkpoint checkpoint.Store
 }
 
-func NewHttpExtraction(logger utils.Logger) *HttpExtraction {
+func NewHttpExtraction(logger utils.Logger, checkpoint checkpoint.Store) *HttpExtraction {
 	return &HttpExtraction{
-		logger: logger,
+		logger:     logger,
+		checkpoint: checkpoint,
 	}
 }
 
-// extract function to run GET request to url
+// extract function to run GET request to datasource url repeatedly
 // transform data using transformer function in params
 // pass data to data channel
-func (h *HttpExtraction) Extract(url string, transformer func(data []byte) (transformation.TransformedData, error), dataPipeline chan<- transformation.TransformedData, wg *sync.WaitGroup) error {
-	h.logger.Debugf("HTTP source: ", url)
+// paginated source (url contain {page}) resume from the page after last checkpoint and stop when page not found
+// stop without error when context is cancelled
+func (h *HttpExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := h.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("HTTP source: %s", config.RedactSecrets(datasource.Source))
 
 	defer wg.Done()
 
+	paginated := strings.Contains(datasource.Source, pagePlaceholder)
+	page := 1
+	if paginated {
+		position, err := h.checkpoint.Load(ctx, datasource.Name)
+		if err != nil {
+			logger.Errorf("unable to load checkpoint %v", err)
+			return err
+		}
+		if position != "" {
+			lastPage, err := strconv.Atoi(position)
+			if err != nil {
+				return errors.Wrapf(err, "invalid checkpoint %s", position)
+			}
+			page = lastPage + 1
+		}
+		logger.Infof("HTTP source start from page %d", page)
+	}
+
 	// random seed based on current time
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	for {
+		url := datasource.Source
+		position := ""
+		if paginated {
+			position = strconv.Itoa(page)
+			url = strings.ReplaceAll(url, pagePlaceholder, position)
+		}
+
 		// fetch data from data source (url)
-		resp, err := http.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return redactError(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
 
 		if err != nil {
-			return err
+			if ctx.Err() != nil {
+				return nil
+			}
+			return redactError(err)
+		}
+
+		if paginated && resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			logger.Infof("HTTP source reached last page %d", page-1)
+			return nil
 		}
 
 		// read response body
@@ -50,18 +103,35 @@ func (h *HttpExtraction) Extract(url string, transformer func(data []byte) (tran
 		// close response body to release memory
 		resp.Body.Close()
 
-		// transform data based on transformer function from params
-		transformedData, err := transformer(body)
+		err = transformRecord(ctx, logger, datasource, body, position, transformer, dataPipeline)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			return err
 		}
+		page++
 
-		h.logger.Debugf("inserted data to channel", transformedData)
-		// push transformed data to channel for storing data to storage
-		dataPipeline <- transformedData
+		// non paginated source only has a single response to extract
+		if datasource.Once && !paginated {
+			return nil
+		}
 
 		// random delay from 250ms to 750ms
 		randomDelay := r.Float32()*500 + 250
-		time.Sleep(time.Duration(randomDelay) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Duration(randomDelay) * time.Millisecond):
+		}
+	}
+}
+
+// error with resolved secrets masked, as http client and websocket errors contain source url
+func redactError(err error) error {
+	message := config.RedactSecrets(err.Error())
+	if message == err.Error() {
+		return err
 	}
+	return errors.New(message)
 }
@@ -1,11 +1,14 @@
 package extraction
 
 import (
+	"context"
 	"sync"
 
+	"github.com/awcjack/ETL-sample/config"
 	"github.com/awcjack/ETL-sample/transformation"
 )
 
 type DataSourceExtration interface {
-	Extract(source string, transformer func(data []byte) (transformation.TransformedData, error), dataPipeline chan<- transformation.TransformedData, wg *sync.WaitGroup) error
+	// extract data until source is exhausted or context is cancelled
+	Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error
 }
@@ -0,0 +1,221 @@
+package extraction
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+const (
+	// interval of committing checkpointed offsets to consumer group
+	kafkaCommitInterval = 5 * time.Second
+	// header of record published by kafka sink with operation of the record
+	kafkaOperationHeader = "operation"
+)
+
+// consumer of kafka topic in consumer group, partitions of topic are shared by instances in same group
+type KafkaExtraction struct {
+	logger     utils.Logger
+	checkpoint checkpoint.Store
+}
+
+func NewKafkaExtraction(logger utils.Logger, checkpoint checkpoint.Store) *KafkaExtraction {
+	return &KafkaExtraction{
+		logger:     logger,
+		checkpoint: checkpoint,
+	}
+}
+
+// partitions assigned to the consumer
+type kafkaState struct {
+	mu sync.Mutex
+	// assigned partitions, only their offsets are committed
+	assigned map[int32]bool
+}
+
+// extract function to consume messages of Topic from offsets committed to consumer group (start of partition if group has no offset)
+// each message value is transformed using transformer function in params, and the records get operation of "operation" header
+// position of record is the next offset of its partition saved to checkpoint of the partition, so instances in same group
+// don't overwrite checkpoint of each other. checkpointed offsets of assigned partitions are committed to consumer group
+// periodically and when partitions are revoked, so messages are delivered at least once
+// stop without error when context is cancelled
+func (k *KafkaExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := k.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("Kafka source: %s", datasource.Source)
+
+	defer wg.Done()
+
+	state := &kafkaState{
+		assigned: make(map[int32]bool),
+	}
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(utils.SplitBrokers(datasource.Source)...),
+		kgo.ConsumerGroup(datasource.Group),
+		kgo.ConsumeTopics(datasource.Topic),
+		kgo.DisableAutoCommit(),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.OnPartitionsAssigned(func(_ context.Context, _ *kgo.Client, assigned map[string][]int32) {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			for _, partition := range assigned[datasource.Topic] {
+				state.assigned[partition] = true
+			}
+			logger.Infof("Kafka source assigned partitions %v of %s", assigned[datasource.Topic], datasource.Topic)
+		}),
+		// loaded records of revoked partitions are committed before other consumer in group consumes them
+		kgo.OnPartitionsRevoked(func(ctx context.Context, client *kgo.Client, revoked map[string][]int32) {
+			if err := k.commit(ctx, client, datasource, state); err != nil {
+				logger.Warningf("unable to commit offsets of revoked partitions %v", err)
+			}
+			state.drop(revoked[datasource.Topic])
+			logger.Infof("Kafka source revoked partitions %v of %s", revoked[datasource.Topic], datasource.Topic)
+		}),
+		// lost partitions may be consumed by other consumer already, so their offsets are not committed
+		kgo.OnPartitionsLost(func(_ context.Context, _ *kgo.Client, lost map[string][]int32) {
+			state.drop(lost[datasource.Topic])
+			logger.Warningf("Kafka source lost partitions %v of %s", lost[datasource.Topic], datasource.Topic)
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "unable to create kafka consumer")
+	}
+	defer client.Close()
+
+	logger.Infof("Kafka source consuming %s in group %s", datasource.Topic, datasource.Group)
+	err = k.consume(ctx, logger, client, datasource, state, transformer, dataPipeline)
+
+	// offsets loaded before stopping are committed with new context as ctx may be cancelled
+	commitCtx, cancel := context.WithTimeout(context.Background(), kafkaCommitInterval)
+	defer cancel()
+	if commitErr := k.commit(commitCtx, client, datasource, state); commitErr != nil {
+		logger.Warningf("unable to commit offsets %v", commitErr)
+	}
+	return err
+}
+
+// poll messages until context is cancelled, message which can't be transformed is not recovered
+func (k *KafkaExtraction) consume(ctx context.Context, logger utils.Logger, client *kgo.Client, datasource config.DataSourceConfig, state *kafkaState, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record) error {
+	nextCommit := time.Now().Add(kafkaCommitInterval)
+	for {
+		if !time.Now().Before(nextCommit) {
+			if err := k.commit(ctx, client, datasource, state); err != nil && ctx.Err() == nil {
+				logger.Warningf("unable to commit offsets %v", err)
+			}
+			nextCommit = time.Now().Add(kafkaCommitInterval)
+		}
+
+		pollCtx, cancel := context.WithDeadline(ctx, nextCommit)
+		fetches := client.PollFetches(pollCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				logger.Errorf("unable to fetch partition %d of %s %v", partition, topic, err)
+			}
+		})
+
+		var err error
+		fetches.EachRecord(func(message *kgo.Record) {
+			if err != nil {
+				return
+			}
+			operation := ""
+			for _, header := range message.Headers {
+				if header.Key == kafkaOperationHeader {
+					operation = string(header.Value)
+				}
+			}
+			operationTransformer := func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+				records, err := transformer(logger, data)
+				for i := range records {
+					records[i].Operation = operation
+				}
+				return records, err
+			}
+			err = transformCheckpointedRecord(ctx, logger, datasource, message.Value, kafkaCheckpoint(datasource, message.Partition), strconv.FormatInt(message.Offset+1, 10), operationTransformer, dataPipeline)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// commit checkpointed offsets of assigned partitions to consumer group, offset is committed only if it is after committed offset
+// so stale checkpoint of reassigned partition is not committed
+func (k *KafkaExtraction) commit(ctx context.Context, client *kgo.Client, datasource config.DataSourceConfig, state *kafkaState) error {
+	state.mu.Lock()
+	partitions := make([]int32, 0, len(state.assigned))
+	for partition := range state.assigned {
+		partitions = append(partitions, partition)
+	}
+	state.mu.Unlock()
+
+	committed := client.CommittedOffsets()[datasource.Topic]
+	uncommitted := make(map[int32]kgo.EpochOffset)
+	for _, partition := range partitions {
+		position, err := k.checkpoint.Load(ctx, kafkaCheckpoint(datasource, partition))
+		if err != nil {
+			return err
+		}
+		if position == "" {
+			continue
+		}
+		offset, err := strconv.ParseInt(position, 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid kafka position %q of partition %d", position, partition)
+		}
+		if c, ok := committed[partition]; ok && c.Offset >= offset {
+			continue
+		}
+		uncommitted[partition] = kgo.EpochOffset{Epoch: -1, Offset: offset}
+	}
+	if len(uncommitted) == 0 {
+		return nil
+	}
+
+	var commitErr error
+	client.CommitOffsetsSync(ctx, map[string]map[int32]kgo.EpochOffset{datasource.Topic: uncommitted}, func(_ *kgo.Client, _ *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, err error) {
+		if err != nil {
+			commitErr = err
+			return
+		}
+		for _, topic := range resp.Topics {
+			for _, partition := range topic.Partitions {
+				if err := kerr.ErrorForCode(partition.ErrorCode); err != nil {
+					commitErr = errors.Wrapf(err, "unable to commit offset of partition %d", partition.Partition)
+				}
+			}
+		}
+	})
+	return commitErr
+}
+
+// forget partitions which are no longer assigned
+func (s *kafkaState) drop(partitions []int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, partition := range partitions {
+		delete(s.assigned, partition)
+	}
+}
+
+// name of checkpoint of partition of topic (e.g. orders-kafka/orders/0)
+func kafkaCheckpoint(datasource config.DataSourceConfig, partition int32) string {
+	return fmt.Sprintf("%s/%s/%d", datasource.Name, datasource.Topic, partition)
+}
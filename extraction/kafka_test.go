@@ -0,0 +1,344 @@
+package extraction_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestKafkaExtract(t *testing.T) {
+	type testcase struct {
+		testcase string
+		// committed offset of partition 0 before extraction
+		committed           int64
+		messages            []*kgo.Record
+		expectedOperations  []string
+		expectedIDs         []string
+		expectedCheckpoints []string
+		expectedPositions   []string
+		expectedError       bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Consume from start of partitions",
+			messages: []*kgo.Record{
+				kafkaMessage(0, `{"order_id": 1}`, ""),
+				kafkaMessage(0, `{"order_id": 1}`, "update"),
+				kafkaMessage(1, `{"order_id": 2}`, "delete"),
+			},
+			expectedOperations:  []string{"", "update", "delete"},
+			expectedIDs:         []string{"1", "1", "2"},
+			expectedCheckpoints: []string{"orders-kafka/orders/0", "orders-kafka/orders/0", "orders-kafka/orders/1"},
+			expectedPositions:   []string{"1", "2", "1"},
+		},
+		{
+			testcase:  "Consume from committed offset",
+			committed: 1,
+			messages: []*kgo.Record{
+				kafkaMessage(0, `{"order_id": 1}`, ""),
+				kafkaMessage(0, `{"order_id": 2}`, ""),
+			},
+			expectedOperations:  []string{""},
+			expectedIDs:         []string{"2"},
+			expectedCheckpoints: []string{"orders-kafka/orders/0"},
+			expectedPositions:   []string{"2"},
+		},
+		{
+			testcase: "Message not transformed",
+			messages: []*kgo.Record{
+				kafkaMessage(0, `{"order_id": 1}`, ""),
+				kafkaMessage(0, `not json`, ""),
+			},
+			expectedOperations:  []string{""},
+			expectedIDs:         []string{"1"},
+			expectedCheckpoints: []string{"orders-kafka/orders/0"},
+			expectedPositions:   []string{"1"},
+			expectedError:       true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newKafkaDependencies(t)
+			defer dep.cluster.Close()
+			if tc.committed > 0 {
+				dep.commit(t, 0, tc.committed)
+			}
+			// messages of second partition are produced after first partition, so records are extracted in produced order
+			for _, message := range tc.messages {
+				dep.produce(t, message)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dataChan := make(chan transformation.Record, 10)
+			extractErr := make(chan error, 1)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				extractErr <- dep.kafkaExtraction.Extract(ctx, dep.datasource, transformation.FieldsTransformer, dataChan, &wg)
+			}()
+
+			var operations, ids, checkpoints, positions []string
+			var err error
+			stopped := false
+			// extraction is stopped once expected records are extracted unless it is expected to fail
+			for !stopped && (tc.expectedError || len(operations) < len(tc.expectedOperations)) {
+				select {
+				case record := <-dataChan:
+					operations = append(operations, record.Operation)
+					ids = append(ids, string(record.Get("order_id").(json.Number)))
+					checkpoints = append(checkpoints, record.Metadata.Checkpoint)
+					positions = append(positions, record.Metadata.Position)
+				case err = <-extractErr:
+					stopped = true
+				case <-time.After(10 * time.Second):
+					t.Fatalf("expected %v records, but got %v", len(tc.expectedOperations), len(operations))
+				}
+			}
+			if !stopped {
+				cancel()
+				err = <-extractErr
+			}
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+			} else if err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+
+			if !reflect.DeepEqual(operations, tc.expectedOperations) {
+				t.Errorf("expected %v, but got %v", tc.expectedOperations, operations)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("expected %v, but got %v", tc.expectedIDs, ids)
+			}
+			if !reflect.DeepEqual(checkpoints, tc.expectedCheckpoints) {
+				t.Errorf("expected checkpoints %v, but got %v", tc.expectedCheckpoints, checkpoints)
+			}
+			if !reflect.DeepEqual(positions, tc.expectedPositions) {
+				t.Errorf("expected positions %v, but got %v", tc.expectedPositions, positions)
+			}
+		})
+	}
+}
+
+func TestKafkaCommit(t *testing.T) {
+	type testcase struct {
+		testcase string
+		// committed offset of partition 0 before extraction
+		committed int64
+		// checkpointed position of each partition
+		checkpoint map[int32]string
+		// committed offset of each partition after extraction is stopped, -1 is not committed
+		expectedCommitted [2]int64
+		// id of first record extracted after restart
+		expectedID string
+	}
+
+	// checkpointed offsets are committed, offset of partition which is not assigned or before committed offset is not committed
+	testcases := []testcase{
+		{
+			testcase:          "Nothing checkpointed",
+			expectedCommitted: [2]int64{-1, -1},
+			expectedID:        "1",
+		},
+		{
+			testcase:          "First record checkpointed",
+			checkpoint:        map[int32]string{0: "1"},
+			expectedCommitted: [2]int64{1, -1},
+			expectedID:        "2",
+		},
+		{
+			testcase:          "Checkpoint of partition of other consumer",
+			checkpoint:        map[int32]string{0: "2", 5: "9"},
+			expectedCommitted: [2]int64{2, -1},
+			expectedID:        "3",
+		},
+		{
+			testcase:          "Stale checkpoint of reassigned partition",
+			committed:         2,
+			checkpoint:        map[int32]string{0: "1"},
+			expectedCommitted: [2]int64{2, -1},
+			expectedID:        "3",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newKafkaDependencies(t)
+			defer dep.cluster.Close()
+			if tc.committed > 0 {
+				dep.commit(t, 0, tc.committed)
+			}
+			for _, message := range []string{`{"order_id": 1}`, `{"order_id": 2}`, `{"order_id": 3}`} {
+				dep.produce(t, kafkaMessage(0, message, ""))
+			}
+
+			dep.extract(t, 3-int(tc.committed), func() {
+				for partition, position := range tc.checkpoint {
+					dep.checkpoint.Save(context.Background(), fmt.Sprintf("%s/%s/%d", dep.datasource.Name, dep.datasource.Topic, partition), position)
+				}
+			})
+			for partition, expected := range tc.expectedCommitted {
+				if committed := dep.committed(t, int32(partition)); committed != expected {
+					t.Errorf("expected partition %d committed %v, but got %v", partition, expected, committed)
+				}
+			}
+
+			// restarted extraction consumes from committed offset
+			for partition := range tc.checkpoint {
+				dep.checkpoint.Save(context.Background(), fmt.Sprintf("%s/%s/%d", dep.datasource.Name, dep.datasource.Topic, partition), "")
+			}
+			ids := dep.extract(t, 1, func() {})
+			if ids[0] != tc.expectedID {
+				t.Errorf("expected %v, but got %v", tc.expectedID, ids[0])
+			}
+		})
+	}
+}
+
+func kafkaMessage(partition int32, value string, operation string) *kgo.Record {
+	message := &kgo.Record{Partition: partition, Value: []byte(value)}
+	if operation != "" {
+		message.Headers = []kgo.RecordHeader{{Key: "operation", Value: []byte(operation)}}
+	}
+	return message
+}
+
+type kafkaDependencies struct {
+	datasource      config.DataSourceConfig
+	checkpoint      checkpoint.Store
+	cluster         *kfake.Cluster
+	producer        *kgo.Client
+	kafkaExtraction *extraction.KafkaExtraction
+}
+
+func newKafkaDependencies(t *testing.T) kafkaDependencies {
+	store := checkpoint.NewMemoryStore()
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "orders"))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	producer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.DefaultProduceTopic("orders"), kgo.RecordPartitioner(kgo.ManualPartitioner()))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	t.Cleanup(producer.Close)
+
+	return kafkaDependencies{
+		datasource: config.DataSourceConfig{
+			Name:   "orders-kafka",
+			Type:   "kafka",
+			Source: strings.Join(cluster.ListenAddrs(), ","),
+			Topic:  "orders",
+			Group:  "etl",
+		},
+		checkpoint:      store,
+		cluster:         cluster,
+		producer:        producer,
+		kafkaExtraction: extraction.NewKafkaExtraction(newLogger(), store),
+	}
+}
+
+func (d kafkaDependencies) produce(t *testing.T, message *kgo.Record) {
+	if err := d.producer.ProduceSync(context.Background(), message).FirstErr(); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+}
+
+// commit offset of partition to consumer group of datasource
+func (d kafkaDependencies) commit(t *testing.T, partition int32, offset int64) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(d.cluster.ListenAddrs()...), kgo.ConsumerGroup(d.datasource.Group))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer client.Close()
+
+	offsets := map[string]map[int32]kgo.EpochOffset{d.datasource.Topic: {partition: {Epoch: -1, Offset: offset}}}
+	var commitErr error
+	client.CommitOffsetsSync(context.Background(), offsets, func(_ *kgo.Client, _ *kmsg.OffsetCommitRequest, _ *kmsg.OffsetCommitResponse, err error) {
+		commitErr = err
+	})
+	if commitErr != nil {
+		t.Fatalf("not expected error, but got %v", commitErr)
+	}
+}
+
+// committed offset of partition in consumer group of datasource, -1 if not committed
+func (d kafkaDependencies) committed(t *testing.T, partition int32) int64 {
+	client, err := kgo.NewClient(kgo.SeedBrokers(d.cluster.ListenAddrs()...))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer client.Close()
+
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = d.datasource.Group
+	topic := kmsg.NewOffsetFetchRequestTopic()
+	topic.Topic = d.datasource.Topic
+	topic.Partitions = []int32{partition}
+	req.Topics = append(req.Topics, topic)
+	resp, err := req.RequestWith(context.Background(), client)
+	if err == nil {
+		err = kerr.ErrorForCode(resp.ErrorCode)
+	}
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	for _, topic := range resp.Topics {
+		for _, p := range topic.Partitions {
+			if p.Partition == partition {
+				return p.Offset
+			}
+		}
+	}
+	return -1
+}
+
+// run extraction until count records are extracted, loaded is called before extraction is stopped
+func (d kafkaDependencies) extract(t *testing.T, count int, loaded func()) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dataChan := make(chan transformation.Record, 10)
+	extractErr := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		extractErr <- d.kafkaExtraction.Extract(ctx, d.datasource, transformation.FieldsTransformer, dataChan, &wg)
+	}()
+
+	var ids []string
+	for len(ids) < count {
+		select {
+		case record := <-dataChan:
+			ids = append(ids, string(record.Get("order_id").(json.Number)))
+		case err := <-extractErr:
+			t.Fatalf("not expected error, but got %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("expected %v records, but got %v", count, len(ids))
+		}
+	}
+	loaded()
+	cancel()
+	if err := <-extractErr; err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	return ids
+}
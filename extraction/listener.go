@@ -0,0 +1,278 @@
+package extraction
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// header of request body signature if not configured
+	defaultSignatureHeader = "X-Signature-256"
+	// max request body bytes if not configured
+	defaultMaxBodySize = 1 << 20
+	// seconds in Retry-After header when data channel is full
+	retryAfterSeconds = "1"
+	// time for in-flight requests to finish when datasource is stopped
+	listenerShutdownTimeout = 5 * time.Second
+)
+
+// push based extraction receiving JSON payloads posted by partners (webhook)
+type ListenerExtraction struct {
+	logger utils.Logger
+}
+
+func NewListenerExtraction(logger utils.Logger) *ListenerExtraction {
+	return &ListenerExtraction{
+		logger: logger,
+	}
+}
+
+// split listener source (e.g. :8090/webhooks/orders) into listen address and path (default /)
+func ParseListenerSource(source string) (string, string, error) {
+	address, path := source, "/"
+	if i := strings.Index(source, "/"); i != -1 {
+		address, path = source[:i], source[i:]
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", "", errors.Wrapf(err, "invalid listen address %s", address)
+	}
+	return address, path, nil
+}
+
+// extract function to start HTTP server on datasource source address and path
+// each POST request body (JSON object or array of JSON objects) is transformed using transformer function in params
+// and records are passed to data channel before 202 is responded
+// run until context is cancelled (once is ignored as pushed records have no end)
+func (l *ListenerExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := l.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+
+	defer wg.Done()
+
+	address, path, err := ParseListenerSource(datasource.Source)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Errorf("unable to listen %v", err)
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, NewListenerHandler(datasource, transformer, dataPipeline, logger))
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	logger.Infof("HTTP listener source listening on %s%s", listener.Addr(), path)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), listenerShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warningf("unable to shutdown HTTP listener %v", err)
+	}
+	logger.Infof("HTTP listener source stopped")
+	return nil
+}
+
+// handler of pushed payloads of a datasource
+type ListenerHandler struct {
+	datasource   config.DataSourceConfig
+	transformer  transformation.TransformFunc
+	dataPipeline chan<- transformation.Record
+	logger       utils.Logger
+	// payloads are enqueued one at a time, so a payload is enqueued entirely or rejected
+	mu sync.Mutex
+}
+
+// handler responding
+//
+//	202 records are enqueued
+//	400 body is not JSON object or array
+//	401 signature is missing or invalid (only if secret is configured)
+//	405 method is not POST
+//	413 body is larger than MaxBodySize or payload has more records than data channel capacity
+//	422 payload can't be transformed
+//	429 data channel is full, retry after Retry-After seconds
+func NewListenerHandler(datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, logger utils.Logger) *ListenerHandler {
+	if datasource.SignatureHeader == "" {
+		datasource.SignatureHeader = defaultSignatureHeader
+	}
+	if datasource.MaxBodySize <= 0 {
+		datasource.MaxBodySize = defaultMaxBodySize
+	}
+
+	return &ListenerHandler{
+		datasource:   datasource,
+		transformer:  transformer,
+		dataPipeline: dataPipeline,
+		logger:       logger,
+	}
+}
+
+func (h *ListenerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.datasource.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("body is larger than %d bytes", h.datasource.MaxBodySize))
+			return
+		}
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "unable to read body"))
+		return
+	}
+
+	if h.datasource.Secret != "" && !h.verify(body, r.Header.Get(h.datasource.SignatureHeader)) {
+		h.logger.Warningf("rejected payload with invalid signature from %s", r.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, errors.New("invalid signature"))
+		return
+	}
+
+	payloads, err := splitPayload(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// every payload is transformed before enqueuing, so invalid batch is rejected without partial enqueue
+	// payload get a correlation id before transforming like raw data of other datasources, records transformed from it share the id
+	records := make([]transformation.Record, 0, len(payloads))
+	for i, payload := range payloads {
+		// pushed record has no position to checkpoint
+		metadata := newRecordMetadata(h.datasource, "")
+		logger := h.logger.WithFields(utils.Fields{utils.FieldRecordID: metadata.CorrelationID})
+		logger.Debugf("transforming payload %d %s", i, payload)
+		transformed, err := h.transformer(logger, payload)
+		if err != nil {
+			logger.Errorf("unable to transform payload %d %v", i, err)
+			writeError(w, http.StatusUnprocessableEntity, errors.Wrapf(err, "unable to transform payload %d", i))
+			return
+		}
+		for _, record := range transformed {
+			record.Metadata = metadata
+			records = append(records, record)
+		}
+	}
+
+	ids, status, err := h.enqueue(r.Context(), records)
+	if err != nil {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", retryAfterSeconds)
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	h.logger.Debugf("enqueued %d records from %d payloads", len(records), len(payloads))
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"accepted": len(ids), "correlationIds": ids})
+}
+
+// constant time comparison of hex encoded HMAC-SHA256 of body, signature can be prefixed by sha256=
+func (h *ListenerHandler) verify(body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.datasource.Secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// push records to data channel if it has room for all of them
+// return correlation ids of records, or HTTP status of rejection
+func (h *ListenerHandler) enqueue(ctx context.Context, records []transformation.Record) ([]string, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(records) > cap(h.dataPipeline) {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("payload has %d records but at most %d records are accepted", len(records), cap(h.dataPipeline))
+	}
+	// room is shared by other datasources, so it is best effort and send may still wait for a moment
+	if len(records) > cap(h.dataPipeline)-len(h.dataPipeline) {
+		return nil, http.StatusTooManyRequests, errors.New("pipeline is full")
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		select {
+		case h.dataPipeline <- record:
+			ids = append(ids, record.Metadata.CorrelationID)
+		case <-ctx.Done():
+			h.logger.Errorf("request cancelled after %d of %d records enqueued", len(ids), len(records))
+			return nil, http.StatusServiceUnavailable, errors.Wrapf(ctx.Err(), "%d of %d records enqueued", len(ids), len(records))
+		}
+	}
+
+	return ids, http.StatusAccepted, nil
+}
+
+// raw data of each payload in JSON object or array of JSON objects
+func splitPayload(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+	switch {
+	case len(body) == 0:
+		return nil, errors.New("body is empty")
+	case body[0] == '{':
+		if !json.Valid(body) {
+			return nil, errors.New("body is not valid JSON")
+		}
+		return []json.RawMessage{body}, nil
+	case body[0] == '[':
+		var payloads []json.RawMessage
+		if err := json.Unmarshal(body, &payloads); err != nil {
+			return nil, errors.Wrap(err, "body is not valid JSON")
+		}
+		for i, payload := range payloads {
+			if trimmed := bytes.TrimSpace(payload); len(trimmed) == 0 || trimmed[0] != '{' {
+				return nil, fmt.Errorf("payload %d is not JSON object", i)
+			}
+		}
+		return payloads, nil
+	}
+	return nil, errors.New("body is not JSON object or array")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,305 @@
+package extraction_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestListenerHandler(t *testing.T) {
+	type testcase struct {
+		testcase          string
+		datasource        config.DataSourceConfig
+		method            string
+		body              string
+		signature         string
+		queued            int
+		expectedStatus    int
+		expectedNames     []string
+		expectedRetryHint bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase:       "Single payload",
+			method:         http.MethodPost,
+			body:           `{"name": "alice"}`,
+			expectedStatus: http.StatusAccepted,
+			expectedNames:  []string{"alice"},
+		},
+		{
+			testcase:       "Batched payloads",
+			method:         http.MethodPost,
+			body:           `[{"name": "alice"}, {"name": "dropped"}, {"name": "bob"}]`,
+			expectedStatus: http.StatusAccepted,
+			expectedNames:  []string{"alice", "bob"},
+		},
+		{
+			testcase:       "Not JSON",
+			method:         http.MethodPost,
+			body:           `alice`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testcase:       "Batch with non object payload",
+			method:         http.MethodPost,
+			body:           `[{"name": "alice"}, "bob"]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			testcase:       "Transform error rejects whole batch",
+			method:         http.MethodPost,
+			body:           `[{"name": "alice"}, {"name": ""}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			testcase:       "Method not allowed",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			testcase:       "Body too large",
+			datasource:     config.DataSourceConfig{MaxBodySize: 10},
+			method:         http.MethodPost,
+			body:           `{"name": "alice"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			testcase:       "Valid signature",
+			datasource:     config.DataSourceConfig{Secret: "secret"},
+			method:         http.MethodPost,
+			body:           `{"name": "alice"}`,
+			signature:      "sha256=" + sign("secret", `{"name": "alice"}`),
+			expectedStatus: http.StatusAccepted,
+			expectedNames:  []string{"alice"},
+		},
+		{
+			testcase:       "Invalid signature",
+			datasource:     config.DataSourceConfig{Secret: "secret"},
+			method:         http.MethodPost,
+			body:           `{"name": "alice"}`,
+			signature:      sign("other", `{"name": "alice"}`),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			testcase:       "Missing signature",
+			datasource:     config.DataSourceConfig{Secret: "secret"},
+			method:         http.MethodPost,
+			body:           `{"name": "alice"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			testcase:          "Pipeline full",
+			method:            http.MethodPost,
+			body:              `[{"name": "alice"}, {"name": "bob"}]`,
+			queued:            2,
+			expectedStatus:    http.StatusTooManyRequests,
+			expectedRetryHint: true,
+		},
+		{
+			testcase:       "Batch larger than pipeline",
+			method:         http.MethodPost,
+			body:           `[{"name": "a"}, {"name": "b"}, {"name": "c"}, {"name": "d"}, {"name": "e"}]`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			tc.datasource.Name = "webhook"
+			dataChan := make(chan transformation.Record, 3)
+			for i := 0; i < tc.queued; i++ {
+				dataChan <- transformation.Record{}
+			}
+			handler := extraction.NewListenerHandler(tc.datasource, jsonNameTransformer, dataChan, newLogger())
+
+			req := httptest.NewRequest(tc.method, "/webhooks", strings.NewReader(tc.body))
+			if tc.signature != "" {
+				req.Header.Set("X-Signature-256", tc.signature)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected %v, but got %v %s", tc.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if tc.expectedRetryHint && rec.Header().Get("Retry-After") == "" {
+				t.Errorf("expected Retry-After header, but got none")
+			}
+
+			if len(dataChan) != tc.queued+len(tc.expectedNames) {
+				t.Fatalf("expected %v records, but got %v", tc.queued+len(tc.expectedNames), len(dataChan))
+			}
+			for i := 0; i < tc.queued; i++ {
+				<-dataChan
+			}
+			for _, name := range tc.expectedNames {
+				record := <-dataChan
+				if record.String("FirstName") != name {
+					t.Errorf("expected %v, but got %v", name, record.String("FirstName"))
+				}
+				if record.Metadata.Datasource != "webhook" || record.Metadata.CorrelationID == "" || record.Metadata.Position != "" {
+					t.Errorf("expected metadata to be attached without position, but got %+v", record.Metadata)
+				}
+			}
+			if tc.expectedStatus == http.StatusAccepted {
+				var body struct{ Accepted int }
+				json.NewDecoder(rec.Body).Decode(&body)
+				if body.Accepted != len(tc.expectedNames) {
+					t.Errorf("expected %v, but got %v", len(tc.expectedNames), body.Accepted)
+				}
+			}
+		})
+	}
+}
+
+func TestListenerHandlerTransformerLogger(t *testing.T) {
+	logger, hook := logrustest.NewNullLogger()
+	// transformer log the payload name by logger passed by handler
+	transformer := func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+		records, err := jsonNameTransformer(logger, data)
+		for _, record := range records {
+			logger.Infof("transforming %s", record.String("FirstName"))
+		}
+		return records, err
+	}
+	dataChan := make(chan transformation.Record, 3)
+	handler := extraction.NewListenerHandler(config.DataSourceConfig{Name: "webhook"}, transformer, dataChan, utils.NewLogrusLogger(logger))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`[{"name": "alice"}, {"name": "bob"}]`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected %v, but got %v %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var body struct{ CorrelationIds []string }
+	json.NewDecoder(rec.Body).Decode(&body)
+	close(dataChan)
+
+	ids := make(map[string]string)
+	for _, entry := range hook.AllEntries() {
+		if name, ok := strings.CutPrefix(entry.Message, "transforming "); ok {
+			ids[name], _ = entry.Data[utils.FieldRecordID].(string)
+		}
+	}
+	i := 0
+	for record := range dataChan {
+		name := record.String("FirstName")
+		if ids[name] == "" || ids[name] != record.Metadata.CorrelationID {
+			t.Errorf("expected transformer log of %v with record id %v, but got %v", name, record.Metadata.CorrelationID, ids[name])
+		}
+		if i >= len(body.CorrelationIds) || body.CorrelationIds[i] != record.Metadata.CorrelationID {
+			t.Errorf("expected correlation id %v in response, but got %v", record.Metadata.CorrelationID, body.CorrelationIds)
+		}
+		i++
+	}
+	if ids["alice"] == ids["bob"] {
+		t.Errorf("expected payloads to get different correlation ids, but got %v", ids)
+	}
+}
+
+func TestListenerExtract(t *testing.T) {
+	// reserve free port for listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	datasource := config.DataSourceConfig{Name: "webhook", Type: "http-listener", Source: address + "/webhooks"}
+	dataChan := make(chan transformation.Record, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	extractErr := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		extractErr <- extraction.NewListenerExtraction(newLogger()).Extract(ctx, datasource, jsonNameTransformer, dataChan, &wg)
+	}()
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = http.Post("http://"+address+"/webhooks", "application/json", strings.NewReader(`{"name": "alice"}`))
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected %v, but got %v", http.StatusAccepted, resp.StatusCode)
+	}
+	if record := <-dataChan; record.String("FirstName") != "alice" {
+		t.Errorf("expected %v, but got %v", "alice", record.String("FirstName"))
+	}
+
+	// address is in use until listener is stopped
+	wg.Add(1)
+	if err := extraction.NewListenerExtraction(newLogger()).Extract(context.Background(), datasource, jsonNameTransformer, dataChan, &wg); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+
+	cancel()
+	if err := <-extractErr; err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+}
+
+func TestParseListenerSource(t *testing.T) {
+	address, path, err := extraction.ParseListenerSource(":8090/webhooks/orders")
+	if err != nil || address != ":8090" || path != "/webhooks/orders" {
+		t.Errorf("expected :8090 and /webhooks/orders, but got %v %v %v", address, path, err)
+	}
+	if _, path, _ := extraction.ParseListenerSource("localhost:8090"); path != "/" {
+		t.Errorf("expected %v, but got %v", "/", path)
+	}
+	if _, _, err := extraction.ParseListenerSource("webhooks"); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+// payload {"name": "dropped"} is transformed to no record and empty name is an error
+func jsonNameTransformer(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+	var payload struct{ Name string }
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	switch payload.Name {
+	case "":
+		return nil, errors.New("name is missing")
+	case "dropped":
+		return nil, nil
+	}
+	return []transformation.Record{{Fields: map[string]interface{}{"FirstName": payload.Name}}}, nil
+}
+
+func sign(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newLogger() utils.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return utils.NewLogrusLogger(logger)
+}
@@ -0,0 +1,363 @@
+package extraction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pkg/errors"
+)
+
+// streaming replication messages in CopyData (https://www.postgresql.org/docs/current/protocol-replication.html)
+const (
+	// WAL data of server
+	xLogDataByteID = 'w'
+	// keepalive of server
+	primaryKeepaliveByteID = 'k'
+	// received and flushed position of client
+	standbyStatusUpdateByteID = 'r'
+)
+
+// time of replication messages is microseconds since 2000-01-01
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// write-ahead log position
+type lsn uint64
+
+// parse position formatted by String (e.g. 16/B374D848)
+func parseLSN(s string) (lsn, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	var upper, lower uint32
+	if !ok || hi == "" || lo == "" {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	if _, err := fmt.Sscanf(hi+" "+lo, "%X %X", &upper, &lower); err != nil {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	return lsn(uint64(upper)<<32 | uint64(lower)), nil
+}
+
+func (l lsn) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// big endian fields of a message, first error is kept and later reads return zero value
+type messageReader struct {
+	data []byte
+	err  error
+}
+
+func (r *messageReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errors.New("message is truncated")
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *messageReader) uint8() byte {
+	if b := r.bytes(1); r.err == nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *messageReader) uint16() uint16 {
+	if b := r.bytes(2); r.err == nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *messageReader) uint32() uint32 {
+	if b := r.bytes(4); r.err == nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *messageReader) uint64() uint64 {
+	if b := r.bytes(8); r.err == nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// null terminated string
+func (r *messageReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.data, 0)
+	if i < 0 {
+		r.err = errors.New("message is truncated")
+		return ""
+	}
+	s := string(r.data[:i])
+	r.data = r.data[i+1:]
+	return s
+}
+
+// XLogData message, WAL data is a pgoutput message
+type xLogData struct {
+	walStart lsn
+	walData  []byte
+}
+
+func parseXLogData(data []byte) (xLogData, error) {
+	r := &messageReader{data: data}
+	x := xLogData{walStart: lsn(r.uint64())}
+	// server WAL end and send time
+	r.uint64()
+	r.uint64()
+	x.walData = r.data
+	return x, r.err
+}
+
+// primary keepalive message
+type primaryKeepalive struct {
+	// position up to which WAL is sent
+	walEnd lsn
+	// server asks for standby status update immediately
+	replyRequested bool
+}
+
+func parsePrimaryKeepalive(data []byte) (primaryKeepalive, error) {
+	r := &messageReader{data: data}
+	k := primaryKeepalive{walEnd: lsn(r.uint64())}
+	r.uint64()
+	k.replyRequested = r.uint8() == 1
+	return k, r.err
+}
+
+// standby status update message, written position is received and flushed (applied) position is committed by loader
+func standbyStatusUpdate(written lsn, flushed lsn, now time.Time) []byte {
+	data := make([]byte, 34)
+	data[0] = standbyStatusUpdateByteID
+	binary.BigEndian.PutUint64(data[1:], uint64(written))
+	binary.BigEndian.PutUint64(data[9:], uint64(flushed))
+	binary.BigEndian.PutUint64(data[17:], uint64(flushed))
+	binary.BigEndian.PutUint64(data[25:], uint64(now.Sub(postgresEpoch).Microseconds()))
+	return data
+}
+
+// table of pgoutput changes, sent before the first change of the table in each replication connection
+type relation struct {
+	namespace string
+	name      string
+	columns   []relationColumn
+}
+
+type relationColumn struct {
+	name string
+	// column is part of replica identity (e.g. primary key, every column if REPLICA IDENTITY FULL)
+	key     bool
+	typeOID uint32
+}
+
+// relation is the table, which is schema qualified (e.g. public.orders) or in public schema
+func (r relation) is(table string) bool {
+	namespace, name, ok := strings.Cut(table, ".")
+	if !ok {
+		namespace, name = "public", table
+	}
+	return r.namespace == namespace && r.name == name
+}
+
+// column value of a tuple, kind is 'n' (null), 'u' (unchanged TOAST value which is not sent) or 't' (text)
+type tupleColumn struct {
+	kind byte
+	data []byte
+}
+
+// pgoutput message (https://www.postgresql.org/docs/current/protocol-logicalrep-message-formats.html)
+type pgoutputMessage struct {
+	// 'B' begin, 'C' commit, 'R' relation, 'I' insert, 'U' update, 'D' delete, 'T' truncate, others are not decoded
+	kind byte
+	// final position of transaction for begin, end of transaction for commit
+	lsn lsn
+	// relation of relation and change message
+	relationID uint32
+	relation   relation
+	// old tuple of update and delete, 'K' (key columns) or 'O' (every column if REPLICA IDENTITY FULL), 0 if not sent
+	oldKind  byte
+	oldTuple []tupleColumn
+	newTuple []tupleColumn
+}
+
+// decode pgoutput message of protocol version 1
+func parsePgoutput(data []byte) (pgoutputMessage, error) {
+	r := &messageReader{data: data}
+	m := pgoutputMessage{kind: r.uint8()}
+	switch m.kind {
+	case 'B':
+		m.lsn = lsn(r.uint64())
+	case 'C':
+		// flags and commit position before end position
+		r.uint8()
+		r.uint64()
+		m.lsn = lsn(r.uint64())
+	case 'R':
+		m.relationID = r.uint32()
+		m.relation.namespace = r.string()
+		if m.relation.namespace == "" {
+			m.relation.namespace = "pg_catalog"
+		}
+		m.relation.name = r.string()
+		// replica identity setting
+		r.uint8()
+		columns := int(r.uint16())
+		for i := 0; i < columns && r.err == nil; i++ {
+			column := relationColumn{key: r.uint8()&1 == 1}
+			column.name = r.string()
+			column.typeOID = r.uint32()
+			// type modifier
+			r.uint32()
+			m.relation.columns = append(m.relation.columns, column)
+		}
+	case 'I':
+		m.relationID = r.uint32()
+		if kind := r.uint8(); r.err == nil && kind != 'N' {
+			return m, fmt.Errorf("unexpected tuple %q of insert", kind)
+		}
+		m.newTuple = r.tuple()
+	case 'U':
+		m.relationID = r.uint32()
+		kind := r.uint8()
+		if kind == 'K' || kind == 'O' {
+			m.oldKind = kind
+			m.oldTuple = r.tuple()
+			kind = r.uint8()
+		}
+		if r.err == nil && kind != 'N' {
+			return m, fmt.Errorf("unexpected tuple %q of update", kind)
+		}
+		m.newTuple = r.tuple()
+	case 'D':
+		m.relationID = r.uint32()
+		m.oldKind = r.uint8()
+		if r.err == nil && m.oldKind != 'K' && m.oldKind != 'O' {
+			return m, fmt.Errorf("unexpected tuple %q of delete", m.oldKind)
+		}
+		m.oldTuple = r.tuple()
+	}
+	if r.err != nil {
+		return m, errors.Wrapf(r.err, "unable to decode pgoutput message %q", m.kind)
+	}
+	return m, nil
+}
+
+// TupleData of change message
+func (r *messageReader) tuple() []tupleColumn {
+	columns := make([]tupleColumn, r.uint16())
+	for i := range columns {
+		columns[i].kind = r.uint8()
+		if columns[i].kind == 't' || columns[i].kind == 'b' {
+			columns[i].data = r.bytes(int(r.uint32()))
+		}
+		if r.err != nil {
+			return nil
+		}
+	}
+	return columns
+}
+
+// row change of a transaction
+type rowChange struct {
+	operation string
+	// column name to value
+	row map[string]interface{}
+}
+
+// row changes of insert, update or delete message
+// update changing key columns is a delete of the old key and an update of the new key
+func (r relation) changes(m pgoutputMessage, types *pgtype.Map) ([]rowChange, error) {
+	switch m.kind {
+	case 'I':
+		row, err := r.row(m.newTuple, nil, false, types)
+		if err != nil {
+			return nil, err
+		}
+		return []rowChange{{operation: transformation.OperationInsert, row: row}}, nil
+	case 'U':
+		row, err := r.row(m.newTuple, m.oldTuple, false, types)
+		if err != nil {
+			return nil, err
+		}
+		update := rowChange{operation: transformation.OperationUpdate, row: row}
+		// old key is only sent without REPLICA IDENTITY FULL if key columns are changed
+		if m.oldKind != 'K' {
+			return []rowChange{update}, nil
+		}
+		old, err := r.row(m.oldTuple, nil, true, types)
+		if err != nil {
+			return nil, err
+		}
+		return []rowChange{{operation: transformation.OperationDelete, row: old}, update}, nil
+	case 'D':
+		row, err := r.row(m.oldTuple, nil, m.oldKind == 'K', types)
+		if err != nil {
+			return nil, err
+		}
+		return []rowChange{{operation: transformation.OperationDelete, row: row}}, nil
+	}
+	return nil, nil
+}
+
+// column values of tuple, unchanged TOAST value is taken from old tuple (sent if REPLICA IDENTITY FULL)
+// null non-key columns of key tuple are not values, so they are skipped if keyOnly
+func (r relation) row(tuple []tupleColumn, old []tupleColumn, keyOnly bool, types *pgtype.Map) (map[string]interface{}, error) {
+	if len(tuple) != len(r.columns) {
+		return nil, fmt.Errorf("tuple of %s.%s has %d columns but relation has %d", r.namespace, r.name, len(tuple), len(r.columns))
+	}
+	row := make(map[string]interface{}, len(tuple))
+	for i, column := range r.columns {
+		if keyOnly && !column.key {
+			continue
+		}
+		value := tuple[i]
+		if value.kind == 'u' && len(old) == len(tuple) {
+			value = old[i]
+		}
+		switch value.kind {
+		case 'n':
+			row[column.name] = nil
+		case 't':
+			row[column.name] = decodeText(column.typeOID, value.data, types)
+		case 'u':
+			return nil, fmt.Errorf("unchanged TOAST value of %s.%s column %s is not sent, set REPLICA IDENTITY FULL", r.namespace, r.name, column.name)
+		default:
+			return nil, fmt.Errorf("unexpected %q value of %s.%s column %s", value.kind, r.namespace, r.name, column.name)
+		}
+	}
+	return row, nil
+}
+
+// JSON value of column text value, number and bool keep their type and date is decoded to time in UTC
+// other values (e.g. text, uuid, numeric NaN, infinite timestamp) are strings
+func decodeText(typeOID uint32, data []byte, types *pgtype.Map) interface{} {
+	switch typeOID {
+	case pgtype.BoolOID:
+		return string(data) == "t"
+	case pgtype.Int2OID, pgtype.Int4OID, pgtype.Int8OID, pgtype.Float4OID, pgtype.Float8OID, pgtype.NumericOID:
+		if json.Valid(data) {
+			return json.Number(data)
+		}
+	case pgtype.DateOID, pgtype.TimestampOID, pgtype.TimestamptzOID:
+		var t time.Time
+		if err := types.Scan(typeOID, pgtype.TextFormatCode, data, &t); err == nil {
+			return t.UTC()
+		}
+	}
+	return string(data)
+}
@@ -0,0 +1,305 @@
+package extraction
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pkg/errors"
+)
+
+const (
+	// interval of standby status updates confirming committed position to server
+	standbyStatusInterval = 10 * time.Second
+	// SQLSTATE of creating existing replication slot
+	duplicateObject = "42710"
+)
+
+// change data capture of postgres tables from logical replication slot with pgoutput plugin
+type PostgresCDCExtraction struct {
+	logger     utils.Logger
+	checkpoint checkpoint.Store
+}
+
+func NewPostgresCDCExtraction(logger utils.Logger, checkpoint checkpoint.Store) *PostgresCDCExtraction {
+	return &PostgresCDCExtraction{
+		logger:     logger,
+		checkpoint: checkpoint,
+	}
+}
+
+// replication progress kept across reconnects
+type replicationState struct {
+	// end of last received transaction, where next connection starts
+	start lsn
+	// final position of transaction being received and number of its changes passed to data channel
+	// the changes are skipped if the transaction is sent again after reconnecting
+	transaction lsn
+	emitted     int
+	// position of last change passed to data channel with position
+	lastPosition lsn
+}
+
+// extract function to stream changes of publication tables (or Table only) from replication slot
+// each inserted, updated or deleted row (JSON object of column to value, key columns only for delete) is transformed using transformer function in params,
+// and the records get operation of the change and are passed to data channel
+// end of transaction is the position of its last change, slot is confirmed up to the checkpoint once the records are committed
+// stop without error when context is cancelled
+func (p *PostgresCDCExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := p.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("Postgres CDC source: %s", utils.RedactConnectionString(datasource.Source))
+
+	defer wg.Done()
+
+	position, err := p.checkpoint.Load(ctx, datasource.Name)
+	if err != nil {
+		logger.Errorf("unable to load checkpoint %v", err)
+		return err
+	}
+	state := &replicationState{}
+	if position != "" {
+		if state.start, err = parseLSN(position); err != nil {
+			return err
+		}
+		state.lastPosition = state.start
+	}
+
+	return keepStreaming(ctx, logger, datasource, newBackoff(datasource), func(ctx context.Context) (int, error) {
+		return p.stream(ctx, logger, datasource, state, transformer, dataPipeline)
+	})
+}
+
+// replicate changes of a single connection until it is closed
+// rejected replication command (e.g. missing publication) and change which can't be decoded or transformed is not recovered
+func (p *PostgresCDCExtraction) stream(ctx context.Context, logger utils.Logger, datasource config.DataSourceConfig, state *replicationState, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record) (int, error) {
+	conn, err := connectReplication(ctx, datasource)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(context.Background())
+
+	// slot is kept by server until it is dropped, so changes while datasource is stopped are not lost
+	if _, err := conn.Exec(ctx, "CREATE_REPLICATION_SLOT "+datasource.Slot+" LOGICAL pgoutput").ReadAll(); err != nil {
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) {
+			return 0, err
+		}
+		if pgErr.Code != duplicateObject {
+			return 0, permanentError{errors.Wrapf(err, "unable to create replication slot %s", datasource.Slot)}
+		}
+	} else {
+		logger.Infof("replication slot %s created", datasource.Slot)
+	}
+
+	command := fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL %s (proto_version '1', publication_names '%s')", datasource.Slot, state.start, datasource.Publication)
+	conn.Frontend().Send(&pgproto3.Query{String: command})
+	if err := conn.Frontend().Flush(); err != nil {
+		return 0, err
+	}
+	for started := false; !started; {
+		msg, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return 0, permanentError{errors.Wrapf(pgconn.ErrorResponseToPgError(msg), "unable to start replication of slot %s", datasource.Slot)}
+		case *pgproto3.CopyBothResponse:
+			started = true
+		}
+	}
+	logger.Infof("Postgres CDC source replicating slot %s from %s", datasource.Slot, state.start)
+
+	types := pgtype.NewMap()
+	relations := make(map[uint32]relation)
+	// received position, which is confirmed when every change before it is committed
+	received := state.start
+	inTransaction := false
+	// position in transaction being received, changes before skip were passed to data channel before reconnecting
+	index, skip := 0, 0
+	// last change is held back until next change, so the last change of transaction gets its position
+	var pending *rowChange
+	emitted := 0
+	emit := func(change rowChange, position lsn) error {
+		data, err := json.Marshal(change.row)
+		if err != nil {
+			return permanentError{errors.Wrap(err, "unable to encode row")}
+		}
+		operationTransformer := func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+			records, err := transformer(logger, data)
+			for i := range records {
+				records[i].Operation = change.operation
+			}
+			return records, err
+		}
+		checkpoint := ""
+		if position != 0 {
+			checkpoint = position.String()
+		}
+		if err := transformRecord(ctx, logger, datasource, data, checkpoint, operationTransformer, dataPipeline); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return permanentError{err}
+		}
+		emitted++
+		state.emitted++
+		if position != 0 {
+			state.lastPosition = position
+		}
+		return nil
+	}
+
+	nextStatus := time.Now().Add(standbyStatusInterval)
+	for {
+		if !time.Now().Before(nextStatus) {
+			if err := p.sendStatus(ctx, conn, datasource, state, received); err != nil {
+				return emitted, err
+			}
+			nextStatus = time.Now().Add(standbyStatusInterval)
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		msg, err := conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && pgconn.Timeout(err) {
+				continue
+			}
+			return emitted, err
+		}
+
+		var data []byte
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return emitted, pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyDone:
+			return emitted, nil
+		case *pgproto3.CopyData:
+			data = msg.Data
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		switch data[0] {
+		case primaryKeepaliveByteID:
+			keepalive, err := parsePrimaryKeepalive(data[1:])
+			if err != nil {
+				return emitted, permanentError{err}
+			}
+			// every change before keepalive position is received if no transaction is being received
+			if !inTransaction && keepalive.walEnd > received {
+				received = keepalive.walEnd
+			}
+			if keepalive.replyRequested {
+				nextStatus = time.Time{}
+			}
+		case xLogDataByteID:
+			xLogData, err := parseXLogData(data[1:])
+			if err != nil {
+				return emitted, permanentError{err}
+			}
+			m, err := parsePgoutput(xLogData.walData)
+			if err != nil {
+				return emitted, permanentError{err}
+			}
+
+			switch m.kind {
+			case 'B':
+				inTransaction = true
+				index, skip = 0, 0
+				if m.lsn == state.transaction {
+					skip = state.emitted
+				} else {
+					state.transaction, state.emitted = m.lsn, 0
+				}
+			case 'R':
+				relations[m.relationID] = m.relation
+			case 'I', 'U', 'D':
+				r, ok := relations[m.relationID]
+				if !ok {
+					return emitted, permanentError{errors.Errorf("relation %d of change not received", m.relationID)}
+				}
+				if datasource.Table != "" && !r.is(datasource.Table) {
+					continue
+				}
+				changes, err := r.changes(m, types)
+				if err != nil {
+					return emitted, permanentError{err}
+				}
+				for i := range changes {
+					if pending != nil {
+						if err := emit(*pending, 0); err != nil {
+							return emitted, err
+						}
+						pending = nil
+					}
+					if index++; index > skip {
+						pending = &changes[i]
+					}
+				}
+			case 'C':
+				if pending != nil {
+					if err := emit(*pending, m.lsn); err != nil {
+						return emitted, err
+					}
+					pending = nil
+				}
+				inTransaction = false
+				state.start, received = m.lsn, m.lsn
+				state.transaction, state.emitted = 0, 0
+			case 'T':
+				logger.Warningf("truncate is not replicated")
+			}
+		}
+	}
+}
+
+// confirm position to server, the position is the received position if every change passed to data channel is committed,
+// otherwise the committed position (checkpoint)
+func (p *PostgresCDCExtraction) sendStatus(ctx context.Context, conn *pgconn.PgConn, datasource config.DataSourceConfig, state *replicationState, received lsn) error {
+	position, err := p.checkpoint.Load(ctx, datasource.Name)
+	if err != nil {
+		return err
+	}
+	flushed := lsn(0)
+	if position != "" {
+		if flushed, err = parseLSN(position); err != nil {
+			return permanentError{err}
+		}
+	}
+	if flushed >= state.lastPosition {
+		flushed = received
+	}
+
+	conn.Frontend().Send(&pgproto3.CopyData{Data: standbyStatusUpdate(received, flushed, time.Now())})
+	return conn.Frontend().Flush()
+}
+
+// connect to database of source in replication mode
+// connection error is redacted as it may contain connection string with password
+func connectReplication(ctx context.Context, datasource config.DataSourceConfig) (*pgconn.PgConn, error) {
+	c, err := pgconn.ParseConfig(datasource.Source)
+	if err != nil {
+		return nil, permanentError{errors.New("unable to parse postgres-cdc source connection string")}
+	}
+	c.RuntimeParams["replication"] = "database"
+
+	conn, err := pgconn.ConnectConfig(ctx, c)
+	if err != nil {
+		message := strings.ReplaceAll(err.Error(), datasource.Source, utils.RedactConnectionString(datasource.Source))
+		return nil, errors.New(utils.RedactSecrets(message, []string{c.Password}))
+	}
+	return conn, nil
+}
@@ -0,0 +1,490 @@
+package extraction_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestPostgresCDCExtract(t *testing.T) {
+	type testcase struct {
+		testcase   string
+		checkpoint string
+		table      string
+		// pgoutput messages of each connection, connection is dropped after its messages except the last one
+		connections           [][][]byte
+		expectedOperations    []string
+		expectedIDs           []string
+		expectedPositions     []string
+		expectedStartCommands []string
+		expectedError         bool
+	}
+
+	orders := pgRelation(1, "orders", pgColumn{"order_id", true, pgtype.Int4OID}, pgColumn{"status", false, pgtype.TextOID})
+	audit := pgRelation(2, "audit", pgColumn{"audit_id", true, pgtype.Int4OID}, pgColumn{"status", false, pgtype.TextOID})
+	testcases := []testcase{
+		{
+			testcase: "Insert, update and delete",
+			connections: [][][]byte{{
+				orders, pgBegin(0x110), pgInsert(1, "1", "new"), pgCommit(0x110),
+				pgBegin(0x210), pgUpdate(1, nil, "1", "paid"), pgDelete(1, "1", nil), pgCommit(0x210),
+			}},
+			expectedOperations:    []string{"insert", "update", "delete"},
+			expectedIDs:           []string{"1", "1", "1"},
+			expectedPositions:     []string{"0/110", "", "0/210"},
+			expectedStartCommands: []string{"START_REPLICATION SLOT etl_orders LOGICAL 0/0 (proto_version '1', publication_names 'etl')"},
+		},
+		{
+			testcase:   "Key update from checkpoint",
+			checkpoint: "1/110",
+			connections: [][][]byte{{
+				orders, pgBegin(0x100000210), pgUpdate(1, []interface{}{"1", nil}, "2", "paid"), pgCommit(0x100000210),
+			}},
+			expectedOperations:    []string{"delete", "update"},
+			expectedIDs:           []string{"1", "2"},
+			expectedPositions:     []string{"", "1/210"},
+			expectedStartCommands: []string{"START_REPLICATION SLOT etl_orders LOGICAL 1/110 (proto_version '1', publication_names 'etl')"},
+		},
+		{
+			testcase: "Changes of other table ignored",
+			table:    "public.orders",
+			connections: [][][]byte{{
+				orders, audit, pgBegin(0x110), pgInsert(1, "1", "new"), pgInsert(2, "7", "new"), pgCommit(0x110),
+			}},
+			expectedOperations:    []string{"insert"},
+			expectedIDs:           []string{"1"},
+			expectedPositions:     []string{"0/110"},
+			expectedStartCommands: []string{"START_REPLICATION SLOT etl_orders LOGICAL 0/0 (proto_version '1', publication_names 'etl')"},
+		},
+		{
+			testcase: "Transaction sent again after reconnecting",
+			connections: [][][]byte{
+				{orders, pgBegin(0x110), pgInsert(1, "1", "new"), pgCommit(0x110), pgBegin(0x210), pgInsert(1, "2", "new"), pgInsert(1, "3", "new")},
+				{orders, pgBegin(0x210), pgInsert(1, "2", "new"), pgInsert(1, "3", "new"), pgCommit(0x210)},
+			},
+			expectedOperations: []string{"insert", "insert", "insert"},
+			expectedIDs:        []string{"1", "2", "3"},
+			expectedPositions:  []string{"0/110", "", "0/210"},
+			expectedStartCommands: []string{
+				"START_REPLICATION SLOT etl_orders LOGICAL 0/0 (proto_version '1', publication_names 'etl')",
+				"START_REPLICATION SLOT etl_orders LOGICAL 0/110 (proto_version '1', publication_names 'etl')",
+			},
+		},
+		{
+			testcase: "Unchanged TOAST value",
+			connections: [][][]byte{{
+				orders, pgBegin(0x110), pgUpdate(1, nil, "1", pgUnchanged), pgCommit(0x110),
+			}},
+			expectedStartCommands: []string{"START_REPLICATION SLOT etl_orders LOGICAL 0/0 (proto_version '1', publication_names 'etl')"},
+			expectedError:         true,
+		},
+		{
+			testcase:              "Replication rejected",
+			expectedStartCommands: []string{"START_REPLICATION SLOT etl_orders LOGICAL 0/0 (proto_version '1', publication_names 'etl')"},
+			expectedError:         true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newPostgresCDCDependencies(t, tc.connections)
+			if tc.checkpoint != "" {
+				dep.checkpoint.Save(context.Background(), dep.datasource.Name, tc.checkpoint)
+			}
+			dep.datasource.Table = tc.table
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dataChan := make(chan transformation.Record, 10)
+			extractErr := make(chan error, 1)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				extractErr <- dep.cdcExtraction.Extract(ctx, dep.datasource, transformation.FieldsTransformer, dataChan, &wg)
+			}()
+
+			var operations, ids, positions []string
+			var err error
+			stopped := false
+			// extraction is stopped once expected records are extracted unless it is expected to fail
+			for !stopped && (tc.expectedError || len(operations) < len(tc.expectedOperations)) {
+				select {
+				case record := <-dataChan:
+					operations = append(operations, record.Operation)
+					ids = append(ids, string(record.Get("order_id").(json.Number)))
+					positions = append(positions, record.Metadata.Position)
+				case err = <-extractErr:
+					stopped = true
+				case <-time.After(5 * time.Second):
+					t.Fatalf("expected %v records, but got %v", len(tc.expectedOperations), len(operations))
+				}
+			}
+			if !stopped {
+				cancel()
+				err = <-extractErr
+			}
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+			} else if err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+
+			if !reflect.DeepEqual(operations, tc.expectedOperations) {
+				t.Errorf("expected %v, but got %v", tc.expectedOperations, operations)
+			}
+			if !reflect.DeepEqual(ids, tc.expectedIDs) {
+				t.Errorf("expected %v, but got %v", tc.expectedIDs, ids)
+			}
+			if !reflect.DeepEqual(positions, tc.expectedPositions) {
+				t.Errorf("expected positions %v, but got %v", tc.expectedPositions, positions)
+			}
+			if commands := dep.server.startCommands(); !reflect.DeepEqual(commands, tc.expectedStartCommands) {
+				t.Errorf("expected %v, but got %v", tc.expectedStartCommands, commands)
+			}
+		})
+	}
+}
+
+func TestPostgresCDCExtractValues(t *testing.T) {
+	orders := pgRelation(1, "orders",
+		pgColumn{"order_id", true, pgtype.Int8OID},
+		pgColumn{"total", false, pgtype.NumericOID},
+		pgColumn{"paid", false, pgtype.BoolOID},
+		pgColumn{"placed_at", false, pgtype.TimestamptzOID},
+		pgColumn{"note", false, pgtype.TextOID},
+	)
+	dep := newPostgresCDCDependencies(t, [][][]byte{{
+		orders, pgBegin(0x110), pgInsert(1, "1", "9.50", "t", "2024-01-02 03:04:05.5+02", nil), pgCommit(0x110),
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dataChan := make(chan transformation.Record, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go dep.cdcExtraction.Extract(ctx, dep.datasource, transformation.FieldsTransformer, dataChan, &wg)
+
+	select {
+	case record := <-dataChan:
+		expected := map[string]interface{}{
+			"order_id":  json.Number("1"),
+			"total":     json.Number("9.50"),
+			"paid":      true,
+			"placed_at": "2024-01-02T01:04:05.5Z",
+			"note":      nil,
+		}
+		if !reflect.DeepEqual(record.Fields, expected) {
+			t.Errorf("expected %v, but got %v", expected, record.Fields)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected record to be extracted")
+	}
+}
+
+func TestPostgresCDCConfirm(t *testing.T) {
+	orders := pgRelation(1, "orders", pgColumn{"order_id", true, pgtype.Int4OID}, pgColumn{"status", false, pgtype.TextOID})
+	dep := newPostgresCDCDependencies(t, [][][]byte{{
+		orders, pgBegin(0x110), pgInsert(1, "1", "new"), pgCommit(0x110),
+		pgBegin(0x210), pgInsert(1, "2", "new"), pgCommit(0x210),
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dataChan := make(chan transformation.Record, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go dep.cdcExtraction.Extract(ctx, dep.datasource, transformation.FieldsTransformer, dataChan, &wg)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-dataChan:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected record to be extracted")
+		}
+	}
+
+	type testcase struct {
+		testcase   string
+		checkpoint string
+		// position of keepalive asking for status
+		walEnd         uint64
+		expectedStatus [2]uint64
+	}
+
+	// slot is confirmed up to committed position, and up to received position once every record is committed
+	testcases := []testcase{
+		{
+			testcase:       "Nothing committed",
+			walEnd:         0x210,
+			expectedStatus: [2]uint64{0x210, 0},
+		},
+		{
+			testcase:       "First transaction committed",
+			checkpoint:     "0/110",
+			walEnd:         0x210,
+			expectedStatus: [2]uint64{0x210, 0x110},
+		},
+		{
+			testcase:       "Every transaction committed",
+			checkpoint:     "0/210",
+			walEnd:         0x300,
+			expectedStatus: [2]uint64{0x300, 0x300},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			if tc.checkpoint != "" {
+				dep.checkpoint.Save(context.Background(), dep.datasource.Name, tc.checkpoint)
+			}
+			dep.server.keepalives <- tc.walEnd
+			select {
+			case status := <-dep.server.statuses:
+				if status != tc.expectedStatus {
+					t.Errorf("expected %x, but got %x", tc.expectedStatus, status)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected standby status update")
+			}
+		})
+	}
+}
+
+// replication server sending pgoutput messages of each connection after replication is started
+// START_REPLICATION is rejected after every connection
+type replicationServer struct {
+	listener    net.Listener
+	connections [][][]byte
+	// keepalive position sent on the last connection, written and flushed position of each standby status update
+	keepalives chan uint64
+	statuses   chan [2]uint64
+
+	mu       sync.Mutex
+	commands []string
+	accepted int
+}
+
+func newReplicationServer(t *testing.T, connections [][][]byte) *replicationServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	s := &replicationServer{
+		listener:    listener,
+		connections: connections,
+		keepalives:  make(chan uint64),
+		statuses:    make(chan [2]uint64, 10),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *replicationServer) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	// cancel request is sent on a new connection when client closes a broken connection
+	msg, err := backend.ReceiveStartupMessage()
+	if _, ok := msg.(*pgproto3.StartupMessage); err != nil || !ok {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	backend.Flush()
+
+	s.mu.Lock()
+	i := s.accepted
+	s.accepted++
+	s.mu.Unlock()
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			if strings.HasPrefix(msg.String, "CREATE_REPLICATION_SLOT") {
+				if i == 0 {
+					backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("CREATE_REPLICATION_SLOT")})
+				} else {
+					backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42710", Message: "replication slot already exists"})
+				}
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+				backend.Flush()
+				continue
+			}
+
+			s.mu.Lock()
+			s.commands = append(s.commands, msg.String)
+			s.mu.Unlock()
+			if i >= len(s.connections) {
+				backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42704", Message: "publication does not exist"})
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+				backend.Flush()
+				continue
+			}
+			backend.Send(&pgproto3.CopyBothResponse{})
+			for _, message := range s.connections[i] {
+				backend.Send(&pgproto3.CopyData{Data: xLogData(message)})
+			}
+			backend.Flush()
+			if i < len(s.connections)-1 {
+				return
+			}
+			go func() {
+				for walEnd := range s.keepalives {
+					data := binary.BigEndian.AppendUint64([]byte{'k'}, walEnd)
+					data = binary.BigEndian.AppendUint64(data, 0)
+					backend.Send(&pgproto3.CopyData{Data: append(data, 1)})
+					backend.Flush()
+				}
+			}()
+		case *pgproto3.CopyData:
+			if len(msg.Data) == 34 && msg.Data[0] == 'r' {
+				s.statuses <- [2]uint64{binary.BigEndian.Uint64(msg.Data[1:]), binary.BigEndian.Uint64(msg.Data[9:])}
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+func (s *replicationServer) startCommands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// XLogData message of pgoutput message
+func xLogData(message []byte) []byte {
+	data := binary.BigEndian.AppendUint64([]byte{'w'}, 0)
+	data = binary.BigEndian.AppendUint64(data, 0)
+	data = binary.BigEndian.AppendUint64(data, 0)
+	return append(data, message...)
+}
+
+type pgColumn struct {
+	name    string
+	key     bool
+	typeOID uint32
+}
+
+// value of column which is unchanged TOAST value
+var pgUnchanged = struct{}{}
+
+func pgRelation(id uint32, name string, columns ...pgColumn) []byte {
+	data := binary.BigEndian.AppendUint32([]byte{'R'}, id)
+	data = append(data, "public\x00"+name+"\x00"...)
+	data = append(data, 'd')
+	data = binary.BigEndian.AppendUint16(data, uint16(len(columns)))
+	for _, column := range columns {
+		flags := byte(0)
+		if column.key {
+			flags = 1
+		}
+		data = append(data, flags)
+		data = append(data, column.name+"\x00"...)
+		data = binary.BigEndian.AppendUint32(data, column.typeOID)
+		data = binary.BigEndian.AppendUint32(data, 0xFFFFFFFF)
+	}
+	return data
+}
+
+func pgBegin(final uint64) []byte {
+	data := binary.BigEndian.AppendUint64([]byte{'B'}, final)
+	data = binary.BigEndian.AppendUint64(data, 0)
+	return binary.BigEndian.AppendUint32(data, 1)
+}
+
+func pgCommit(end uint64) []byte {
+	data := binary.BigEndian.AppendUint64([]byte{'C', 0}, end)
+	data = binary.BigEndian.AppendUint64(data, end)
+	return binary.BigEndian.AppendUint64(data, 0)
+}
+
+func pgInsert(id uint32, values ...interface{}) []byte {
+	data := binary.BigEndian.AppendUint32([]byte{'I'}, id)
+	return pgTuple(append(data, 'N'), values)
+}
+
+// update with old key if old is not nil
+func pgUpdate(id uint32, old []interface{}, values ...interface{}) []byte {
+	data := binary.BigEndian.AppendUint32([]byte{'U'}, id)
+	if old != nil {
+		data = pgTuple(append(data, 'K'), old)
+	}
+	return pgTuple(append(data, 'N'), values)
+}
+
+// delete of key, non-key column is nil
+func pgDelete(id uint32, old ...interface{}) []byte {
+	data := binary.BigEndian.AppendUint32([]byte{'D'}, id)
+	return pgTuple(append(data, 'K'), old)
+}
+
+// TupleData of nil (null), pgUnchanged or string (text) values
+func pgTuple(data []byte, values []interface{}) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(values)))
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			data = append(data, 't')
+			data = binary.BigEndian.AppendUint32(data, uint32(len(v)))
+			data = append(data, v...)
+		case nil:
+			data = append(data, 'n')
+		default:
+			data = append(data, 'u')
+		}
+	}
+	return data
+}
+
+type postgresCDCDependencies struct {
+	datasource    config.DataSourceConfig
+	checkpoint    checkpoint.Store
+	server        *replicationServer
+	cdcExtraction *extraction.PostgresCDCExtraction
+}
+
+func newPostgresCDCDependencies(t *testing.T, connections [][][]byte) postgresCDCDependencies {
+	store := checkpoint.NewMemoryStore()
+	server := newReplicationServer(t, connections)
+
+	return postgresCDCDependencies{
+		datasource: config.DataSourceConfig{
+			Name:           "orders-cdc",
+			Type:           "postgres-cdc",
+			Source:         "postgres://etl@" + server.listener.Addr().String() + "/shop?sslmode=disable",
+			Slot:           "etl_orders",
+			Publication:    "etl",
+			ReconnectDelay: 1,
+		},
+		checkpoint:    store,
+		server:        server,
+		cdcExtraction: extraction.NewPostgresCDCExtraction(newLogger(), store),
+	}
+}
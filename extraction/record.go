@@ -0,0 +1,63 @@
+package extraction
+
+import (
+	"context"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// transform raw data and push transformed records to data channel
+// raw data get a correlation id before transforming for tracing it in transform and load logs, records transformed from it share the id
+// position is the extraction position after this raw data which will be checkpointed once the records are committed
+// only last record of raw data carry the position, so checkpoint is not advanced until every record of raw data is committed
+// raw data may be transformed to zero or more records (e.g. dropped by transformation step)
+// return context error if context is cancelled before records are pushed
+func transformRecord(ctx context.Context, logger utils.Logger, datasource config.DataSourceConfig, rawData []byte, position string, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record) error {
+	return transformCheckpointedRecord(ctx, logger, datasource, rawData, "", position, transformer, dataPipeline)
+}
+
+// transform raw data like transformRecord, position is saved to checkpoint of the name instead of checkpoint of datasource
+func transformCheckpointedRecord(ctx context.Context, logger utils.Logger, datasource config.DataSourceConfig, rawData []byte, checkpoint string, position string, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record) error {
+	metadata := newRecordMetadata(datasource, "")
+	metadata.Checkpoint = checkpoint
+	logger = logger.WithFields(utils.Fields{utils.FieldRecordID: metadata.CorrelationID})
+	logger.Debugf("transforming raw data %s", rawData)
+
+	// transform data based on transformer function from params
+	records, err := transformer(logger, rawData)
+	if err != nil {
+		logger.Errorf("unable to transform data %v", err)
+		return err
+	}
+	if len(records) == 0 {
+		logger.Debugf("no record transformed from raw data")
+	}
+
+	for i, transformedData := range records {
+		transformedData.Metadata = metadata
+		if i == len(records)-1 {
+			transformedData.Metadata.Position = position
+		}
+
+		logger.Debugf("inserted data to channel %+v", transformedData)
+		// push transformed data to channel for storing data to storage
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case dataPipeline <- transformedData:
+		}
+	}
+
+	return nil
+}
+
+// metadata of extracted record with new correlation id
+func newRecordMetadata(datasource config.DataSourceConfig, position string) transformation.RecordMetadata {
+	return transformation.RecordMetadata{
+		CorrelationID: utils.NewCorrelationID(),
+		Datasource:    datasource.Name,
+		Position:      position,
+	}
+}
@@ -0,0 +1,202 @@
+package extraction
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// rows of each query if not configured
+	defaultChunkSize = 1000
+	// delay between queries after all rows are extracted if not configured
+	defaultPollInterval = 5 * time.Second
+)
+
+// rows of sql datasource query
+type SQLRows interface {
+	// rows after watermark (bound to :last_value) ordered by watermark column and limited to limit rows (bound to :limit)
+	// row is column name to value
+	Query(ctx context.Context, watermark string, limit int) ([]map[string]interface{}, error)
+	Close() error
+}
+
+// connect to database of sql datasource (e.g. NewSQLQuery)
+type SQLOpener func(ctx context.Context, datasource config.DataSourceConfig) (SQLRows, error)
+
+// incremental extraction of rows after high watermark from sql database
+type SQLExtraction struct {
+	logger     utils.Logger
+	checkpoint checkpoint.Store
+	open       SQLOpener
+}
+
+func NewSQLExtraction(logger utils.Logger, checkpoint checkpoint.Store, open SQLOpener) *SQLExtraction {
+	return &SQLExtraction{
+		logger:     logger,
+		checkpoint: checkpoint,
+		open:       open,
+	}
+}
+
+// extract function to query rows after watermark in chunks of ChunkSize rows
+// each row (JSON object of column to value) is transformed using transformer function in params and passed to data channel
+// watermark column value is the position, which is checkpointed after the records are committed
+// next chunk is queried immediately if chunk is full, otherwise after PollInterval (or stop if once)
+// stop without error when context is cancelled
+func (s *SQLExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := s.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("SQL source: %s", utils.RedactConnectionString(datasource.Source))
+
+	defer wg.Done()
+
+	watermark, err := s.checkpoint.Load(ctx, datasource.Name)
+	if err != nil {
+		logger.Errorf("unable to load checkpoint %v", err)
+		return err
+	}
+	if watermark == "" {
+		watermark = datasource.InitialWatermark
+	}
+	logger.Infof("SQL source start after %s %s", datasource.WatermarkColumn, watermark)
+
+	rows, err := s.open(ctx, datasource)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	defer rows.Close()
+
+	chunkSize := datasource.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	pollInterval := defaultPollInterval
+	if datasource.PollInterval > 0 {
+		pollInterval = time.Duration(datasource.PollInterval) * time.Millisecond
+	}
+
+	for {
+		chunk, err := rows.Query(ctx, watermark, chunkSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		full := len(chunk) == chunkSize
+
+		watermarks, err := rowWatermarks(chunk, datasource.WatermarkColumn)
+		if err != nil {
+			return err
+		}
+		// rows with watermark of last row of full chunk may continue in next chunk, so they are extracted with next chunk
+		if full {
+			last := len(chunk)
+			for last > 0 && watermarks[last-1] == watermarks[len(chunk)-1] {
+				last--
+			}
+			if last == 0 {
+				return fmt.Errorf("all %d rows of chunk have %s %s, increase ChunkSize", chunkSize, datasource.WatermarkColumn, watermarks[0])
+			}
+			chunk, watermarks = chunk[:last], watermarks[:last]
+		}
+
+		for i, row := range chunk {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return errors.Wrapf(err, "unable to encode row of %s %s", datasource.WatermarkColumn, watermarks[i])
+			}
+			// rows with same watermark are checkpointed after the last of them, so no row is skipped after restart
+			position := ""
+			if i == len(chunk)-1 || watermarks[i+1] != watermarks[i] {
+				position = watermarks[i]
+			}
+			if err := transformRecord(ctx, logger, datasource, data, position, transformer, dataPipeline); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+		if len(chunk) > 0 {
+			watermark = watermarks[len(chunk)-1]
+		}
+		if full {
+			continue
+		}
+
+		if datasource.Once {
+			logger.Infof("SQL source reached %s %s", datasource.WatermarkColumn, watermark)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// watermark of each row, column is matched case insensitively
+func rowWatermarks(rows []map[string]interface{}, column string) ([]string, error) {
+	watermarks := make([]string, len(rows))
+	for i, row := range rows {
+		found := false
+		for name, value := range row {
+			if strings.EqualFold(name, column) {
+				if value == nil {
+					return nil, fmt.Errorf("watermark column %s is null", column)
+				}
+				watermarks[i] = formatWatermark(value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("watermark column %s not found in query result", column)
+		}
+	}
+	return watermarks, nil
+}
+
+// watermark value as checkpoint position, time is formatted in RFC 3339 in UTC
+func formatWatermark(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// watermark position as query parameter, RFC 3339 time and integer are bound as time and integer
+func parseWatermark(watermark string) interface{} {
+	if t, err := time.Parse(time.RFC3339Nano, watermark); err == nil {
+		return t
+	}
+	if i, err := strconv.ParseInt(watermark, 10, 64); err == nil {
+		return i
+	}
+	return watermark
+}
@@ -0,0 +1,179 @@
+package extraction
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+// streaming extraction of Server-Sent Events (text/event-stream)
+type SSEExtraction struct {
+	logger     utils.Logger
+	checkpoint checkpoint.Store
+}
+
+func NewSSEExtraction(logger utils.Logger, checkpoint checkpoint.Store) *SSEExtraction {
+	return &SSEExtraction{
+		logger:     logger,
+		checkpoint: checkpoint,
+	}
+}
+
+// extract function to hold GET request to datasource url open
+// data of each event is transformed using transformer function in params and passed to data channel
+// id of last event is the position, which is sent in Last-Event-ID header when reconnecting or resuming from checkpoint
+// stop without error when context is cancelled
+func (s *SSEExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := s.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("SSE source: %s", config.RedactSecrets(datasource.Source))
+
+	defer wg.Done()
+
+	lastEventID, err := s.checkpoint.Load(ctx, datasource.Name)
+	if err != nil {
+		logger.Errorf("unable to load checkpoint %v", err)
+		return err
+	}
+	if lastEventID != "" {
+		logger.Infof("SSE source resume after event %s", lastEventID)
+	}
+
+	b := newBackoff(datasource)
+	return keepStreaming(ctx, logger, datasource, b, func(ctx context.Context) (int, error) {
+		return s.stream(ctx, logger, datasource, &lastEventID, b, transformer, dataPipeline)
+	})
+}
+
+// read events of a single connection until it ended
+// 204 response means server asked not to reconnect, 429 and 5xx response are retried and other response are not recovered
+func (s *SSEExtraction) stream(ctx context.Context, logger utils.Logger, datasource config.DataSourceConfig, lastEventID *string, b *backoff, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, datasource.Source, nil)
+	if err != nil {
+		return 0, permanentError{redactError(err)}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, redactError(err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return 0, errStreamEnd
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return 0, permanentError{fmt.Errorf("unexpected status %s", resp.Status)}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return 0, permanentError{fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))}
+	}
+	logger.Infof("SSE source connected")
+
+	events := newEventReader(resp.Body, *lastEventID)
+	received := 0
+	for {
+		data, err := events.next()
+		*lastEventID = events.lastEventID
+		if events.retry > 0 {
+			b.initial = events.retry
+		}
+		if err == io.EOF {
+			return received, nil
+		}
+		if err != nil {
+			return received, err
+		}
+
+		received++
+		if err := transformRecord(ctx, logger, datasource, data, *lastEventID, transformer, dataPipeline); err != nil {
+			return received, permanentError{err}
+		}
+	}
+}
+
+// reader of server-sent events (https://html.spec.whatwg.org/multipage/server-sent-events.html)
+// lines end with \n or \r\n, and event type is ignored
+type eventReader struct {
+	scanner *bufio.Scanner
+	// id of last event which is kept until another id is received
+	lastEventID string
+	// reconnection time requested by server (0 if not requested)
+	retry time.Duration
+}
+
+func newEventReader(r io.Reader, lastEventID string) *eventReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	return &eventReader{
+		scanner:     scanner,
+		lastEventID: lastEventID,
+	}
+}
+
+// read data of next event with data (data lines are joined by \n)
+// return io.EOF when stream ended, incomplete event at the end of stream is discarded
+func (e *eventReader) next() ([]byte, error) {
+	var data []byte
+	hasData := false
+	for e.scanner.Scan() {
+		line := e.scanner.Bytes()
+		// blank line dispatch event
+		if len(line) == 0 {
+			if len(data) > 0 {
+				return data, nil
+			}
+			hasData = false
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "":
+			// comment (e.g. keep alive)
+		case "data":
+			if hasData {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+			hasData = true
+			if len(data) > maxMessageSize {
+				return nil, permanentError{fmt.Errorf("event data is larger than %d bytes", maxMessageSize)}
+			}
+		case "id":
+			if bytes.IndexByte(value, 0) == -1 {
+				e.lastEventID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(string(value)); err == nil && ms > 0 {
+				e.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := e.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, permanentError{fmt.Errorf("event line is larger than %d bytes", maxMessageSize)}
+		}
+		return nil, err
+	}
+	return nil, io.EOF
+}
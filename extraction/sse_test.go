@@ -0,0 +1,197 @@
+package extraction_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+type sseResponse struct {
+	status      int
+	contentType string
+	body        string
+}
+
+func TestSSEExtract(t *testing.T) {
+	type testcase struct {
+		testcase    string
+		checkpoint  string
+		once        bool
+		transformer transformation.TransformFunc
+		// responses of each connection, server respond 204 after these responses
+		responses            []sseResponse
+		expectedNames        []string
+		expectedPositions    []string
+		expectedLastEventIDs []string
+		expectedError        bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Reconnect with last event id",
+			responses: []sseResponse{
+				{body: "id: 1\ndata: alice\n\nid: 2\ndata: bob\n\n"},
+				{body: "data: carol\n\n"},
+			},
+			expectedNames:        []string{"alice", "bob", "carol"},
+			expectedPositions:    []string{"1", "2", "2"},
+			expectedLastEventIDs: []string{"", "2", "2"},
+		},
+		{
+			testcase:             "Resume from checkpoint",
+			checkpoint:           "5",
+			responses:            []sseResponse{{body: "id: 6\ndata: dave\n\n"}},
+			expectedNames:        []string{"dave"},
+			expectedPositions:    []string{"6"},
+			expectedLastEventIDs: []string{"5", "6"},
+		},
+		{
+			testcase:             "Multi line data and comments",
+			responses:            []sseResponse{{body: ": keep alive\nretry: 1\nevent: user\ndata: al\r\ndata: ice\r\n\r\ndata\n\n: incomplete\ndata: bob"}},
+			expectedNames:        []string{"al\nice"},
+			expectedPositions:    []string{""},
+			expectedLastEventIDs: []string{"", ""},
+		},
+		{
+			testcase:             "Retry server error",
+			responses:            []sseResponse{{status: http.StatusServiceUnavailable}, {body: "id: 1\ndata: alice\n\n"}},
+			expectedNames:        []string{"alice"},
+			expectedPositions:    []string{"1"},
+			expectedLastEventIDs: []string{"", "", "1"},
+		},
+		{
+			testcase:             "Once stop at end of stream",
+			once:                 true,
+			responses:            []sseResponse{{body: "data: alice\n\n"}, {body: "data: bob\n\n"}},
+			expectedNames:        []string{"alice"},
+			expectedPositions:    []string{""},
+			expectedLastEventIDs: []string{""},
+		},
+		{
+			testcase:             "Not found",
+			responses:            []sseResponse{{status: http.StatusNotFound}},
+			expectedLastEventIDs: []string{""},
+			expectedError:        true,
+		},
+		{
+			testcase:             "Not event stream",
+			responses:            []sseResponse{{contentType: "application/json", body: "{}"}},
+			expectedLastEventIDs: []string{""},
+			expectedError:        true,
+		},
+		{
+			testcase:             "Transform error",
+			transformer:          jsonNameTransformer,
+			responses:            []sseResponse{{body: "data: {\n\n"}},
+			expectedLastEventIDs: []string{""},
+			expectedError:        true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			var mu sync.Mutex
+			lastEventIDs := []string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				i := len(lastEventIDs)
+				lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+				mu.Unlock()
+
+				if i >= len(tc.responses) {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				resp := tc.responses[i]
+				if resp.contentType == "" {
+					resp.contentType = "text/event-stream; charset=utf-8"
+				}
+				if resp.status == 0 {
+					resp.status = http.StatusOK
+				}
+				w.Header().Set("Content-Type", resp.contentType)
+				w.WriteHeader(resp.status)
+				io.WriteString(w, resp.body)
+			}))
+			defer server.Close()
+
+			store := checkpoint.NewMemoryStore()
+			datasource := config.DataSourceConfig{Name: "events", Type: "sse", Source: server.URL, Once: tc.once, ReconnectDelay: 1}
+			if tc.checkpoint != "" {
+				store.Save(context.Background(), datasource.Name, tc.checkpoint)
+			}
+			transformer := tc.transformer
+			if transformer == nil {
+				transformer = nameTransformer
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			dataChan := make(chan transformation.Record, 10)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			err := extraction.NewSSEExtraction(newLogger(), store).Extract(ctx, datasource, transformer, dataChan, &wg)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+			} else if err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+			close(dataChan)
+
+			var names, positions []string
+			for data := range dataChan {
+				names = append(names, data.String("FirstName"))
+				positions = append(positions, data.Metadata.Position)
+			}
+			if !reflect.DeepEqual(names, tc.expectedNames) {
+				t.Errorf("expected %v, but got %v", tc.expectedNames, names)
+			}
+			if !reflect.DeepEqual(positions, tc.expectedPositions) {
+				t.Errorf("expected positions %v, but got %v", tc.expectedPositions, positions)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(lastEventIDs, tc.expectedLastEventIDs) {
+				t.Errorf("expected Last-Event-ID %v, but got %v", tc.expectedLastEventIDs, lastEventIDs)
+			}
+		})
+	}
+}
+
+func TestSSEExtractRedactSecret(t *testing.T) {
+	t.Setenv("TEST_SSE_KEY", "ssesecretkey")
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"Datasource": [{"name": "events", "type": "sse", "source": "http://[::1/events?key=${env:TEST_SSE_KEY}"}]}`), 0o644)
+	c, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	dataChan := make(chan transformation.Record, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	err = extraction.NewSSEExtraction(newLogger(), checkpoint.NewMemoryStore()).Extract(context.Background(), c.Datasource[0], nameTransformer, dataChan, &wg)
+	if err == nil {
+		t.Fatalf("expected error but got nil")
+	}
+	// malformed url is echoed in error
+	if strings.Contains(err.Error(), "ssesecretkey") || !strings.Contains(err.Error(), utils.Redacted) {
+		t.Errorf("expected secret to be redacted, but got %v", err)
+	}
+}
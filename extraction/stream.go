@@ -0,0 +1,106 @@
+package extraction
+
+import (
+	"context"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// delay before reconnecting stream if not configured
+	defaultReconnectDelay = time.Second
+	// max delay before reconnecting stream
+	maxReconnectDelay = 30 * time.Second
+	// max bytes of a stream message (sse event data or websocket message)
+	maxMessageSize = 16 << 20
+)
+
+// stream is closed by server which asked not to reconnect (e.g. sse 204 response)
+var errStreamEnd = errors.New("stream closed by server")
+
+// stream error which is not recovered by reconnecting (e.g. rejected request or transform error)
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// connect to stream and transform its messages until it ended
+// return number of messages received and error which stopped the stream (nil if stream ended normally)
+type streamFunc func(ctx context.Context) (int, error)
+
+// delay between reconnecting a stream
+type backoff struct {
+	// delay after a connection received messages
+	initial time.Duration
+	// delay after a connection without message, doubled after each attempt up to maxReconnectDelay
+	current time.Duration
+}
+
+func newBackoff(datasource config.DataSourceConfig) *backoff {
+	initial := defaultReconnectDelay
+	if datasource.ReconnectDelay > 0 {
+		initial = time.Duration(datasource.ReconnectDelay) * time.Millisecond
+	}
+	return &backoff{
+		initial: initial,
+		current: initial,
+	}
+}
+
+// delay before next connection
+func (b *backoff) next(received bool) time.Duration {
+	if received {
+		b.current = b.initial
+	}
+	delay := b.current
+	b.current = min(b.current*2, maxReconnectDelay)
+	return delay
+}
+
+// keep stream connected until context is cancelled
+// stream is reconnected after backoff delay when it ended or failed, except permanent error and stream closed by server
+// once datasource stop after stream ended normally
+// stop without error when context is cancelled
+func keepStreaming(ctx context.Context, logger utils.Logger, datasource config.DataSourceConfig, b *backoff, stream streamFunc) error {
+	for {
+		received, err := stream(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if errors.Is(err, errStreamEnd) {
+			logger.Infof("stream closed by server")
+			return nil
+		}
+		if err == nil && datasource.Once {
+			logger.Infof("stream ended")
+			return nil
+		}
+
+		delay := b.next(received > 0)
+		if err != nil {
+			logger.Warningf("stream disconnected %v, reconnecting in %s", err, delay)
+		} else {
+			logger.Infof("stream ended, reconnecting in %s", delay)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
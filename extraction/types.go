@@ -1,5 +0,0 @@
-package extraction
-
-import "errors"
-
-var errorNotImplemented = errors.New("not yet implemented")
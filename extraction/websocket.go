@@ -0,0 +1,84 @@
+package extraction
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// time for close message to be written when datasource is stopped
+const websocketCloseTimeout = time.Second
+
+// streaming extraction of WebSocket messages
+type WebSocketExtraction struct {
+	logger utils.Logger
+}
+
+func NewWebSocketExtraction(logger utils.Logger) *WebSocketExtraction {
+	return &WebSocketExtraction{
+		logger: logger,
+	}
+}
+
+// extract function to hold WebSocket connection to datasource url open
+// each text or binary message is transformed using transformer function in params and passed to data channel
+// messages have no position, so stream is not resumed after reconnecting
+// stop without error when context is cancelled
+func (w *WebSocketExtraction) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	logger := w.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("WebSocket source: %s", config.RedactSecrets(datasource.Source))
+
+	defer wg.Done()
+
+	return keepStreaming(ctx, logger, datasource, newBackoff(datasource), func(ctx context.Context) (int, error) {
+		return w.stream(ctx, logger, datasource, transformer, dataPipeline)
+	})
+}
+
+// read messages of a single connection until it is closed
+// normal closure means stream ended, handshake rejected with 4xx status (except 429) is not recovered
+func (w *WebSocketExtraction) stream(ctx context.Context, logger utils.Logger, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record) (int, error) {
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, datasource.Source, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			return 0, permanentError{errors.Wrapf(redactError(err), "unexpected status %s", resp.Status)}
+		}
+		return 0, redactError(err)
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxMessageSize)
+	logger.Infof("WebSocket source connected")
+
+	// close connection to stop reading when context is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(websocketCloseTimeout))
+		conn.Close()
+	})
+	defer stop()
+
+	received := 0
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return received, nil
+			}
+			if errors.Is(err, websocket.ErrReadLimit) {
+				return received, permanentError{errors.Errorf("message is larger than %d bytes", maxMessageSize)}
+			}
+			return received, err
+		}
+
+		received++
+		if err := transformRecord(ctx, logger, datasource, data, "", transformer, dataPipeline); err != nil {
+			return received, permanentError{err}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package extraction_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/gorilla/websocket"
+)
+
+type websocketConnection struct {
+	messages []string
+	// close code sent after messages, connection is dropped without close message if 0
+	closeCode int
+}
+
+func TestWebSocketExtract(t *testing.T) {
+	type testcase struct {
+		testcase    string
+		once        bool
+		transformer transformation.TransformFunc
+		// messages of each connection, server reject handshake after these connections
+		connections         []websocketConnection
+		expectedNames       []string
+		expectedConnections int
+		expectedError       bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Reconnect after connection dropped",
+			once:     true,
+			connections: []websocketConnection{
+				{messages: []string{"alice", "bob"}},
+				{messages: []string{"carol"}, closeCode: websocket.CloseNormalClosure},
+			},
+			expectedNames:       []string{"alice", "bob", "carol"},
+			expectedConnections: 2,
+		},
+		{
+			testcase: "Reconnect after server going away",
+			once:     true,
+			connections: []websocketConnection{
+				{messages: []string{"alice"}, closeCode: websocket.CloseGoingAway},
+				{messages: []string{"bob"}, closeCode: websocket.CloseNormalClosure},
+			},
+			expectedNames:       []string{"alice", "bob"},
+			expectedConnections: 2,
+		},
+		{
+			testcase:            "Reconnect after end of stream until rejected",
+			connections:         []websocketConnection{{messages: []string{"alice"}, closeCode: websocket.CloseNormalClosure}},
+			expectedNames:       []string{"alice"},
+			expectedConnections: 2,
+			expectedError:       true,
+		},
+		{
+			testcase:            "Transform error",
+			transformer:         jsonNameTransformer,
+			connections:         []websocketConnection{{messages: []string{"{"}}},
+			expectedConnections: 1,
+			expectedError:       true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			// hijacked connection may outlive subtest, so handler must not read tc
+			serverConnections := tc.connections
+			var mu sync.Mutex
+			connections := 0
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				i := connections
+				connections++
+				mu.Unlock()
+
+				if i >= len(serverConnections) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				for _, message := range serverConnections[i].messages {
+					conn.WriteMessage(websocket.TextMessage, []byte(message))
+				}
+				if serverConnections[i].closeCode != 0 {
+					conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(serverConnections[i].closeCode, ""))
+				}
+			}))
+			defer server.Close()
+
+			datasource := config.DataSourceConfig{Name: "messages", Type: "websocket", Source: "ws" + strings.TrimPrefix(server.URL, "http"), Once: tc.once, ReconnectDelay: 1}
+			transformer := tc.transformer
+			if transformer == nil {
+				transformer = nameTransformer
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			dataChan := make(chan transformation.Record, 10)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			err := extraction.NewWebSocketExtraction(newLogger()).Extract(ctx, datasource, transformer, dataChan, &wg)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+			} else if err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+			close(dataChan)
+
+			var names []string
+			for data := range dataChan {
+				names = append(names, data.String("FirstName"))
+			}
+			if !reflect.DeepEqual(names, tc.expectedNames) {
+				t.Errorf("expected %v, but got %v", tc.expectedNames, names)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if connections != tc.expectedConnections {
+				t.Errorf("expected %v connections, but got %v", tc.expectedConnections, connections)
+			}
+		})
+	}
+}
+
+func TestWebSocketExtractCancel(t *testing.T) {
+	closed := make(chan int, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte("alice"))
+		// wait until client close connection
+		_, _, err = conn.ReadMessage()
+		if closeErr, ok := err.(*websocket.CloseError); ok {
+			closed <- closeErr.Code
+		}
+	}))
+	defer server.Close()
+
+	datasource := config.DataSourceConfig{Name: "messages", Type: "websocket", Source: "ws" + strings.TrimPrefix(server.URL, "http")}
+	dataChan := make(chan transformation.Record, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	extractErr := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		extractErr <- extraction.NewWebSocketExtraction(newLogger()).Extract(ctx, datasource, nameTransformer, dataChan, &wg)
+	}()
+
+	if record := <-dataChan; record.String("FirstName") != "alice" {
+		t.Errorf("expected %v, but got %v", "alice", record.String("FirstName"))
+	}
+	cancel()
+	if err := <-extractErr; err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+	select {
+	case code := <-closed:
+		if code != websocket.CloseNormalClosure {
+			t.Errorf("expected %v, but got %v", websocket.CloseNormalClosure, code)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected close message to be sent")
+	}
+}
@@ -0,0 +1,49 @@
+package loading
+
+import (
+	"context"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// durable queue which records can be acknowledged after committed
+type acknowledger interface {
+	Ack(offset int64) error
+}
+
+// repository wrapper which acknowledge durable queue offset
+// only after the records are committed by the wrapped repository
+// unacknowledged records are replayed from the queue after restart
+type AckRepository struct {
+	repo   Repository
+	queue  acknowledger
+	logger utils.Logger
+}
+
+func NewAckRepository(repo Repository, queue acknowledger, logger utils.Logger) *AckRepository {
+	return &AckRepository{
+		repo:   repo,
+		queue:  queue,
+		logger: logger,
+	}
+}
+
+func (a *AckRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	if err := a.repo.Write(ctx, entity, records); err != nil {
+		return err
+	}
+
+	if len(records) != 0 {
+		// records are consumed from queue in order, so the last one contain the latest offset
+		a.ack(records[len(records)-1].Metadata.QueueOffset)
+	}
+	return nil
+}
+
+// failure is only logged as records will be replayed from previous acknowledged offset after restart
+func (a *AckRepository) ack(offset int64) {
+	if err := a.queue.Ack(offset); err != nil {
+		a.logger.Errorf("unable to acknowledge queue offset %d %v", offset, err)
+	}
+}
@@ -0,0 +1,57 @@
+package loading
+
+import (
+	"context"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// repository wrapper which save extraction position to checkpoint store
+// only after the records are committed by the wrapped repository (at-least-once delivery)
+type CheckpointRepository struct {
+	repo   Repository
+	store  checkpoint.Store
+	logger utils.Logger
+}
+
+func NewCheckpointRepository(repo Repository, store checkpoint.Store, logger utils.Logger) *CheckpointRepository {
+	return &CheckpointRepository{
+		repo:   repo,
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (c *CheckpointRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	if err := c.repo.Write(ctx, entity, records); err != nil {
+		return err
+	}
+
+	c.commit(ctx, records)
+	return nil
+}
+
+// save latest position of each checkpoint in committed records
+// failure is only logged as records will be extracted again from previous checkpoint after restart
+func (c *CheckpointRepository) commit(ctx context.Context, records []transformation.Record) {
+	// records of same checkpoint are in extraction order, so the last one contain the latest position
+	positions := make(map[string]transformation.RecordMetadata)
+	for _, record := range records {
+		if record.Metadata.Position == "" {
+			continue
+		}
+		name := record.Metadata.Checkpoint
+		if name == "" {
+			name = record.Metadata.Datasource
+		}
+		positions[name] = record.Metadata
+	}
+
+	for name, metadata := range positions {
+		if err := c.store.Save(ctx, name, metadata.Position); err != nil {
+			c.logger.WithFields(utils.Fields{utils.FieldDatasource: metadata.Datasource}).Errorf("unable to save checkpoint %s %s %v", name, metadata.Position, err)
+		}
+	}
+}
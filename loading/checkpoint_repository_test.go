@@ -0,0 +1,101 @@
+package loading_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/checkpoint"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCheckpointRepository(t *testing.T) {
+	type testcase struct {
+		testcase          string
+		records           []transformation.Record
+		repoErr           error
+		expectedPositions map[string]string
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Latest position of each datasource",
+			records: []transformation.Record{
+				{Metadata: transformation.RecordMetadata{Datasource: "a", Position: "1"}},
+				{Metadata: transformation.RecordMetadata{Datasource: "b", Position: "10"}},
+				{Metadata: transformation.RecordMetadata{Datasource: "a", Position: "2"}},
+			},
+			expectedPositions: map[string]string{"a": "2", "b": "10"},
+		},
+		{
+			testcase: "Latest position of each checkpoint of datasource",
+			records: []transformation.Record{
+				{Metadata: transformation.RecordMetadata{Datasource: "a", Checkpoint: "a/0", Position: "1"}},
+				{Metadata: transformation.RecordMetadata{Datasource: "a", Checkpoint: "a/1", Position: "5"}},
+				{Metadata: transformation.RecordMetadata{Datasource: "a", Checkpoint: "a/0", Position: "2"}},
+			},
+			expectedPositions: map[string]string{"a": "", "a/0": "2", "a/1": "5"},
+		},
+		{
+			testcase: "Not checkpoint if repository failed",
+			records: []transformation.Record{
+				{Metadata: transformation.RecordMetadata{Datasource: "a", Position: "1"}},
+			},
+			repoErr:           errors.New("insert failed"),
+			expectedPositions: map[string]string{"a": ""},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newLoadingDependencies()
+			dep.repo.err = tc.repoErr
+			store := checkpoint.NewMemoryStore()
+			repo := loading.NewCheckpointRepository(dep.repo, store, utils.NewLogrusLogger(logrus.StandardLogger()))
+
+			err := repo.Write(context.Background(), "users", tc.records)
+			if err != tc.repoErr {
+				t.Errorf("expected error %v, but got %v", tc.repoErr, err)
+			}
+
+			for datasource, expected := range tc.expectedPositions {
+				position, _ := store.Load(context.Background(), datasource)
+				if position != expected {
+					t.Errorf("expected position %v of %v, but got %v", expected, datasource, position)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpointRepositorySplitPayload(t *testing.T) {
+	dep := newLoadingDependencies()
+	store := checkpoint.NewMemoryStore()
+	repo := loading.NewCheckpointRepository(dep.repo, store, utils.NewLogrusLogger(logrus.StandardLogger()))
+
+	// records of second payload are split across two writes by bulk insert size, only its last record carry the position
+	first := []transformation.Record{
+		{Metadata: transformation.RecordMetadata{Datasource: "a", Position: "1"}},
+		{Metadata: transformation.RecordMetadata{Datasource: "a"}},
+	}
+	second := []transformation.Record{
+		{Metadata: transformation.RecordMetadata{Datasource: "a", Position: "2"}},
+	}
+
+	if err := repo.Write(context.Background(), "users", first); err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+	dep.repo.err = errors.New("insert failed")
+	if err := repo.Write(context.Background(), "users", second); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+
+	// second payload is extracted again from position of first payload after restart
+	position, _ := store.Load(context.Background(), "a")
+	if position != "1" {
+		t.Errorf("expected position 1, but got %v", position)
+	}
+}
@@ -0,0 +1,35 @@
+package loading
+
+import (
+	"context"
+
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// deduplication filter of loaded records
+type committer interface {
+	Commit(records []transformation.Record)
+}
+
+// repository wrapper which add records to deduplication filter
+// only after the records are committed by the wrapped repository
+type DeduplicationRepository struct {
+	repo      Repository
+	committer committer
+}
+
+func NewDeduplicationRepository(repo Repository, committer committer) *DeduplicationRepository {
+	return &DeduplicationRepository{
+		repo:      repo,
+		committer: committer,
+	}
+}
+
+func (d *DeduplicationRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	if err := d.repo.Write(ctx, entity, records); err != nil {
+		return err
+	}
+
+	d.committer.Commit(records)
+	return nil
+}
@@ -0,0 +1,55 @@
+package loading_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+type committerMock struct {
+	committed []transformation.Record
+}
+
+func (c *committerMock) Commit(records []transformation.Record) {
+	c.committed = append(c.committed, records...)
+}
+
+func TestDeduplicationRepository(t *testing.T) {
+	type testcase struct {
+		testcase          string
+		repoErr           error
+		expectedCommitted int
+	}
+
+	testcases := []testcase{
+		{
+			testcase:          "Commit after records loaded",
+			expectedCommitted: 2,
+		},
+		{
+			testcase:          "Not commit if repository failed",
+			repoErr:           errors.New("insert failed"),
+			expectedCommitted: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newLoadingDependencies()
+			dep.repo.err = tc.repoErr
+			committer := &committerMock{}
+			repo := loading.NewDeduplicationRepository(dep.repo, committer)
+
+			err := repo.Write(context.Background(), "users", records("users", "users"))
+			if err != tc.repoErr {
+				t.Errorf("expected error %v, but got %v", tc.repoErr, err)
+			}
+			if len(committer.committed) != tc.expectedCommitted {
+				t.Errorf("expected %v, but got %v", tc.expectedCommitted, len(committer.committed))
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package loading
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+// archive sink appending records to file in JSON lines format
+// archived records can be loaded to database by replay command
+type FileRepository struct {
+	path   string
+	logger utils.Logger
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// file is created on first record
+func NewFileRepository(path string, logger utils.Logger) *FileRepository {
+	return &FileRepository{
+		path:   path,
+		logger: logger,
+	}
+}
+
+// records are synced to disk before returning so they are not lost once committed
+func (f *FileRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	lines := make([]byte, 0)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal record")
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return errors.Wrap(err, "unable to open archive file")
+		}
+		f.file = file
+	}
+
+	if _, err := f.file.Write(lines); err != nil {
+		return errors.Wrap(err, "unable to write archive file")
+	}
+	if err := f.file.Sync(); err != nil {
+		return errors.Wrap(err, "unable to sync archive file")
+	}
+
+	f.logger.Debugf("archived %d %s records", len(records), entity)
+	return nil
+}
+
+func (f *FileRepository) Close() error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
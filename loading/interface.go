@@ -6,7 +6,8 @@ import (
 	"github.com/awcjack/ETL-sample/transformation"
 )
 
-type repository interface {
-	AddUser(ctx context.Context, user transformation.TransformedData) error
-	AddUsers(ctx context.Context, users []transformation.TransformedData) error
+// datastore repository for storing transformed records
+// records are of the given entity, entity is mapped to table by Entities config
+type Repository interface {
+	Write(ctx context.Context, entity string, records []transformation.Record) error
 }
@@ -0,0 +1,131 @@
+package loading
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// headers of published messages
+const (
+	// entity of record
+	KafkaEntityHeader = "entity"
+	// operation of record (insert if missing)
+	KafkaOperationHeader = "operation"
+)
+
+// sink publishing records to kafka topic of their entity
+// records are published by idempotent producer and acknowledged by every in-sync replica before returning
+type KafkaRepository struct {
+	client   *kgo.Client
+	topic    string
+	format   string
+	keyField string
+	codecs   *transformation.AvroCodecs
+	logger   utils.Logger
+}
+
+// client connects to brokers on first write, avro codec of each entity is created from schemas if format is avro
+func NewKafkaRepository(c config.KafkaConfig, schemas transformation.Schemas, logger utils.Logger) (*KafkaRepository, error) {
+	k := &KafkaRepository{
+		topic:    c.Topic,
+		format:   c.Format,
+		keyField: c.KeyField,
+		logger:   logger,
+	}
+	if c.Format == "avro" {
+		codecs, err := transformation.NewAvroCodecs(schemas)
+		if err != nil {
+			return nil, err
+		}
+		k.codecs = codecs
+	}
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(utils.SplitBrokers(c.Brokers)...))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create kafka producer")
+	}
+	k.client = client
+	return k, nil
+}
+
+// publish records in order, records with same key are in same partition and keep their order
+// entity and operation of record are message headers
+func (k *KafkaRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	topic := strings.ReplaceAll(k.topic, "{entity}", entity)
+	messages := make([]*kgo.Record, 0, len(records))
+	for _, record := range records {
+		value, err := k.encode(record)
+		if err != nil {
+			return err
+		}
+		message := &kgo.Record{
+			Topic:   topic,
+			Key:     k.key(record),
+			Value:   value,
+			Headers: []kgo.RecordHeader{{Key: KafkaEntityHeader, Value: []byte(record.Entity)}},
+		}
+		if record.Operation != "" {
+			message.Headers = append(message.Headers, kgo.RecordHeader{Key: KafkaOperationHeader, Value: []byte(record.Operation)})
+		}
+		messages = append(messages, message)
+	}
+
+	if err := k.client.ProduceSync(ctx, messages...).FirstErr(); err != nil {
+		return errors.Wrapf(err, "unable to publish %d %s records to %s", len(records), entity, topic)
+	}
+	k.logger.Debugf("published %d %s records to %s", len(records), entity, topic)
+	return nil
+}
+
+// JSON of record in archive format or avro single object encoding of record fields
+func (k *KafkaRepository) encode(record transformation.Record) ([]byte, error) {
+	if k.codecs != nil {
+		return k.codecs.Encode(record)
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal record")
+	}
+	return value, nil
+}
+
+// value of key field, nil (no key) if record has no such field or it is empty
+func (k *KafkaRepository) key(record transformation.Record) []byte {
+	if k.keyField == "" {
+		return nil
+	}
+	switch value := record.Get(k.keyField).(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []byte(value)
+	case time.Time:
+		return []byte(transformation.FormatDate(value))
+	default:
+		return []byte(fmt.Sprint(value))
+	}
+}
+
+// close producer after every write returned
+func (k *KafkaRepository) Close() error {
+	if k == nil {
+		return nil
+	}
+	k.client.Close()
+	return nil
+}
@@ -0,0 +1,188 @@
+package loading_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKafkaRepositoryWrite(t *testing.T) {
+	type message struct {
+		key       string
+		operation string
+		fields    map[string]interface{}
+	}
+	type testcase struct {
+		testcase         string
+		config           config.KafkaConfig
+		records          []transformation.Record
+		expectedTopic    string
+		expectedMessages []message
+		expectedError    bool
+	}
+
+	placedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	testcases := []testcase{
+		{
+			testcase: "JSON keyed by field",
+			config:   config.KafkaConfig{Topic: "etl.{entity}", Format: "json", KeyField: "OrderId"},
+			records: []transformation.Record{
+				{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(1), "Email": "a@example.com", "PlacedAt": placedAt}},
+				{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(1)}, Operation: transformation.OperationDelete},
+			},
+			expectedTopic: "etl.orders",
+			expectedMessages: []message{
+				{key: "1", fields: map[string]interface{}{"OrderId": json.Number("1"), "Email": "a@example.com", "PlacedAt": "2024-01-02T03:04:05Z"}},
+				{key: "1", operation: transformation.OperationDelete, fields: map[string]interface{}{"OrderId": json.Number("1")}},
+			},
+		},
+		{
+			testcase: "Avro without key",
+			config:   config.KafkaConfig{Topic: "orders", Format: "avro", KeyField: "Email"},
+			records: []transformation.Record{
+				{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(2), "Email": "", "PlacedAt": placedAt}},
+			},
+			expectedTopic: "orders",
+			expectedMessages: []message{
+				{fields: map[string]interface{}{"OrderId": int64(2), "Email": "", "PlacedAt": placedAt}},
+			},
+		},
+		{
+			testcase: "Unknown topic",
+			config:   config.KafkaConfig{Topic: "missing", Format: "json"},
+			records: []transformation.Record{
+				{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(3)}},
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newKafkaRepositoryDependencies(t)
+			defer dep.cluster.Close()
+
+			tc.config.Enable, tc.config.Brokers = true, strings.Join(dep.cluster.ListenAddrs(), ",")
+			repo, err := loading.NewKafkaRepository(tc.config, dep.schemas, dep.logger)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			defer repo.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = repo.Write(ctx, "orders", tc.records)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+
+			published := dep.consume(ctx, t, tc.expectedTopic, len(tc.expectedMessages))
+			for i, expected := range tc.expectedMessages {
+				m := published[i]
+				if string(m.Key) != expected.key {
+					t.Errorf("expected key %v, but got %v", expected.key, string(m.Key))
+				}
+				headers := make(map[string]string)
+				for _, header := range m.Headers {
+					headers[header.Key] = string(header.Value)
+				}
+				if headers[loading.KafkaEntityHeader] != "orders" || headers[loading.KafkaOperationHeader] != expected.operation {
+					t.Errorf("expected %v orders message, but got %v", expected.operation, headers)
+				}
+
+				var fields map[string]interface{}
+				if tc.config.Format == "avro" {
+					record, err := dep.codecs.Decode(m.Value)
+					if err != nil {
+						t.Fatalf("not expected error, but got %v", err)
+					}
+					fields = record.Fields
+				} else {
+					var record transformation.Record
+					if err := json.Unmarshal(m.Value, &record); err != nil {
+						t.Fatalf("not expected error, but got %v", err)
+					}
+					fields = record.Fields
+				}
+				if !reflect.DeepEqual(fields, expected.fields) {
+					t.Errorf("expected %v, but got %v", expected.fields, fields)
+				}
+			}
+		})
+	}
+}
+
+type kafkaRepositoryDependencies struct {
+	cluster *kfake.Cluster
+	schemas transformation.Schemas
+	codecs  *transformation.AvroCodecs
+	logger  utils.Logger
+}
+
+func newKafkaRepositoryDependencies(t *testing.T) kafkaRepositoryDependencies {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{{
+		Name:  "orders",
+		Table: "orders",
+		Fields: []config.EntityFieldConfig{
+			{Name: "OrderId", Type: "int", Column: "order_id"},
+			{Name: "Email", Type: "string", Column: "email"},
+			{Name: "PlacedAt", Type: "date", Column: "placed_at"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	codecs, err := transformation.NewAvroCodecs(schemas)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "etl.orders"), kfake.SeedTopics(1, "orders"))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return kafkaRepositoryDependencies{
+		cluster: cluster,
+		schemas: schemas,
+		codecs:  codecs,
+		logger:  utils.NewLogrusLogger(logger),
+	}
+}
+
+// consume count messages of topic from start, messages of a key are in publish order
+func (d kafkaRepositoryDependencies) consume(ctx context.Context, t *testing.T, topic string, count int) []*kgo.Record {
+	client, err := kgo.NewClient(kgo.SeedBrokers(d.cluster.ListenAddrs()...), kgo.ConsumeTopics(topic), kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer client.Close()
+
+	messages := make([]*kgo.Record, 0, count)
+	for len(messages) < count {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			t.Fatalf("expected %d messages, but got %d", count, len(messages))
+		}
+		messages = append(messages, fetches.Records()...)
+	}
+	return messages
+}
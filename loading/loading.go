@@ -5,38 +5,141 @@ import (
 	"time"
 
 	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
 )
 
+const (
+	// delay before retrying first failed write
+	retryDelay = time.Second
+	// max delay before retrying failed write
+	maxRetryDelay = 30 * time.Second
+)
+
+// write error which is not recovered by retrying (e.g. record violating table constraint)
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// mark error of repository as not recovered by retrying, so loader stop instead of retrying the write
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// bulk insert settings which can be changed while loader is running
+type bulkInsertSettings struct {
+	size     int
+	interval int
+}
+
+// loader for saving data from data channel to repository
+type Loader struct {
+	repo               Repository
+	bulkInsert         bool
+	bulkInsertSize     int
+	bulkInsertInterval int
+	// pending bulk insert settings update
+	settings chan bulkInsertSettings
+	logger   utils.Logger
+}
+
+func NewLoader(repo Repository, bulkInsert bool, bulkInsertSize int, bulkInsertInterval int, logger utils.Logger) *Loader {
+	return &Loader{
+		repo:               repo,
+		bulkInsert:         bulkInsert,
+		bulkInsertSize:     bulkInsertSize,
+		bulkInsertInterval: bulkInsertInterval,
+		settings:           make(chan bulkInsertSettings, 1),
+		logger:             logger,
+	}
+}
+
+// change bulk insert size and interval of running loader without dropping buffered records
+// only the latest update is kept if loader didn't pick up previous update yet
+func (l *Loader) UpdateBulkInsert(bulkInsertSize int, bulkInsertInterval int) {
+	settings := bulkInsertSettings{
+		size:     bulkInsertSize,
+		interval: bulkInsertInterval,
+	}
+	for {
+		select {
+		case l.settings <- settings:
+			return
+		default:
+			// drop stale update
+			select {
+			case <-l.settings:
+			default:
+			}
+		}
+	}
+}
+
 // Saving data to specific repo
 // allow bulk insert or single insert
 // flush based on if slice didn't filled
-func SaveData(ctx context.Context, repo repository, dataPipeline <-chan transformation.TransformedData, bulkInsert bool, bulkInsertSize int, bulkInsertInterval int) error {
+// failed write is retried with backoff until it succeeded, so records are not taken from data channel during datastore downtime
+// return after flushing buffered records when data channel is closed, or error of write which is not recovered by retrying
+func (l *Loader) Run(ctx context.Context, dataPipeline <-chan transformation.Record) error {
 	var err error
-	if bulkInsert {
-		timer := time.NewTimer(time.Duration(bulkInsertInterval) * time.Second)
-		users := make([]transformation.TransformedData, 0, bulkInsertSize)
+	if l.bulkInsert {
+		timer := time.NewTimer(time.Duration(l.bulkInsertInterval) * time.Second)
+		records := make([]transformation.Record, 0, l.bulkInsertSize)
 		for {
 			select {
 			case <-ctx.Done():
 				return nil
+			case settings := <-l.settings:
+				l.bulkInsertSize = settings.size
+				l.bulkInsertInterval = settings.interval
+				// buffered records are kept and flushed if already reach new bulk insert size
+				if len(records) >= l.bulkInsertSize {
+					err = l.flush(ctx, records)
+					records = records[:0]
+					if err != nil {
+						return err
+					}
+				}
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(time.Duration(l.bulkInsertInterval) * time.Second)
 			case <-timer.C:
-				if len(users) != 0 {
-					err = repo.AddUsers(ctx, users)
-					users = users[:0]
+				if len(records) != 0 {
+					err = l.flush(ctx, records)
+					records = records[:0]
 					if err != nil {
 						return err
 					}
 				}
-				timer.Reset(time.Duration(bulkInsertInterval) * time.Second)
-			case data := <-dataPipeline:
-				users = append(users, data)
-				if len(users) >= bulkInsertSize {
-					err = repo.AddUsers(ctx, users)
-					users = users[:0]
+				timer.Reset(time.Duration(l.bulkInsertInterval) * time.Second)
+			case data, ok := <-dataPipeline:
+				// flush buffered records when data channel is closed
+				if !ok {
+					if len(records) != 0 {
+						return l.flush(ctx, records)
+					}
+					return nil
+				}
+				records = append(records, data)
+				if len(records) >= l.bulkInsertSize {
+					err = l.flush(ctx, records)
+					records = records[:0]
 					if err != nil {
 						return err
 					}
-					timer.Reset(time.Duration(bulkInsertInterval) * time.Second)
+					timer.Reset(time.Duration(l.bulkInsertInterval) * time.Second)
 				}
 			}
 		}
@@ -45,8 +148,11 @@ func SaveData(ctx context.Context, repo repository, dataPipeline <-chan transfor
 			select {
 			case <-ctx.Done():
 				return nil
-			case data := <-dataPipeline:
-				err = repo.AddUser(ctx, data)
+			case data, ok := <-dataPipeline:
+				if !ok {
+					return nil
+				}
+				err = l.write(ctx, data.Entity, []transformation.Record{data})
 				if err != nil {
 					return err
 				}
@@ -54,3 +160,48 @@ func SaveData(ctx context.Context, repo repository, dataPipeline <-chan transfor
 		}
 	}
 }
+
+// write buffered records to repo by entity
+// consecutive records of same entity are written together so records are committed in extraction order
+func (l *Loader) flush(ctx context.Context, records []transformation.Record) error {
+	start := 0
+	for i := 1; i <= len(records); i++ {
+		if i < len(records) && records[i].Entity == records[start].Entity {
+			continue
+		}
+		if err := l.write(ctx, records[start].Entity, records[start:i]); err != nil {
+			return err
+		}
+		start = i
+	}
+	return nil
+}
+
+// write records to repo, retry with exponential backoff until written, context is cancelled or error is permanent
+// records not written before context is cancelled are lost (or replayed from unacknowledged offset of disk queue after restart)
+func (l *Loader) write(ctx context.Context, entity string, records []transformation.Record) error {
+	delay := retryDelay
+	for {
+		err := l.repo.Write(ctx, entity, records)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
+		l.logger.Warningf("unable to write %d %s records %v, retrying in %s", len(records), entity, err, delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// Saving data to specific repo with fixed bulk insert settings
+func SaveData(ctx context.Context, repo Repository, dataPipeline <-chan transformation.Record, bulkInsert bool, bulkInsertSize int, bulkInsertInterval int, logger utils.Logger) error {
+	return NewLoader(repo, bulkInsert, bulkInsertSize, bulkInsertInterval, logger).Run(ctx, dataPipeline)
+}
@@ -2,51 +2,64 @@ package loading_test
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/awcjack/ETL-sample/loading"
 	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
 )
 
 func TestSaveData(t *testing.T) {
 	type testcase struct {
-		testcase             string
-		dataCount            int
-		bulkInsert           bool
-		bulkInsertSize       int
-		bulkInsertInterval   int
-		expectedRepoAddUser  int
-		expectedRepoAddUsers int
+		testcase           string
+		records            []transformation.Record
+		bulkInsert         bool
+		bulkInsertSize     int
+		bulkInsertInterval int
+		expectedRepoWrite  int
+		expectedEntities   []string
 	}
 
 	testcases := []testcase{
 		{
-			testcase:             "Single Insert",
-			dataCount:            5,
-			bulkInsert:           false,
-			bulkInsertSize:       10,
-			bulkInsertInterval:   10,
-			expectedRepoAddUser:  5,
-			expectedRepoAddUsers: 0,
+			testcase:           "Single Insert",
+			records:            records("users", "users", "users", "users", "users"),
+			bulkInsert:         false,
+			bulkInsertSize:     10,
+			bulkInsertInterval: 10,
+			expectedRepoWrite:  5,
+			expectedEntities:   []string{"users", "users", "users", "users", "users"},
 		},
 		{
-			testcase:             "Bulk Insert",
-			dataCount:            5,
-			bulkInsert:           true,
-			bulkInsertSize:       5,
-			bulkInsertInterval:   1000, // hard to test code based on time
-			expectedRepoAddUser:  0,
-			expectedRepoAddUsers: 1,
+			testcase:           "Bulk Insert",
+			records:            records("users", "users", "users", "users", "users"),
+			bulkInsert:         true,
+			bulkInsertSize:     5,
+			bulkInsertInterval: 1000, // hard to test code based on time
+			expectedRepoWrite:  1,
+			expectedEntities:   []string{"users"},
+		},
+		{
+			testcase:           "Bulk Insert of multiple entities in order",
+			records:            records("users", "users", "orders", "users"),
+			bulkInsert:         true,
+			bulkInsertSize:     4,
+			bulkInsertInterval: 1000,
+			expectedRepoWrite:  3,
+			expectedEntities:   []string{"users", "orders", "users"},
 		},
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.testcase, func(t *testing.T) {
 			dep := newLoadingDependencies()
-			dataChan := make(chan transformation.TransformedData, tc.dataCount)
-			for i := 0; i < tc.dataCount; i++ {
-				dataChan <- transformation.TransformedData{}
+			dataChan := make(chan transformation.Record, len(tc.records))
+			for _, record := range tc.records {
+				dataChan <- record
 			}
 
 			ctx, cancel := context.WithCancel(context.Background())
@@ -57,40 +70,175 @@ func TestSaveData(t *testing.T) {
 				cancel()
 			}()
 
-			loading.SaveData(ctx, dep.repo, dataChan, tc.bulkInsert, tc.bulkInsertSize, tc.bulkInsertInterval)
-			if dep.repo.CalledAddUser != tc.expectedRepoAddUser {
-				t.Errorf("expected called add user %v, but got %v", tc.expectedRepoAddUser, dep.repo.CalledAddUser)
+			loading.SaveData(ctx, dep.repo, dataChan, tc.bulkInsert, tc.bulkInsertSize, tc.bulkInsertInterval, dep.logger)
+			if dep.repo.CalledWrite != tc.expectedRepoWrite {
+				t.Errorf("expected called write %v, but got %v", tc.expectedRepoWrite, dep.repo.CalledWrite)
+			}
+			if !reflect.DeepEqual(dep.repo.entities, tc.expectedEntities) {
+				t.Errorf("expected %v, but got %v", tc.expectedEntities, dep.repo.entities)
 			}
-			if dep.repo.CalledAddUsers != tc.expectedRepoAddUsers {
-				t.Errorf("expected called add users %v, but got %v", tc.expectedRepoAddUsers, dep.repo.CalledAddUsers)
+			if len(dep.repo.records) != len(tc.records) {
+				t.Errorf("expected %v records, but got %v", len(tc.records), len(dep.repo.records))
 			}
 		})
 	}
 }
 
 type loadingDependencies struct {
-	repo *repoMock
+	repo   *repoMock
+	logger utils.Logger
 }
 
 func newLoadingDependencies() loadingDependencies {
 	repo := &repoMock{}
 
 	return loadingDependencies{
-		repo: repo,
+		repo:   repo,
+		logger: utils.NewLogrusLogger(logrus.StandardLogger()),
 	}
 }
 
 type repoMock struct {
-	CalledAddUser  int
-	CalledAddUsers int
+	CalledWrite int
+	entities    []string
+	records     []transformation.Record
+	err         error
+	// number of writes failed with failure error before writes succeed
+	failures int
+}
+
+func (r *repoMock) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	r.CalledWrite++
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("connection refused")
+	}
+	r.entities = append(r.entities, entity)
+	r.records = append(r.records, records...)
+	return r.err
 }
 
-func (r *repoMock) AddUser(ctx context.Context, user transformation.TransformedData) error {
-	r.CalledAddUser++
-	return nil
+// empty record of each entity
+func records(entities ...string) []transformation.Record {
+	records := make([]transformation.Record, 0, len(entities))
+	for _, entity := range entities {
+		records = append(records, transformation.NewRecord(entity))
+	}
+	return records
 }
 
-func (r *repoMock) AddUsers(ctx context.Context, user []transformation.TransformedData) error {
-	r.CalledAddUsers++
-	return nil
+func TestLoaderUpdateBulkInsert(t *testing.T) {
+	dep := newLoadingDependencies()
+	dataChan := make(chan transformation.Record, 5)
+	for _, record := range records("users", "users", "users") {
+		dataChan <- record
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loader := loading.NewLoader(dep.repo, true, 10, 1000, dep.logger)
+	done := make(chan struct{})
+	go func() {
+		loader.Run(ctx, dataChan)
+		close(done)
+	}()
+
+	// wait until loader buffered all records, then shrink bulk insert size below buffered count
+	time.Sleep(100 * time.Millisecond)
+	loader.UpdateBulkInsert(2, 1000)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	if dep.repo.CalledWrite != 1 {
+		t.Errorf("expected buffered records flushed once, but got %v", dep.repo.CalledWrite)
+	}
+	if len(dep.repo.records) != 3 {
+		t.Errorf("expected 3 records inserted without dropping buffered records, but got %v", len(dep.repo.records))
+	}
+}
+
+func TestLoaderFlushOnClose(t *testing.T) {
+	dep := newLoadingDependencies()
+	dataChan := make(chan transformation.Record, 3)
+	for _, record := range records("users", "users", "users") {
+		dataChan <- record
+	}
+	close(dataChan)
+
+	err := loading.NewLoader(dep.repo, true, 10, 1000, dep.logger).Run(context.Background(), dataChan)
+	if err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+	if len(dep.repo.records) != 3 {
+		t.Errorf("expected 3 buffered records flushed, but got %v", len(dep.repo.records))
+	}
+}
+
+func TestLoaderRetry(t *testing.T) {
+	type testcase struct {
+		testcase string
+		failures int
+		err      error
+		// context is cancelled while write is retried
+		cancel              bool
+		expectedCalledWrite int
+		expectedRecords     int
+		expectedError       bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase:            "Written after retry",
+			failures:            1,
+			expectedCalledWrite: 2,
+			expectedRecords:     3,
+		},
+		{
+			testcase:            "Permanent error not retried",
+			err:                 loading.Permanent(errors.New("violates not-null constraint")),
+			expectedCalledWrite: 1,
+			expectedRecords:     3,
+			expectedError:       true,
+		},
+		{
+			testcase:            "Stop retrying when cancelled",
+			failures:            100,
+			cancel:              true,
+			expectedCalledWrite: 1,
+			expectedRecords:     0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newLoadingDependencies()
+			dep.repo.failures = tc.failures
+			dep.repo.err = tc.err
+			dataChan := make(chan transformation.Record, 3)
+			for _, record := range records("users", "users", "users") {
+				dataChan <- record
+			}
+			close(dataChan)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+			err := loading.NewLoader(dep.repo, true, 10, 1000, dep.logger).Run(ctx, dataChan)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+			} else if err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+			if dep.repo.CalledWrite != tc.expectedCalledWrite {
+				t.Errorf("expected called write %v, but got %v", tc.expectedCalledWrite, dep.repo.CalledWrite)
+			}
+			if len(dep.repo.records) != tc.expectedRecords {
+				t.Errorf("expected %v records, but got %v", tc.expectedRecords, len(dep.repo.records))
+			}
+		})
+	}
 }
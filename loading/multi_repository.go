@@ -0,0 +1,28 @@
+package loading
+
+import (
+	"context"
+
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// repository writing records to every sink in order
+// records are committed only if every sink succeed, so sink may get duplicated records when they are retried (at-least-once delivery)
+type MultiRepository struct {
+	repos []Repository
+}
+
+func NewMultiRepository(repos ...Repository) *MultiRepository {
+	return &MultiRepository{
+		repos: repos,
+	}
+}
+
+func (m *MultiRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	for _, repo := range m.repos {
+		if err := repo.Write(ctx, entity, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
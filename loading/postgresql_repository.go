@@ -2,49 +2,102 @@ package loading
 
 import (
 	"context"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/awcjack/ETL-sample/config"
 	"github.com/awcjack/ETL-sample/transformation"
 	"github.com/awcjack/ETL-sample/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+// maximum number of bind parameters of a PostgreSQL statement
+const maxParameters = 65535
+
 type PostgreSQLRepository struct {
 	db     *sqlx.DB
+	tables map[string]table
 	logger utils.Logger
 }
 
-func NewPostgreSQLRepository(db *sqlx.DB, logger utils.Logger) *PostgreSQLRepository {
+// table of entity with columns in order of entity fields
+type table struct {
+	name    string
+	fields  []string
+	columns []string
+	// fields and columns of entity key in key order
+	keyFields  []string
+	keyColumns []string
+}
+
+// records of each entity are inserted to table of entity config
+// update and delete records are applied to rows with same entity key
+func NewPostgreSQLRepository(db *sqlx.DB, entities []config.EntityConfig, logger utils.Logger) *PostgreSQLRepository {
 	if db == nil {
 		logger.Panicf("missing db")
 	}
 
+	tables := make(map[string]table, len(entities))
+	for _, entity := range entities {
+		t := table{
+			// schema qualified table is quoted by parts (e.g. sales.orders -> "sales"."orders")
+			name:    pgx.Identifier(strings.Split(entity.Table, ".")).Sanitize(),
+			fields:  make([]string, 0, len(entity.Fields)),
+			columns: make([]string, 0, len(entity.Fields)),
+		}
+		for _, field := range entity.Fields {
+			t.fields = append(t.fields, field.Name)
+			t.columns = append(t.columns, pgx.Identifier{field.Column}.Sanitize())
+		}
+		for _, key := range entity.Key {
+			for i, field := range t.fields {
+				if strings.EqualFold(field, key) {
+					t.keyFields = append(t.keyFields, field)
+					t.keyColumns = append(t.keyColumns, t.columns[i])
+				}
+			}
+		}
+		tables[entity.Name] = t
+	}
+
 	return &PostgreSQLRepository{
 		db:     db,
+		tables: tables,
 		logger: logger,
 	}
 }
 
-type postgresqlUser struct {
-	FirstName     string    `db:"first_name"`
-	LastName      string    `db:"last_name"`
-	DateOfBirth   time.Time `db:"date_of_birth"`
-	City          string    `db:"city"`
-	StreetName    string    `db:"street_name"`
-	StreetAddress string    `db:"street_address"`
-	ZipCode       string    `db:"zip_code"`
-	State         string    `db:"state"`
-	Country       string    `db:"country"`
-	Latitude      float64   `db:"latitude"`
-	Longitude     float64   `db:"longitude"`
-}
+// write records to table of entity in a transaction in order
+// consecutive inserts are inserted by multi-row insert statements, split if exceeding bind parameters limit
+// update replaces the row with same key (inserted if not found) and delete removes the row with same key
+func (p *PostgreSQLRepository) Write(ctx context.Context, entity string, records []transformation.Record) (err error) {
+	if len(records) == 0 {
+		return nil
+	}
+	t, ok := p.tables[entity]
+	if !ok || len(t.columns) == 0 {
+		return Permanent(errors.Errorf("entity %s has no table", entity))
+	}
+	for _, record := range records {
+		switch record.Operation {
+		case "", transformation.OperationInsert:
+		case transformation.OperationUpdate, transformation.OperationDelete:
+			if len(t.keyColumns) == 0 {
+				return Permanent(errors.Errorf("entity %s has no Key to %s record", entity, record.Operation))
+			}
+		default:
+			return Permanent(errors.Errorf("%s record has unsupported operation %s", entity, record.Operation))
+		}
+	}
+
+	// every write get a batch id for grouping the records in load logs
+	batchLogger := p.logger.WithFields(utils.Fields{utils.FieldBatchID: utils.NewCorrelationID()})
 
-// insert
-func (p *PostgreSQLRepository) AddUser(ctx context.Context, user transformation.TransformedData) error {
 	tx, err := p.db.Beginx()
 	if err != nil {
 		return errors.Wrap(err, "unable to start transaction")
@@ -54,76 +107,143 @@ func (p *PostgreSQLRepository) AddUser(ctx context.Context, user transformation.
 		err = p.finishTransaction(err, tx)
 	}()
 
-	dbUser := postgresqlUser{
-		FirstName:     user.FirstName,
-		LastName:      user.LastName,
-		DateOfBirth:   user.DateOfBirth,
-		City:          user.Address.City,
-		StreetName:    user.Address.StreetName,
-		StreetAddress: user.Address.StreetAddress,
-		ZipCode:       user.Address.ZipCode,
-		State:         user.Address.State,
-		Country:       user.Address.Country,
-		Latitude:      user.Address.Latitude,
-		Longitude:     user.Address.Longitude,
-	}
-
-	// Insert user to users table in PostgreSQL
-	_, err = tx.NamedExec(`
-		INSERT INTO
-			users (first_name, last_name, date_of_birth, city, street_name, street_address, zip_code, state, country, latitude, longitude)
-		VALUES
-			(:first_name, :last_name, :date_of_birth, :city, :street_name, :street_address, :zip_code, :state, :country, :latitude, :longitude)
-	`, dbUser)
-	if err != nil {
-		return err
+	chunkSize := maxParameters / len(t.columns)
+	var inserted, updated, deleted int64
+	for start := 0; start < len(records); {
+		end := start + 1
+		if records[start].IsInsert() {
+			for end < len(records) && end-start < chunkSize && records[end].IsInsert() {
+				end++
+			}
+		}
+		for _, record := range records[start:end] {
+			batchLogger.WithFields(utils.Fields{
+				utils.FieldDatasource: record.Metadata.Datasource,
+				utils.FieldRecordID:   record.Metadata.CorrelationID,
+			}).Debugf("adding %s record to batch", entity)
+		}
+
+		switch records[start].Operation {
+		case transformation.OperationUpdate:
+			query, args := t.update(records[start])
+			affected, err := exec(ctx, tx, query, args)
+			if err == nil && affected == 0 {
+				query, args = t.insert(records[start:end])
+				affected, err = exec(ctx, tx, query, args)
+			}
+			if err != nil {
+				batchLogger.Errorf("unable to update %s record %v", entity, err)
+				return err
+			}
+			updated += affected
+		case transformation.OperationDelete:
+			query, args := t.delete(records[start])
+			affected, err := exec(ctx, tx, query, args)
+			if err != nil {
+				batchLogger.Errorf("unable to delete %s record %v", entity, err)
+				return err
+			}
+			deleted += affected
+		default:
+			query, args := t.insert(records[start:end])
+			affected, err := exec(ctx, tx, query, args)
+			if err != nil {
+				batchLogger.Errorf("unable to insert %d %s records %v", end-start, entity, err)
+				return err
+			}
+			inserted += affected
+		}
+		start = end
 	}
 
+	if updated == 0 && deleted == 0 {
+		batchLogger.Infof("inserted %d %s records", inserted, entity)
+	} else {
+		batchLogger.Infof("inserted %d, updated %d and deleted %d %s records", inserted, updated, deleted, entity)
+	}
 	return nil
 }
 
-// bulk insert
-func (p *PostgreSQLRepository) AddUsers(ctx context.Context, users []transformation.TransformedData) error {
-	tx, err := p.db.Beginx()
+// execute statement in transaction and return number of affected rows
+// error of invalid data, constraint violation or undefined table/column is permanent as retrying the statement fails again
+func exec(ctx context.Context, tx *sqlx.Tx, query string, args []interface{}) (int64, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
-		return errors.Wrap(err, "unable to start transaction")
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && len(pgErr.Code) == 5 {
+			switch pgErr.Code[:2] {
+			// data exception, integrity constraint violation, syntax error or access rule violation
+			case "22", "23", "42":
+				return 0, Permanent(err)
+			}
+		}
+		return 0, err
 	}
+	affected, _ := result.RowsAffected()
+	return affected, nil
+}
 
-	defer func() {
-		err = p.finishTransaction(err, tx)
-	}()
+// multi-row insert statement and its arguments, missing field is inserted as NULL
+func (t table) insert(records []transformation.Record) (string, []interface{}) {
+	var b strings.Builder
+	args := make([]interface{}, 0, len(records)*len(t.fields))
+	b.WriteString("INSERT INTO " + t.name + " (" + strings.Join(t.columns, ", ") + ") VALUES ")
+	for i, record := range records {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j, field := range t.fields {
+			if j != 0 {
+				b.WriteString(", ")
+			}
+			args = append(args, record.Get(field))
+			b.WriteString("$" + strconv.Itoa(len(args)))
+		}
+		b.WriteString(")")
+	}
 
-	dbUsers := make([]postgresqlUser, 0, len(users))
-	for _, user := range users {
-		dbUsers = append(dbUsers, postgresqlUser{
-			FirstName:     user.FirstName,
-			LastName:      user.LastName,
-			DateOfBirth:   user.DateOfBirth,
-			City:          user.Address.City,
-			StreetName:    user.Address.StreetName,
-			StreetAddress: user.Address.StreetAddress,
-			ZipCode:       user.Address.ZipCode,
-			State:         user.Address.State,
-			Country:       user.Address.Country,
-			Latitude:      user.Address.Latitude,
-			Longitude:     user.Address.Longitude,
-		})
-	}
-
-	// Insert user to users table in PostgreSQL
-	result, err := tx.NamedExec(`
-		INSERT INTO
-			users (first_name, last_name, date_of_birth, city, street_name, street_address, zip_code, state, country, latitude, longitude)
-		VALUES
-			(:first_name, :last_name, :date_of_birth, :city, :street_name, :street_address, :zip_code, :state, :country, :latitude, :longitude)
-	`, dbUsers)
-	p.logger.Infof("result %v", result)
-	p.logger.Infof("err %v", err)
-	if err != nil {
-		return err
+	return b.String(), args
+}
+
+// update statement setting every column of rows with key of record and its arguments
+func (t table) update(record transformation.Record) (string, []interface{}) {
+	var b strings.Builder
+	args := make([]interface{}, 0, len(t.fields)+len(t.keyFields))
+	b.WriteString("UPDATE " + t.name + " SET ")
+	for i, field := range t.fields {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		args = append(args, record.Get(field))
+		b.WriteString(t.columns[i] + " = $" + strconv.Itoa(len(args)))
 	}
+	b.WriteString(t.where(record, &args))
 
-	return nil
+	return b.String(), args
+}
+
+// delete statement of rows with key of record and its arguments
+func (t table) delete(record transformation.Record) (string, []interface{}) {
+	args := make([]interface{}, 0, len(t.keyFields))
+	query := "DELETE FROM " + t.name + t.where(record, &args)
+
+	return query, args
+}
+
+// where clause matching key of record, key values are appended to args
+func (t table) where(record transformation.Record, args *[]interface{}) string {
+	var b strings.Builder
+	b.WriteString(" WHERE ")
+	for i, field := range t.keyFields {
+		if i != 0 {
+			b.WriteString(" AND ")
+		}
+		*args = append(*args, record.Get(field))
+		b.WriteString(t.keyColumns[i] + " = $" + strconv.Itoa(len(*args)))
+	}
+
+	return b.String()
 }
 
 // PostgreSQL finish transaction operation (rollback if failure and commit if no error)
@@ -136,17 +256,28 @@ func (p *PostgreSQLRepository) finishTransaction(err error, tx *sqlx.Tx) error {
 		return err
 	}
 	if commitErr := tx.Commit(); commitErr != nil {
-		return errors.Wrap(err, "failed to commit transaction")
+		return errors.Wrap(commitErr, "failed to commit transaction")
 	}
 	return err
 }
 
 // Start PostgreSQL connection
+// connection error is redacted as driver error may contain connection string with password
 func NewPostgreSQLConnection(c config.DatabaseConfig) (*sqlx.DB, error) {
 	db, err := sqlx.Connect("pgx", c.ConnectionString)
 	if err != nil {
-		return nil, err
+		return nil, errors.New(redactConnectionError(err.Error(), c.ConnectionString))
 	}
 
 	return db, nil
 }
+
+// mask connection string and its password in error message
+func redactConnectionError(message string, connectionString string) string {
+	message = strings.ReplaceAll(message, connectionString, utils.RedactConnectionString(connectionString))
+	if config, err := pgx.ParseConfig(connectionString); err == nil && config.Password != "" {
+		message = utils.RedactSecrets(message, []string{config.Password})
+	}
+
+	return message
+}
@@ -0,0 +1,160 @@
+package loading_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPostgreSQLRepositoryWrite(t *testing.T) {
+	type testcase struct {
+		testcase      string
+		expect        func(mock sqlmock.Sqlmock)
+		expectedError bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Committed",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO "orders"`).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			testcase: "Insert failed",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO "orders"`).WillReturnError(errors.New("connection reset"))
+				mock.ExpectRollback()
+			},
+			expectedError: true,
+		},
+		{
+			testcase: "Commit failed",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO "orders"`).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit().WillReturnError(errors.New("connection reset"))
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			defer db.Close()
+			tc.expect(mock)
+
+			repo := loading.NewPostgreSQLRepository(sqlx.NewDb(db, "pgx"), []config.EntityConfig{{
+				Name:   "orders",
+				Table:  "orders",
+				Fields: []config.EntityFieldConfig{{Name: "OrderId", Type: "int", Column: "order_id"}},
+			}}, utils.NewLogrusLogger(logrus.StandardLogger()))
+			err = repo.Write(context.Background(), "orders", []transformation.Record{
+				{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(1)}},
+				{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(2)}},
+			})
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+			} else if err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestPostgreSQLRepositoryPermanentError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "orders"`).WillReturnError(&pgconn.PgError{Code: "23502", Message: "null value in column violates not-null constraint"})
+	mock.ExpectRollback()
+
+	logger := utils.NewLogrusLogger(logrus.StandardLogger())
+	repo := loading.NewPostgreSQLRepository(sqlx.NewDb(db, "pgx"), []config.EntityConfig{{
+		Name:   "orders",
+		Table:  "orders",
+		Fields: []config.EntityFieldConfig{{Name: "OrderId", Type: "int", Column: "order_id"}},
+	}}, logger)
+	dataChan := make(chan transformation.Record, 1)
+	dataChan <- transformation.Record{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(1)}}
+	close(dataChan)
+
+	// constraint violation is not retried by loader
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := loading.NewLoader(repo, false, 1, 1, logger).Run(ctx, dataChan); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+}
+
+func TestPostgreSQLRepositoryWriteNull(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "orders" SET "order_id" = \$1, "note" = \$2 WHERE "order_id" = \$3`).WithArgs(int64(1), nil, int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "orders"`).WithArgs(int64(2), nil).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	entities := []config.EntityConfig{{
+		Name:  "orders",
+		Table: "orders",
+		Fields: []config.EntityFieldConfig{
+			{Name: "OrderId", Type: "int", Column: "order_id"},
+			{Name: "Note", Type: "string", Column: "note"},
+		},
+		Key: []string{"OrderId"},
+	}}
+	schemas, err := transformation.NewSchemas(entities)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	// null column of change data capture update and missing field are conformed to nil
+	records := []transformation.Record{
+		{Entity: "orders", Operation: transformation.OperationUpdate, Fields: map[string]interface{}{"order_id": int64(1), "note": nil}},
+		{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(2)}},
+	}
+	for i := range records {
+		if err := schemas.Conform(&records[i]); err != nil {
+			t.Fatalf("not expected error, but got %v", err)
+		}
+	}
+
+	repo := loading.NewPostgreSQLRepository(sqlx.NewDb(db, "pgx"), entities, utils.NewLogrusLogger(logrus.StandardLogger()))
+	if err := repo.Write(context.Background(), "orders", records); err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("not expected error, but got %v", err)
+	}
+}
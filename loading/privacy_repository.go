@@ -0,0 +1,35 @@
+package loading
+
+import (
+	"context"
+
+	"github.com/awcjack/ETL-sample/privacy"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// repository wrapper which apply privacy policy (masking, hashing, truncation and encryption) of the sink
+// before records are written to the wrapped repository, records of caller are not modified
+type PrivacyRepository struct {
+	repo   Repository
+	policy *privacy.Policy
+}
+
+func NewPrivacyRepository(repo Repository, policy *privacy.Policy) *PrivacyRepository {
+	return &PrivacyRepository{
+		repo:   repo,
+		policy: policy,
+	}
+}
+
+func (p *PrivacyRepository) Write(ctx context.Context, entity string, records []transformation.Record) error {
+	protected := make([]transformation.Record, 0, len(records))
+	for _, record := range records {
+		protectedRecord, err := p.policy.Apply(record)
+		if err != nil {
+			return err
+		}
+		protected = append(protected, protectedRecord)
+	}
+
+	return p.repo.Write(ctx, entity, protected)
+}
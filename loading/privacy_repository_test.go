@@ -0,0 +1,61 @@
+package loading_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/loading"
+	"github.com/awcjack/ETL-sample/privacy"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+func TestPrivacyRepository(t *testing.T) {
+	schemas, _ := transformation.NewSchemas([]config.EntityConfig{config.UserEntity()})
+	policies, err := privacy.NewPolicies(config.PrivacyConfig{
+		Policies: []config.PrivacyPolicyConfig{
+			{Sink: "archive", Field: "LastName", Action: "redact"},
+			{Sink: "archive", Field: "Address.ZipCode", Action: "truncate", Length: 3},
+		},
+	}, schemas)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	database := &repoMock{}
+	archive := &repoMock{}
+	repo := loading.NewMultiRepository(
+		loading.NewPrivacyRepository(database, policies[privacy.SinkDatabase]),
+		loading.NewPrivacyRepository(archive, policies[privacy.SinkArchive]),
+	)
+
+	users := []transformation.Record{{Entity: "users", Fields: map[string]interface{}{"FirstName": "John", "LastName": "Doe", "Address.ZipCode": "02134"}}}
+	if err := repo.Write(context.Background(), "users", users); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	expectedArchive := transformation.Record{Entity: "users", Fields: map[string]interface{}{"FirstName": "John", "LastName": utils.Redacted, "Address.ZipCode": "021"}}
+	if len(archive.records) != 1 || !reflect.DeepEqual(archive.records[0], expectedArchive) {
+		t.Errorf("expected %+v, but got %+v", expectedArchive, archive.records)
+	}
+	// records of caller and other sink are not modified
+	expectedDatabase := transformation.Record{Entity: "users", Fields: map[string]interface{}{"FirstName": "John", "LastName": "Doe", "Address.ZipCode": "02134"}}
+	if len(database.records) != 1 || !reflect.DeepEqual(database.records[0], expectedDatabase) || !reflect.DeepEqual(users[0], expectedDatabase) {
+		t.Errorf("expected %+v, but got %+v", expectedDatabase, database.records)
+	}
+}
+
+func TestMultiRepositoryError(t *testing.T) {
+	database := &repoMock{err: errors.New("insert failed")}
+	archive := &repoMock{}
+	repo := loading.NewMultiRepository(database, archive)
+
+	if err := repo.Write(context.Background(), "users", records("users")); err != database.err {
+		t.Errorf("expected error %v, but got %v", database.err, err)
+	}
+	if archive.CalledWrite != 0 {
+		t.Errorf("expected archive not called, but got %v", archive.CalledWrite)
+	}
+}
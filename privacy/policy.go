@@ -0,0 +1,241 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// sinks which records are written to
+const (
+	SinkDatabase = "database"
+	SinkArchive  = "archive"
+	SinkKafka    = "kafka"
+)
+
+// actions of field policy
+const (
+	ActionRedact   = "redact"
+	ActionHash     = "hash"
+	ActionTruncate = "truncate"
+	ActionEncrypt  = "encrypt"
+)
+
+// compiled field policies of a sink
+type Policy struct {
+	fields []fieldPolicy
+}
+
+type fieldPolicy struct {
+	field transformation.Field
+	apply func(value interface{}) (interface{}, error)
+}
+
+// keys loaded from key files
+type keys struct {
+	hash       []byte
+	encryption []byte
+}
+
+// compile policies of every sink and collect every problem (unknown field, unsupported action etc) into a single error
+// field is resolved in every entity, so a policy applies to records of any entity having the field
+// key files are only loaded if hash or encrypt policy is used
+func NewPolicies(c config.PrivacyConfig, schemas transformation.Schemas) (map[string]*Policy, error) {
+	problems := make([]string, 0)
+	k := keys{}
+	for _, policy := range c.Policies {
+		var err error
+		if policy.Action == ActionHash && k.hash == nil {
+			if k.hash, err = utils.LoadKey(c.HashKeyFile); err != nil {
+				problems = append(problems, fmt.Sprintf("Privacy.HashKeyFile %v", err))
+				k.hash = []byte{}
+			}
+		}
+		if policy.Action == ActionEncrypt && k.encryption == nil {
+			if k.encryption, err = utils.LoadKey(c.EncryptionKeyFile); err != nil {
+				problems = append(problems, fmt.Sprintf("Privacy.EncryptionKeyFile %v", err))
+				k.encryption = []byte{}
+			}
+		}
+	}
+
+	policies := make(map[string]*Policy)
+	configured := make(map[string]bool)
+	for i, policyConfig := range c.Policies {
+		key := strings.ToLower(policyConfig.Sink + "." + policyConfig.Field)
+		if configured[key] {
+			problems = append(problems, fmt.Sprintf("Privacy.Policies[%d] (%s) field already has policy of sink %s", i, policyConfig.Field, policyConfig.Sink))
+			continue
+		}
+		configured[key] = true
+
+		field, err := compileField(policyConfig, schemas, k)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Privacy.Policies[%d] (%s) %v", i, policyConfig.Field, err))
+			continue
+		}
+		if policies[policyConfig.Sink] == nil {
+			policies[policyConfig.Sink] = &Policy{}
+		}
+		policies[policyConfig.Sink].fields = append(policies[policyConfig.Sink].fields, field)
+	}
+
+	if len(problems) != 0 {
+		return nil, &config.ValidationError{Problems: problems}
+	}
+	return policies, nil
+}
+
+// return copy of record with field policies applied, record is returned unchanged if policy is nil
+// empty (zero) fields and fields not in entity of record are kept unchanged
+func (p *Policy) Apply(record transformation.Record) (transformation.Record, error) {
+	if p == nil {
+		return record, nil
+	}
+
+	record = record.Clone()
+	for _, field := range p.fields {
+		value := record.Get(field.field.Name)
+		if transformation.IsZero(value) {
+			continue
+		}
+		applied, err := field.apply(value)
+		if err != nil {
+			return transformation.Record{}, fmt.Errorf("unable to apply privacy policy of %s: %w", field.field.Name, err)
+		}
+		record.Set(field.field.Name, applied)
+	}
+	return record, nil
+}
+
+func compileField(c config.PrivacyPolicyConfig, schemas transformation.Schemas, k keys) (fieldPolicy, error) {
+	if first, _, _ := strings.Cut(c.Field, "."); strings.EqualFold(first, "Metadata") {
+		return fieldPolicy{}, fmt.Errorf("metadata field is not supported")
+	}
+	f, err := schemas.Field(c.Field)
+	if err != nil {
+		return fieldPolicy{}, err
+	}
+
+	field := fieldPolicy{field: f}
+	switch f.Type {
+	case transformation.FieldString, transformation.FieldFloat, transformation.FieldInt, transformation.FieldDate:
+	default:
+		return fieldPolicy{}, fmt.Errorf("field is not a string, number or date")
+	}
+
+	switch c.Action {
+	case ActionRedact:
+		field.apply = redact(f.Type)
+	case ActionHash, ActionEncrypt:
+		// result is a string which can't be stored in number or date field
+		if f.Type != transformation.FieldString {
+			return fieldPolicy{}, fmt.Errorf("%s is only supported by string field", c.Action)
+		}
+		if c.Action == ActionHash {
+			field.apply = hash(k.hash)
+		} else {
+			field.apply = encrypt(k.encryption)
+		}
+	case ActionTruncate:
+		field.apply, err = truncate(f.Type, c.Length, c.DatePart)
+		if err != nil {
+			return fieldPolicy{}, err
+		}
+	default:
+		return fieldPolicy{}, fmt.Errorf("action %s not implemented", c.Action)
+	}
+
+	return field, nil
+}
+
+// replace string with redacted placeholder, number and date with null
+func redact(fieldType transformation.FieldType) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		if fieldType == transformation.FieldString {
+			return utils.Redacted, nil
+		}
+		return nil, nil
+	}
+}
+
+// deterministic keyed hash (hex encoded HMAC-SHA256), same value is hashed to same result for joining
+func hash(key []byte) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value.(string)))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+}
+
+// base64 encoded AES-256-GCM ciphertext with random nonce, decrypted by Decrypt
+func encrypt(key []byte) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		ciphertext, err := utils.Encrypt(key, []byte(value.(string)))
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(ciphertext), nil
+	}
+}
+
+// keep first length characters of string, length decimal places of number or year / month of date
+func truncate(fieldType transformation.FieldType, length int, datePart string) (func(value interface{}) (interface{}, error), error) {
+	switch fieldType {
+	case transformation.FieldString:
+		if length <= 0 {
+			return nil, fmt.Errorf("Length must be positive but got %d", length)
+		}
+		return func(value interface{}) (interface{}, error) {
+			if runes := []rune(value.(string)); len(runes) > length {
+				return string(runes[:length]), nil
+			}
+			return value, nil
+		}, nil
+	case transformation.FieldFloat:
+		if length < 0 {
+			return nil, fmt.Errorf("Length must not be negative but got %d", length)
+		}
+		scale := math.Pow10(length)
+		return func(value interface{}) (interface{}, error) {
+			return math.Trunc(value.(float64)*scale) / scale, nil
+		}, nil
+	case transformation.FieldDate:
+		var truncateDate func(t time.Time) time.Time
+		switch datePart {
+		case "year":
+			truncateDate = func(t time.Time) time.Time { return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location()) }
+		case "month":
+			truncateDate = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()) }
+		default:
+			return nil, fmt.Errorf("DatePart %q is not supported (supported: year, month)", datePart)
+		}
+		return func(value interface{}) (interface{}, error) {
+			return truncateDate(value.(time.Time)), nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("truncate is not supported by %s field", fieldType)
+}
+
+// decrypt value encrypted by encrypt policy
+func Decrypt(key []byte, value string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("value is not base64 encoded: %w", err)
+	}
+	plaintext, err := utils.Decrypt(key, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
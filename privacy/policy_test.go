@@ -0,0 +1,271 @@
+package privacy_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/privacy"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+func TestPolicy(t *testing.T) {
+	type testcase struct {
+		testcase string
+		policies []config.PrivacyPolicyConfig
+		input    transformation.Record
+		expected transformation.Record
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Redact",
+			policies: []config.PrivacyPolicyConfig{
+				{Sink: "archive", Field: "LastName", Action: "redact"},
+				{Sink: "archive", Field: "DateOfBirth", Action: "redact"},
+				{Sink: "archive", Field: "Address.Latitude", Action: "redact"},
+			},
+			input:    user(fields{"FirstName": "John", "LastName": "Doe", "DateOfBirth": time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), "Address.Latitude": 42.36}),
+			expected: user(fields{"FirstName": "John", "LastName": utils.Redacted}),
+		},
+		{
+			testcase: "Hash",
+			policies: []config.PrivacyPolicyConfig{
+				{Sink: "archive", Field: "LastName", Action: "hash"},
+			},
+			input:    user(fields{"LastName": "Doe"}),
+			expected: user(fields{"LastName": "d275d874d64d587f109082351af7928aa0bf16f40031555482b9a64714e0211e"}),
+		},
+		{
+			testcase: "Truncate",
+			policies: []config.PrivacyPolicyConfig{
+				{Sink: "archive", Field: "DateOfBirth", Action: "truncate", DatePart: "year"},
+				{Sink: "archive", Field: "Address.ZipCode", Action: "truncate", Length: 3},
+				{Sink: "archive", Field: "Address.Latitude", Action: "truncate", Length: 1},
+			},
+			input:    user(fields{"DateOfBirth": time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), "Address.ZipCode": "02134-1234", "Address.Latitude": 42.3601}),
+			expected: user(fields{"DateOfBirth": time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), "Address.ZipCode": "021", "Address.Latitude": 42.3}),
+		},
+		{
+			testcase: "Empty field kept empty",
+			policies: []config.PrivacyPolicyConfig{
+				{Sink: "archive", Field: "LastName", Action: "hash"},
+				{Sink: "archive", Field: "DateOfBirth", Action: "truncate", DatePart: "month"},
+			},
+			input:    user(fields{"FirstName": "John"}),
+			expected: user(fields{"FirstName": "John"}),
+		},
+		{
+			testcase: "Record of entity without field kept",
+			policies: []config.PrivacyPolicyConfig{
+				{Sink: "archive", Field: "LastName", Action: "redact"},
+				{Sink: "archive", Field: "OrderId", Action: "redact"},
+			},
+			input:    order(fields{"OrderId": 1}),
+			expected: order(fields{}),
+		},
+		{
+			testcase: "Policy of other sink ignored",
+			policies: []config.PrivacyPolicyConfig{
+				{Sink: "database", Field: "FirstName", Action: "redact"},
+			},
+			input:    user(fields{"FirstName": "John"}),
+			expected: user(fields{"FirstName": "John"}),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newPolicyDependencies(t)
+			dep.config.Policies = tc.policies
+
+			policies, err := privacy.NewPolicies(dep.config, schemas)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			v, err := policies[privacy.SinkArchive].Apply(tc.input)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if !reflect.DeepEqual(v, tc.expected) {
+				t.Errorf("expected %+v, but got %+v", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestPolicyHashDeterministic(t *testing.T) {
+	dep := newPolicyDependencies(t)
+	dep.config.Policies = []config.PrivacyPolicyConfig{{Sink: "database", Field: "LastName", Action: "hash"}}
+	policies, err := privacy.NewPolicies(dep.config, schemas)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	first, _ := policies[privacy.SinkDatabase].Apply(user(fields{"LastName": "Doe"}))
+	second, _ := policies[privacy.SinkDatabase].Apply(user(fields{"LastName": "Doe"}))
+	other, _ := policies[privacy.SinkDatabase].Apply(user(fields{"LastName": "Roe"}))
+	if first.String("LastName") != second.String("LastName") || len(first.String("LastName")) != 64 {
+		t.Errorf("expected same hash, but got %v and %v", first.String("LastName"), second.String("LastName"))
+	}
+	if first.String("LastName") == other.String("LastName") {
+		t.Errorf("expected different hash, but got %v", other.String("LastName"))
+	}
+}
+
+func TestPolicyEncrypt(t *testing.T) {
+	dep := newPolicyDependencies(t)
+	dep.config.Policies = []config.PrivacyPolicyConfig{{Sink: "database", Field: "Address.StreetAddress", Action: "encrypt"}}
+	policies, err := privacy.NewPolicies(dep.config, schemas)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	input := user(fields{"Address.StreetAddress": "82204 Wisoky Canyon"})
+	v, err := policies[privacy.SinkDatabase].Apply(input)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if strings.Contains(v.String("Address.StreetAddress"), "Wisoky") {
+		t.Errorf("expected encrypted value, but got %v", v.String("Address.StreetAddress"))
+	}
+	if input.String("Address.StreetAddress") != "82204 Wisoky Canyon" {
+		t.Errorf("expected input unchanged, but got %v", input.String("Address.StreetAddress"))
+	}
+
+	key, _ := utils.LoadKey(dep.config.EncryptionKeyFile)
+	plaintext, err := privacy.Decrypt(key, v.String("Address.StreetAddress"))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if plaintext != "82204 Wisoky Canyon" {
+		t.Errorf("expected %v, but got %v", "82204 Wisoky Canyon", plaintext)
+	}
+}
+
+func TestNewPoliciesInvalid(t *testing.T) {
+	type testcase struct {
+		testcase         string
+		policy           config.PrivacyPolicyConfig
+		modify           func(c *config.PrivacyConfig)
+		expectedProblems []string
+	}
+
+	testcases := []testcase{
+		{
+			testcase:         "Unknown field",
+			policy:           config.PrivacyPolicyConfig{Sink: "database", Field: "MiddleName", Action: "redact"},
+			expectedProblems: []string{"field MiddleName not found"},
+		},
+		{
+			testcase:         "Metadata field",
+			policy:           config.PrivacyPolicyConfig{Sink: "database", Field: "Metadata.Datasource", Action: "redact"},
+			expectedProblems: []string{"metadata field is not supported"},
+		},
+		{
+			testcase:         "Truncate int field",
+			policy:           config.PrivacyPolicyConfig{Sink: "database", Field: "OrderId", Action: "truncate", Length: 1},
+			expectedProblems: []string{"truncate is not supported by int field"},
+		},
+		{
+			testcase:         "Encrypt date field",
+			policy:           config.PrivacyPolicyConfig{Sink: "database", Field: "DateOfBirth", Action: "encrypt"},
+			expectedProblems: []string{"encrypt is only supported by string field"},
+		},
+		{
+			testcase:         "Truncate string without length",
+			policy:           config.PrivacyPolicyConfig{Sink: "database", Field: "FirstName", Action: "truncate"},
+			expectedProblems: []string{"Length must be positive"},
+		},
+		{
+			testcase:         "Truncate date by day",
+			policy:           config.PrivacyPolicyConfig{Sink: "database", Field: "DateOfBirth", Action: "truncate", DatePart: "day"},
+			expectedProblems: []string{"DatePart \"day\" is not supported"},
+		},
+		{
+			testcase: "Missing key file",
+			policy:   config.PrivacyPolicyConfig{Sink: "database", Field: "FirstName", Action: "hash"},
+			modify: func(c *config.PrivacyConfig) {
+				c.HashKeyFile = filepath.Join(os.TempDir(), "not-exist.key")
+			},
+			expectedProblems: []string{"Privacy.HashKeyFile unable to read key file"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newPolicyDependencies(t)
+			dep.config.Policies = []config.PrivacyPolicyConfig{tc.policy}
+			if tc.modify != nil {
+				tc.modify(&dep.config)
+			}
+
+			_, err := privacy.NewPolicies(dep.config, schemas)
+			if err == nil {
+				t.Fatalf("expected error but got nil")
+			}
+			for _, expected := range tc.expectedProblems {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected problem %v, but got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+type fields map[string]interface{}
+
+// users and orders entities
+var schemas = func() transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{
+		config.UserEntity(),
+		{Name: "orders", Fields: []config.EntityFieldConfig{{Name: "OrderId", Type: "int"}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+func user(f fields) transformation.Record {
+	return newRecord(config.DefaultEntity, f)
+}
+
+func order(f fields) transformation.Record {
+	return newRecord("orders", f)
+}
+
+// record conformed to schema of entity
+func newRecord(entity string, f fields) transformation.Record {
+	record := transformation.Record{Entity: entity, Fields: f}
+	if err := schemas.Conform(&record); err != nil {
+		panic(err)
+	}
+	return record
+}
+
+type policyDependencies struct {
+	config config.PrivacyConfig
+}
+
+// hash key is fixed for checking hash result, encryption key is random
+func newPolicyDependencies(t *testing.T) policyDependencies {
+	dir := t.TempDir()
+	hashKeyFile := filepath.Join(dir, "hash.key")
+	os.WriteFile(hashKeyFile, []byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", utils.KeySize)))), 0o600)
+	encryptionKeyFile := filepath.Join(dir, "encryption.key")
+	utils.GenerateKey(encryptionKeyFile)
+
+	return policyDependencies{
+		config: config.PrivacyConfig{
+			HashKeyFile:       hashKeyFile,
+			EncryptionKeyFile: encryptionKeyFile,
+		},
+	}
+}
@@ -0,0 +1,410 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	// segment file extension, file name is the offset of first record in segment
+	segmentExtension = ".log"
+	// file keeping the next unacknowledged offset
+	ackFileName = "ack"
+	// entry header size (4 bytes payload length + 4 bytes payload crc32)
+	entryHeaderSize = 8
+)
+
+// durable queue between extraction and loading
+// records are appended to segment log files on local disk and consumed from the last acknowledged offset
+// fully acknowledged segments are removed
+type DiskQueue struct {
+	mu     sync.Mutex
+	dir    string
+	logger utils.Logger
+	// decoded records are conformed to schema of their entity, e.g. JSON number to int or date string to time
+	schemas transformation.Schemas
+	// max size of each segment file in bytes
+	segmentSize int64
+	// offset of first record in each segment (sorted)
+	segments []int64
+	// active segment file for appending
+	writer     *os.File
+	writerSize int64
+	// offset of next appended record
+	nextOffset int64
+	// offset of first unacknowledged record
+	ackOffset int64
+	// closed and replaced on every append to wake up consumer
+	notify chan struct{}
+}
+
+// open disk queue in dir (create if not exist)
+// partially written record at the end of last segment (crash during append) is truncated
+func NewDiskQueue(dir string, segmentSize int64, schemas transformation.Schemas, logger utils.Logger) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "unable to create queue directory")
+	}
+
+	q := &DiskQueue{
+		dir:         dir,
+		logger:      logger,
+		schemas:     schemas,
+		segmentSize: segmentSize,
+		notify:      make(chan struct{}),
+	}
+
+	ackOffset, err := q.readAckOffset()
+	if err != nil {
+		return nil, err
+	}
+	q.ackOffset = ackOffset
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list queue directory")
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, base)
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if len(q.segments) == 0 {
+		// start a new log from acknowledged offset
+		q.segments = append(q.segments, q.ackOffset)
+		q.nextOffset = q.ackOffset
+	} else {
+		base := q.segments[len(q.segments)-1]
+		count, size, err := recoverSegment(q.segmentPath(base))
+		if err != nil {
+			return nil, err
+		}
+		q.nextOffset = base + count
+		q.writerSize = size
+	}
+
+	q.writer, err = os.OpenFile(q.segmentPath(q.segments[len(q.segments)-1]), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open queue segment")
+	}
+
+	logger.Infof("disk queue opened with %d unacknowledged records", q.nextOffset-q.ackOffset)
+	return q, nil
+}
+
+// append record to the end of queue
+func (q *DiskQueue) Append(data transformation.Record) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode record")
+	}
+
+	entry := make([]byte, entryHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(entry[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(entry[4:8], crc32.ChecksumIEEE(payload))
+	copy(entry[entryHeaderSize:], payload)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.writerSize >= q.segmentSize {
+		if err := q.roll(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.writer.Write(entry); err != nil {
+		return errors.Wrap(err, "unable to append record")
+	}
+	if err := q.writer.Sync(); err != nil {
+		return errors.Wrap(err, "unable to sync queue segment")
+	}
+	q.writerSize += int64(len(entry))
+	q.nextOffset++
+
+	// wake up consumer waiting for new record
+	close(q.notify)
+	q.notify = make(chan struct{})
+
+	return nil
+}
+
+// acknowledge all records up to offset (inclusive) are committed
+// fully acknowledged segments are removed from disk
+func (q *DiskQueue) Ack(offset int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if offset < q.ackOffset {
+		return nil
+	}
+	q.ackOffset = offset + 1
+
+	if err := q.writeAckOffset(); err != nil {
+		return err
+	}
+
+	// segment is fully acknowledged if next segment start at or before ack offset
+	for len(q.segments) > 1 && q.segments[1] <= q.ackOffset {
+		if err := os.Remove(q.segmentPath(q.segments[0])); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "unable to remove acknowledged segment")
+		}
+		q.segments = q.segments[1:]
+	}
+
+	return nil
+}
+
+// append every record from data channel to the queue until context is cancelled
+func (q *DiskQueue) Ingest(ctx context.Context, dataPipeline <-chan transformation.Record) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case data := <-dataPipeline:
+			if err := q.Append(data); err != nil {
+				q.logger.WithFields(utils.Fields{
+					utils.FieldDatasource: data.Metadata.Datasource,
+					utils.FieldRecordID:   data.Metadata.CorrelationID,
+				}).Errorf("unable to append record to disk queue %v", err)
+				return err
+			}
+		}
+	}
+}
+
+// pass records to data channel starting from first unacknowledged offset until context is cancelled
+// queue offset of each record is attached to record metadata for acknowledging after commit
+func (q *DiskQueue) Consume(ctx context.Context, dataPipeline chan<- transformation.Record) error {
+	q.mu.Lock()
+	offset := q.ackOffset
+	q.mu.Unlock()
+
+	var reader *segmentReader
+	defer func() {
+		if reader != nil {
+			reader.Close()
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		available := offset < q.nextOffset
+		notify := q.notify
+		base := q.segmentFor(offset)
+		q.mu.Unlock()
+
+		if !available {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-notify:
+			}
+			continue
+		}
+
+		if reader == nil || reader.base != base {
+			if reader != nil {
+				reader.Close()
+			}
+			var err error
+			reader, err = openSegmentReader(q.segmentPath(base), base)
+			if err != nil {
+				return err
+			}
+		}
+		data, err := reader.readAt(offset)
+		if err == nil {
+			err = q.schemas.Conform(&data)
+		}
+		if err != nil {
+			q.logger.Errorf("unable to read disk queue offset %d %v", offset, err)
+			return err
+		}
+		data.Metadata.QueueOffset = offset
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case dataPipeline <- data:
+		}
+		offset++
+	}
+}
+
+// close active segment file
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.writer.Close()
+}
+
+// close active segment and start a new segment from next offset
+func (q *DiskQueue) roll() error {
+	if err := q.writer.Close(); err != nil {
+		return errors.Wrap(err, "unable to close queue segment")
+	}
+
+	writer, err := os.OpenFile(q.segmentPath(q.nextOffset), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "unable to create queue segment")
+	}
+	q.writer = writer
+	q.writerSize = 0
+	q.segments = append(q.segments, q.nextOffset)
+
+	return nil
+}
+
+// base offset of the segment containing offset
+func (q *DiskQueue) segmentFor(offset int64) int64 {
+	i := sort.Search(len(q.segments), func(i int) bool { return q.segments[i] > offset })
+	if i == 0 {
+		return q.segments[0]
+	}
+	return q.segments[i-1]
+}
+
+func (q *DiskQueue) segmentPath(base int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", base, segmentExtension))
+}
+
+func (q *DiskQueue) readAckOffset() (int64, error) {
+	content, err := os.ReadFile(filepath.Join(q.dir, ackFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "unable to read queue ack file")
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid queue ack file")
+	}
+	return offset, nil
+}
+
+// write ack offset to temp file and rename to make sure ack file won't be corrupted if crash in the middle of write
+func (q *DiskQueue) writeAckOffset() error {
+	path := filepath.Join(q.dir, ackFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(q.ackOffset, 10)), 0o644); err != nil {
+		return errors.Wrap(err, "unable to write queue ack file")
+	}
+
+	return errors.Wrap(os.Rename(tmp, path), "unable to replace queue ack file")
+}
+
+// count valid records in segment and truncate incomplete or corrupted tail
+func recoverSegment(path string) (int64, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "unable to open queue segment")
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var count, size int64
+	for {
+		payload, err := readEntry(reader)
+		if err != nil {
+			break
+		}
+		count++
+		size += int64(entryHeaderSize + len(payload))
+	}
+
+	if err := file.Truncate(size); err != nil {
+		return 0, 0, errors.Wrap(err, "unable to truncate queue segment")
+	}
+	return count, size, nil
+}
+
+// read single entry and verify checksum
+func readEntry(reader io.Reader) ([]byte, error) {
+	header := make([]byte, entryHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("queue entry checksum mismatch")
+	}
+
+	return payload, nil
+}
+
+// sequential reader of a segment file
+type segmentReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	// offset of first record in segment
+	base int64
+	// offset of next record to be read
+	next int64
+}
+
+func openSegmentReader(path string, base int64) (*segmentReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open queue segment")
+	}
+
+	return &segmentReader{
+		file:   file,
+		reader: bufio.NewReader(file),
+		base:   base,
+		next:   base,
+	}, nil
+}
+
+// read record at offset, skipping records before offset
+func (s *segmentReader) readAt(offset int64) (transformation.Record, error) {
+	var data transformation.Record
+	for s.next <= offset {
+		payload, err := readEntry(s.reader)
+		if err != nil {
+			return data, err
+		}
+		s.next++
+
+		if s.next > offset {
+			err = json.Unmarshal(payload, &data)
+			return data, errors.Wrap(err, "unable to decode record")
+		}
+	}
+
+	return data, errors.Errorf("offset %d is before reader position %d", offset, s.next)
+}
+
+func (s *segmentReader) Close() error {
+	return s.file.Close()
+}
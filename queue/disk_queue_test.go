@@ -0,0 +1,228 @@
+package queue_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/queue"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func TestDiskQueueReplay(t *testing.T) {
+	type testcase struct {
+		testcase       string
+		appendCount    int
+		ackOffset      int64
+		segmentSize    int64
+		expectedNames  []string
+		expectedOffset int64
+	}
+
+	testcases := []testcase{
+		{
+			testcase:       "Replay unacknowledged records",
+			appendCount:    5,
+			ackOffset:      2,
+			segmentSize:    1024 * 1024,
+			expectedNames:  []string{"3", "4"},
+			expectedOffset: 3,
+		},
+		{
+			testcase:       "Replay across segments",
+			appendCount:    5,
+			ackOffset:      0,
+			segmentSize:    1, // every record in its own segment
+			expectedNames:  []string{"1", "2", "3", "4"},
+			expectedOffset: 1,
+		},
+		{
+			testcase:       "Nothing to replay",
+			appendCount:    3,
+			ackOffset:      2,
+			segmentSize:    1024 * 1024,
+			expectedNames:  []string{},
+			expectedOffset: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newDiskQueueDependencies(t)
+
+			q, err := queue.NewDiskQueue(dep.dir, tc.segmentSize, dep.schemas, dep.logger)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			for i := 0; i < tc.appendCount; i++ {
+				if err := q.Append(user(string(rune('0' + i)))); err != nil {
+					t.Fatalf("not expected error, but got %v", err)
+				}
+			}
+			q.Ack(tc.ackOffset)
+			q.Close()
+
+			// reopen queue to simulate restart
+			reopened, err := queue.NewDiskQueue(dep.dir, tc.segmentSize, dep.schemas, dep.logger)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			defer reopened.Close()
+
+			records := consume(reopened, len(tc.expectedNames))
+			if len(records) != len(tc.expectedNames) {
+				t.Fatalf("expected %v records, but got %v", len(tc.expectedNames), len(records))
+			}
+			for i, record := range records {
+				if record.String("FirstName") != tc.expectedNames[i] {
+					t.Errorf("expected %v, but got %v", tc.expectedNames[i], record.String("FirstName"))
+				}
+			}
+			if len(records) != 0 && records[0].Metadata.QueueOffset != tc.expectedOffset {
+				t.Errorf("expected offset %v, but got %v", tc.expectedOffset, records[0].Metadata.QueueOffset)
+			}
+		})
+	}
+}
+
+func TestDiskQueueRemoveAcknowledgedSegment(t *testing.T) {
+	dep := newDiskQueueDependencies(t)
+
+	q, err := queue.NewDiskQueue(dep.dir, 1, dep.schemas, dep.logger)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 3; i++ {
+		q.Append(user(""))
+	}
+	if count := dep.segmentCount(); count != 3 {
+		t.Fatalf("expected 3 segments, but got %v", count)
+	}
+
+	q.Ack(1)
+	if count := dep.segmentCount(); count != 1 {
+		t.Errorf("expected 1 segment after ack, but got %v", count)
+	}
+}
+
+func TestDiskQueueTruncateIncompleteRecord(t *testing.T) {
+	dep := newDiskQueueDependencies(t)
+
+	q, err := queue.NewDiskQueue(dep.dir, 1024*1024, dep.schemas, dep.logger)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	q.Append(user("complete"))
+	q.Close()
+
+	// simulate crash in the middle of append
+	segment, _ := filepath.Glob(filepath.Join(dep.dir, "*.log"))
+	file, _ := os.OpenFile(segment[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	file.Write([]byte{0, 0, 0, 100, 1, 2})
+	file.Close()
+
+	reopened, err := queue.NewDiskQueue(dep.dir, 1024*1024, dep.schemas, dep.logger)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer reopened.Close()
+	reopened.Append(user("after restart"))
+
+	records := consume(reopened, 2)
+	if len(records) != 2 || records[0].String("FirstName") != "complete" || records[1].String("FirstName") != "after restart" {
+		t.Errorf("expected incomplete record to be truncated, but got %+v", records)
+	}
+}
+
+func TestDiskQueueConformRecord(t *testing.T) {
+	dep := newDiskQueueDependencies(t)
+
+	q, err := queue.NewDiskQueue(dep.dir, 1024*1024, dep.schemas, dep.logger)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	defer q.Close()
+	record := user("John")
+	record.Set("DateOfBirth", time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC))
+	record.Set("Address.Latitude", 42.36)
+	record.Operation = transformation.OperationUpdate
+	q.Append(record)
+
+	// field types are restored from JSON by schema of entity
+	records := consume(q, 1)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, but got %+v", records)
+	}
+	if !reflect.DeepEqual(records[0].Fields, record.Fields) {
+		t.Errorf("expected %+v, but got %+v", record.Fields, records[0].Fields)
+	}
+	if records[0].Operation != record.Operation {
+		t.Errorf("expected %v, but got %v", record.Operation, records[0].Operation)
+	}
+}
+
+// users record with first name
+func user(firstName string) transformation.Record {
+	record := transformation.Record{Entity: config.DefaultEntity, Fields: map[string]interface{}{"FirstName": firstName}}
+	if err := schemas.Conform(&record); err != nil {
+		panic(err)
+	}
+	return record
+}
+
+var schemas = func() transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{config.UserEntity()})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+// consume expected number of records or until timeout
+func consume(q *queue.DiskQueue, count int) []transformation.Record {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	dataChan := make(chan transformation.Record)
+	go q.Consume(ctx, dataChan)
+
+	records := make([]transformation.Record, 0, count)
+	for {
+		select {
+		case <-ctx.Done():
+			return records
+		case data := <-dataChan:
+			records = append(records, data)
+			if len(records) > count {
+				return records
+			}
+		}
+	}
+}
+
+type diskQueueDependencies struct {
+	dir     string
+	schemas transformation.Schemas
+	logger  utils.Logger
+}
+
+func newDiskQueueDependencies(t *testing.T) diskQueueDependencies {
+	return diskQueueDependencies{
+		dir:     t.TempDir(),
+		schemas: schemas,
+		logger:  utils.NewLogrusLogger(logrus.StandardLogger()),
+	}
+}
+
+func (d diskQueueDependencies) segmentCount() int {
+	segments, _ := filepath.Glob(filepath.Join(d.dir, "*.log"))
+	return len(segments)
+}
@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awcjack/ETL-sample/config"
+)
+
+// change of entity schema between versions
+type Change struct {
+	// field name, empty if change is about the entity (e.g. table)
+	Field       string
+	Description string
+	// compatible change (new field) can be applied to existing table and consumers
+	// incompatible change (removed field, changed type, table or column) needs manual migration
+	Compatible bool
+}
+
+// changes from old to new entity config, fields are matched by name case insensitively
+// field order is ignored as columns are inserted by name
+func Diff(old config.EntityConfig, new config.EntityConfig) []Change {
+	changes := make([]Change, 0)
+	if old.Table != new.Table {
+		changes = append(changes, Change{Description: fmt.Sprintf("table changed from %s to %s", old.Table, new.Table)})
+	}
+
+	oldFields := make(map[string]config.EntityFieldConfig, len(old.Fields))
+	for _, field := range old.Fields {
+		oldFields[strings.ToLower(field.Name)] = field
+	}
+	newFields := make(map[string]bool, len(new.Fields))
+	for _, field := range new.Fields {
+		newFields[strings.ToLower(field.Name)] = true
+		previous, ok := oldFields[strings.ToLower(field.Name)]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Field: field.Name, Description: fmt.Sprintf("field %s (%s) added", field.Name, field.Type), Compatible: true})
+		case previous.Type != field.Type:
+			changes = append(changes, Change{Field: field.Name, Description: fmt.Sprintf("field %s type changed from %s to %s", field.Name, previous.Type, field.Type)})
+		case previous.Column != field.Column:
+			changes = append(changes, Change{Field: field.Name, Description: fmt.Sprintf("field %s column changed from %s to %s", field.Name, previous.Column, field.Column)})
+		case previous.DateOnly != field.DateOnly:
+			// date values are still compatible with both DATE and timestamp columns
+			changes = append(changes, Change{Field: field.Name, Description: fmt.Sprintf("field %s date only changed from %t to %t", field.Name, previous.DateOnly, field.DateOnly), Compatible: true})
+		case previous.Name != field.Name:
+			changes = append(changes, Change{Field: field.Name, Description: fmt.Sprintf("field %s renamed to %s", previous.Name, field.Name)})
+		}
+	}
+	for _, field := range old.Fields {
+		if !newFields[strings.ToLower(field.Name)] {
+			changes = append(changes, Change{Field: field.Name, Description: fmt.Sprintf("field %s removed", field.Name)})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// column of live table
+type Column struct {
+	Name     string
+	DataType string
+	Nullable bool
+	// column has default value (including identity and generated column), so it can be omitted in insert
+	HasDefault bool
+}
+
+// catalog of live database tables
+type Catalog interface {
+	// columns of table in column order, empty if table does not exist
+	Columns(ctx context.Context, table string) ([]Column, error)
+	// column data type can store value of field type
+	Accepts(fieldType transformation.FieldType, dataType string) bool
+	// apply additive changes (create table, add nullable columns) of drift
+	Migrate(ctx context.Context, drift Drift) error
+}
+
+// entity field missing in live table
+type FieldColumn struct {
+	Field  string
+	Column string
+	Type   transformation.FieldType
+	// date field is stored in DATE column
+	DateOnly bool
+}
+
+// difference between entity config and live table
+type Drift struct {
+	Entity string
+	Table  string
+	// table does not exist
+	MissingTable bool
+	// columns of entity fields missing in table (every field if table is missing)
+	MissingColumns []FieldColumn
+	// differences which can't be applied automatically (e.g. column type can't store field type)
+	Incompatible []string
+}
+
+// table needs additive changes
+func (d Drift) Additive() bool {
+	return d.MissingTable || len(d.MissingColumns) != 0
+}
+
+// problems preventing records of entity from loading
+// additive changes are problems only if they are not migrated
+func (d Drift) Problems(migrate bool) []string {
+	problems := make([]string, 0, len(d.Incompatible)+1)
+	for _, incompatible := range d.Incompatible {
+		problems = append(problems, fmt.Sprintf("entity %s table %s %s", d.Entity, d.Table, incompatible))
+	}
+	if d.Additive() && !migrate {
+		problems = append(problems, fmt.Sprintf("entity %s %s (enable Schema.Migrate to apply)", d.Entity, d.String()))
+	}
+	return problems
+}
+
+// description of additive changes
+func (d Drift) String() string {
+	if d.MissingTable {
+		return fmt.Sprintf("table %s is missing", d.Table)
+	}
+	columns := make([]string, 0, len(d.MissingColumns))
+	for _, column := range d.MissingColumns {
+		columns = append(columns, fmt.Sprintf("%s (%s)", column.Column, column.Type))
+	}
+	return fmt.Sprintf("table %s is missing columns %s", d.Table, strings.Join(columns, ", "))
+}
+
+// compare entity config with live table
+// column names are compared case insensitively as unquoted identifiers are folded by database
+func DetectDrift(ctx context.Context, catalog Catalog, entity config.EntityConfig) (Drift, error) {
+	drift := Drift{
+		Entity:         entity.Name,
+		Table:          entity.Table,
+		MissingColumns: make([]FieldColumn, 0),
+		Incompatible:   make([]string, 0),
+	}
+	columns, err := catalog.Columns(ctx, entity.Table)
+	if err != nil {
+		return drift, err
+	}
+	if len(columns) == 0 {
+		drift.MissingTable = true
+	}
+
+	byName := make(map[string]Column, len(columns))
+	for _, column := range columns {
+		byName[strings.ToLower(column.Name)] = column
+	}
+	used := make(map[string]bool, len(entity.Fields))
+	for _, field := range entity.Fields {
+		fieldType := transformation.FieldType(field.Type)
+		used[strings.ToLower(field.Column)] = true
+		column, ok := byName[strings.ToLower(field.Column)]
+		if !ok {
+			drift.MissingColumns = append(drift.MissingColumns, FieldColumn{Field: field.Name, Column: field.Column, Type: fieldType, DateOnly: field.DateOnly})
+			continue
+		}
+		if !catalog.Accepts(fieldType, column.DataType) {
+			drift.Incompatible = append(drift.Incompatible, fmt.Sprintf("column %s is %s but field %s is %s", column.Name, column.DataType, field.Name, field.Type))
+		}
+	}
+	// column not loaded by entity must be filled by database
+	for _, column := range columns {
+		if !used[strings.ToLower(column.Name)] && !column.Nullable && !column.HasDefault {
+			drift.Incompatible = append(drift.Incompatible, fmt.Sprintf("column %s is NOT NULL without default but not a field of entity", column.Name))
+		}
+	}
+
+	return drift, nil
+}
+
+// detect drift of every entity table and apply additive changes if migrate is enabled
+// tables are not changed if any table has incompatible drift or additive drift which is not migrated
+func Evolve(ctx context.Context, catalog Catalog, entities []config.EntityConfig, migrate bool, logger utils.Logger) error {
+	drifts := make([]Drift, 0, len(entities))
+	problems := make([]string, 0)
+	for _, entity := range entities {
+		drift, err := DetectDrift(ctx, catalog, entity)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, drift.Problems(migrate)...)
+		drifts = append(drifts, drift)
+	}
+	if len(problems) != 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+
+	for _, drift := range drifts {
+		if !drift.Additive() {
+			continue
+		}
+		if err := catalog.Migrate(ctx, drift); err != nil {
+			return err
+		}
+		logger.Infof("migrated entity %s: %s", drift.Entity, drift.String())
+	}
+	return nil
+}
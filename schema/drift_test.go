@@ -0,0 +1,151 @@
+package schema_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/schema"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type catalogMock struct {
+	tables   map[string][]schema.Column
+	migrated []schema.Drift
+}
+
+func (c *catalogMock) Columns(ctx context.Context, table string) ([]schema.Column, error) {
+	return c.tables[table], nil
+}
+
+func (c *catalogMock) Accepts(fieldType transformation.FieldType, dataType string) bool {
+	return string(fieldType) == dataType
+}
+
+func (c *catalogMock) Migrate(ctx context.Context, drift schema.Drift) error {
+	c.migrated = append(c.migrated, drift)
+	return nil
+}
+
+func TestDetectDrift(t *testing.T) {
+	type testcase struct {
+		testcase      string
+		columns       []schema.Column
+		expectedDrift schema.Drift
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "No drift",
+			columns: []schema.Column{
+				{Name: "order_id", DataType: "int"},
+				{Name: "TOTAL", DataType: "float", Nullable: true},
+				{Name: "created_at", DataType: "date", HasDefault: true},
+			},
+			expectedDrift: schema.Drift{Entity: "orders", Table: "orders", MissingColumns: []schema.FieldColumn{}, Incompatible: []string{}},
+		},
+		{
+			testcase: "Missing table",
+			expectedDrift: schema.Drift{Entity: "orders", Table: "orders", MissingTable: true, MissingColumns: []schema.FieldColumn{
+				{Field: "OrderId", Column: "order_id", Type: transformation.FieldInt},
+				{Field: "Total", Column: "total", Type: transformation.FieldFloat},
+			}, Incompatible: []string{}},
+		},
+		{
+			testcase: "Missing column",
+			columns:  []schema.Column{{Name: "order_id", DataType: "int"}},
+			expectedDrift: schema.Drift{Entity: "orders", Table: "orders", MissingColumns: []schema.FieldColumn{
+				{Field: "Total", Column: "total", Type: transformation.FieldFloat},
+			}, Incompatible: []string{}},
+		},
+		{
+			testcase: "Incompatible column type and NOT NULL column",
+			columns: []schema.Column{
+				{Name: "order_id", DataType: "int"},
+				{Name: "total", DataType: "string", Nullable: true},
+				{Name: "customer", DataType: "string"},
+			},
+			expectedDrift: schema.Drift{Entity: "orders", Table: "orders", MissingColumns: []schema.FieldColumn{}, Incompatible: []string{
+				"column total is string but field Total is float",
+				"column customer is NOT NULL without default but not a field of entity",
+			}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			catalog := &catalogMock{tables: map[string][]schema.Column{"orders": tc.columns}}
+			drift, err := schema.DetectDrift(context.Background(), catalog, orders())
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if !reflect.DeepEqual(drift, tc.expectedDrift) {
+				t.Errorf("expected %+v, but got %+v", tc.expectedDrift, drift)
+			}
+		})
+	}
+}
+
+func TestEvolve(t *testing.T) {
+	type testcase struct {
+		testcase         string
+		tables           map[string][]schema.Column
+		migrate          bool
+		expectedProblems int
+		expectedMigrated int
+	}
+
+	testcases := []testcase{
+		{
+			testcase:         "Missing column migrated",
+			tables:           map[string][]schema.Column{"orders": {{Name: "order_id", DataType: "int"}}, "refunds": {{Name: "order_id", DataType: "int"}}},
+			migrate:          true,
+			expectedMigrated: 1,
+		},
+		{
+			testcase:         "Missing column refused without migrate",
+			tables:           map[string][]schema.Column{"orders": {{Name: "order_id", DataType: "int"}}, "refunds": {{Name: "order_id", DataType: "int"}}},
+			expectedProblems: 1,
+		},
+		{
+			testcase:         "Nothing migrated if any table is incompatible",
+			tables:           map[string][]schema.Column{"orders": {{Name: "order_id", DataType: "int"}}, "refunds": {{Name: "order_id", DataType: "string"}}},
+			migrate:          true,
+			expectedProblems: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			catalog := &catalogMock{tables: tc.tables}
+			refunds := config.EntityConfig{Name: "refunds", Table: "refunds", Fields: []config.EntityFieldConfig{{Name: "OrderId", Type: "int", Column: "order_id"}}}
+			err := schema.Evolve(context.Background(), catalog, []config.EntityConfig{orders(), refunds}, tc.migrate, newLogger())
+
+			if tc.expectedProblems == 0 && err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if tc.expectedProblems != 0 {
+				var validationErr *config.ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("expected validation error, but got %v", err)
+				}
+				if len(validationErr.Problems) != tc.expectedProblems {
+					t.Errorf("expected %v, but got %v", tc.expectedProblems, validationErr.Problems)
+				}
+			}
+			if len(catalog.migrated) != tc.expectedMigrated {
+				t.Errorf("expected %v, but got %v", tc.expectedMigrated, len(catalog.migrated))
+			}
+		})
+	}
+}
+
+func newLogger() utils.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return utils.NewLogrusLogger(logger)
+}
@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"context"
+	"strings"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// column type of new column by field type
+var postgreSQLColumnTypes = map[transformation.FieldType]string{
+	transformation.FieldString: "TEXT",
+	transformation.FieldFloat:  "DOUBLE PRECISION",
+	transformation.FieldInt:    "BIGINT",
+	transformation.FieldBool:   "BOOLEAN",
+	transformation.FieldDate:   "TIMESTAMPTZ",
+}
+
+// information_schema data types able to store field type
+var postgreSQLAcceptedTypes = map[transformation.FieldType][]string{
+	transformation.FieldString: {"text", "character varying", "character"},
+	transformation.FieldFloat:  {"double precision", "real", "numeric"},
+	transformation.FieldInt:    {"bigint", "integer", "smallint", "numeric"},
+	transformation.FieldBool:   {"boolean"},
+	transformation.FieldDate:   {"timestamp with time zone", "timestamp without time zone", "date"},
+}
+
+// catalog of PostgreSQL tables read from information_schema
+type PostgreSQLCatalog struct {
+	db *sqlx.DB
+}
+
+func NewPostgreSQLCatalog(db *sqlx.DB) *PostgreSQLCatalog {
+	return &PostgreSQLCatalog{
+		db: db,
+	}
+}
+
+type postgresqlColumn struct {
+	Name       string `db:"column_name"`
+	DataType   string `db:"data_type"`
+	Nullable   bool   `db:"nullable"`
+	HasDefault bool   `db:"has_default"`
+}
+
+// columns of table in current schema, or schema of schema qualified table (e.g. sales.orders)
+func (p *PostgreSQLCatalog) Columns(ctx context.Context, table string) ([]Column, error) {
+	schema, name := "", table
+	if i := strings.LastIndex(table, "."); i != -1 {
+		schema, name = table[:i], table[i+1:]
+	}
+
+	rows := make([]postgresqlColumn, 0)
+	err := p.db.SelectContext(ctx, &rows, `SELECT column_name, data_type, is_nullable = 'YES' AS nullable,
+		(column_default IS NOT NULL OR is_identity = 'YES' OR is_generated <> 'NEVER') AS has_default
+		FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+		ORDER BY ordinal_position`, schema, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read columns of table %s", table)
+	}
+
+	columns := make([]Column, 0, len(rows))
+	for _, row := range rows {
+		columns = append(columns, Column(row))
+	}
+	return columns, nil
+}
+
+func (p *PostgreSQLCatalog) Accepts(fieldType transformation.FieldType, dataType string) bool {
+	for _, accepted := range postgreSQLAcceptedTypes[fieldType] {
+		if accepted == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+// create missing table or add missing nullable columns in a transaction
+func (p *PostgreSQLCatalog) Migrate(ctx context.Context, drift Drift) (err error) {
+	if !drift.Additive() {
+		return nil
+	}
+
+	table := pgx.Identifier(strings.Split(drift.Table, ".")).Sanitize()
+	columns := make([]string, 0, len(drift.MissingColumns))
+	for _, column := range drift.MissingColumns {
+		columnType, ok := postgreSQLColumnTypes[column.Type]
+		if !ok {
+			return errors.Errorf("field %s has unsupported type %s", column.Field, column.Type)
+		}
+		if column.DateOnly {
+			columnType = "DATE"
+		}
+		columns = append(columns, pgx.Identifier{column.Column}.Sanitize()+" "+columnType)
+	}
+
+	var statement string
+	if drift.MissingTable {
+		statement = "CREATE TABLE IF NOT EXISTS " + table + " (" + strings.Join(columns, ", ") + ")"
+	} else {
+		statement = "ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS " + strings.Join(columns, ", ADD COLUMN IF NOT EXISTS ")
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to start transaction")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err = tx.ExecContext(ctx, statement); err != nil {
+		return errors.Wrapf(err, "unable to migrate table %s", drift.Table)
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "unable to commit migration")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/pkg/errors"
+)
+
+// registered version of entity schema
+type Version struct {
+	Entity       string
+	Version      int
+	RegisteredAt time.Time
+	Table        string
+	Fields       []config.EntityFieldConfig
+}
+
+// entity config of registered version
+func (v Version) EntityConfig() config.EntityConfig {
+	return config.EntityConfig{
+		Name:   v.Entity,
+		Table:  v.Table,
+		Fields: append([]config.EntityFieldConfig(nil), v.Fields...),
+	}
+}
+
+// entity schema is incompatible with latest registered version
+type IncompatibleError struct {
+	Entity  string
+	Version int
+	Changes []Change
+}
+
+func (e *IncompatibleError) Error() string {
+	changes := make([]string, 0, len(e.Changes))
+	for _, change := range e.Changes {
+		if !change.Compatible {
+			changes = append(changes, change.Description)
+		}
+	}
+	return fmt.Sprintf("entity %s is incompatible with registered schema v%d: %s", e.Entity, e.Version, strings.Join(changes, ", "))
+}
+
+// local registry keeping every version of entity schemas in <path>/<entity>/v<version>.json
+// registered versions are never changed, so the directory can be committed as history of entity schemas
+type Registry struct {
+	path string
+	now  func() time.Time
+}
+
+func NewRegistry(path string) *Registry {
+	return &Registry{
+		path: path,
+		now:  time.Now,
+	}
+}
+
+// registered versions of entity sorted by version
+func (r *Registry) Versions(entity string) ([]Version, error) {
+	files, err := filepath.Glob(filepath.Join(r.path, entity, "v*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list schema versions")
+	}
+
+	versions := make([]Version, 0, len(files))
+	for _, file := range files {
+		if _, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "v"), ".json")); err != nil {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read schema version")
+		}
+		var version Version
+		if err := json.Unmarshal(content, &version); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse schema version %s", file)
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+
+	return versions, nil
+}
+
+// latest registered version of entity, nil if entity is not registered
+func (r *Registry) Latest(entity string) (*Version, error) {
+	versions, err := r.Versions(entity)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[len(versions)-1], nil
+}
+
+// changes of entity config since latest registered version
+// return IncompatibleError if any change is incompatible
+func (r *Registry) Check(entity config.EntityConfig) ([]Change, error) {
+	latest, err := r.Latest(entity.Name)
+	if err != nil || latest == nil {
+		return nil, err
+	}
+
+	changes := Diff(latest.EntityConfig(), entity)
+	for _, change := range changes {
+		if !change.Compatible {
+			return changes, &IncompatibleError{Entity: entity.Name, Version: latest.Version, Changes: changes}
+		}
+	}
+	return changes, nil
+}
+
+// register entity config as new version if it is changed since latest registered version
+// return registered version and whether it is a new version, incompatible change is refused
+func (r *Registry) Register(entity config.EntityConfig) (Version, bool, error) {
+	latest, err := r.Latest(entity.Name)
+	if err != nil {
+		return Version{}, false, err
+	}
+	changes, err := r.Check(entity)
+	if err != nil {
+		return Version{}, false, err
+	}
+	if latest != nil && len(changes) == 0 {
+		return *latest, false, nil
+	}
+
+	version := Version{
+		Entity:       entity.Name,
+		Version:      1,
+		RegisteredAt: r.now().UTC(),
+		Table:        entity.Table,
+		Fields:       append([]config.EntityFieldConfig(nil), entity.Fields...),
+	}
+	if latest != nil {
+		version.Version = latest.Version + 1
+	}
+	if err := r.write(version); err != nil {
+		return Version{}, false, err
+	}
+
+	return version, true, nil
+}
+
+// write version file atomically, existing version is never overwritten
+func (r *Registry) write(version Version) error {
+	dir := filepath.Join(r.path, version.Entity)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrap(err, "unable to create schema registry directory")
+	}
+	content, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal schema version")
+	}
+
+	temp, err := os.CreateTemp(dir, ".version-*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create schema version file")
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+		return errors.Wrap(err, "unable to write schema version file")
+	}
+	if err := temp.Close(); err != nil {
+		return errors.Wrap(err, "unable to write schema version file")
+	}
+
+	// link fails if the version is registered concurrently
+	if err := os.Link(temp.Name(), filepath.Join(dir, fmt.Sprintf("v%d.json", version.Version))); err != nil {
+		return errors.Wrapf(err, "unable to register schema v%d of entity %s", version.Version, version.Entity)
+	}
+	return nil
+}
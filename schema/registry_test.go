@@ -0,0 +1,174 @@
+package schema_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/schema"
+)
+
+func TestRegister(t *testing.T) {
+	type testcase struct {
+		testcase           string
+		entity             config.EntityConfig
+		expectedVersion    int
+		expectedRegistered bool
+		expectedErr        bool
+	}
+
+	// testcases run in order against same registry
+	testcases := []testcase{
+		{
+			testcase:           "First version",
+			entity:             orders(),
+			expectedVersion:    1,
+			expectedRegistered: true,
+		},
+		{
+			testcase:           "Unchanged entity",
+			entity:             orders(),
+			expectedVersion:    1,
+			expectedRegistered: false,
+		},
+		{
+			testcase:           "Field added",
+			entity:             orders(config.EntityFieldConfig{Name: "Paid", Type: "bool", Column: "paid"}),
+			expectedVersion:    2,
+			expectedRegistered: true,
+		},
+		{
+			testcase:    "Field removed",
+			entity:      orders(),
+			expectedErr: true,
+		},
+		{
+			testcase: "Field type changed",
+			entity: config.EntityConfig{Name: "orders", Table: "orders", Fields: []config.EntityFieldConfig{
+				{Name: "OrderId", Type: "int", Column: "order_id"},
+				{Name: "Total", Type: "string", Column: "total"},
+				{Name: "Paid", Type: "bool", Column: "paid"},
+			}},
+			expectedErr: true,
+		},
+	}
+
+	registry := schema.NewRegistry(t.TempDir())
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			version, registered, err := registry.Register(tc.entity)
+			if tc.expectedErr {
+				var incompatibleErr *schema.IncompatibleError
+				if !errors.As(err, &incompatibleErr) {
+					t.Fatalf("expected incompatible error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if version.Version != tc.expectedVersion {
+				t.Errorf("expected %v, but got %v", tc.expectedVersion, version.Version)
+			}
+			if registered != tc.expectedRegistered {
+				t.Errorf("expected %v, but got %v", tc.expectedRegistered, registered)
+			}
+		})
+	}
+
+	versions, err := registry.Versions("orders")
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected %v, but got %v", 2, len(versions))
+	}
+	if !reflect.DeepEqual(versions[0].EntityConfig(), orders()) {
+		t.Errorf("expected %+v, but got %+v", orders(), versions[0].EntityConfig())
+	}
+}
+
+func TestRegistryIgnoreOtherFiles(t *testing.T) {
+	path := t.TempDir()
+	os.MkdirAll(filepath.Join(path, "orders"), 0o755)
+	os.WriteFile(filepath.Join(path, "orders", "vnext.json"), []byte("not json"), 0o644)
+
+	latest, err := schema.NewRegistry(path).Latest("orders")
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if latest != nil {
+		t.Errorf("expected %v, but got %+v", nil, latest)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type testcase struct {
+		testcase           string
+		old                config.EntityConfig
+		new                config.EntityConfig
+		expectedChanges    []string
+		expectedCompatible bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase:           "Unchanged with different field order",
+			old:                orders(),
+			new:                config.EntityConfig{Name: "orders", Table: "orders", Fields: []config.EntityFieldConfig{orders().Fields[1], orders().Fields[0]}},
+			expectedChanges:    []string{},
+			expectedCompatible: true,
+		},
+		{
+			testcase:           "Field added",
+			old:                orders(),
+			new:                orders(config.EntityFieldConfig{Name: "Paid", Type: "bool", Column: "paid"}),
+			expectedChanges:    []string{"field Paid (bool) added"},
+			expectedCompatible: true,
+		},
+		{
+			testcase:        "Table and column changed",
+			old:             orders(),
+			new:             config.EntityConfig{Name: "orders", Table: "sales.orders", Fields: []config.EntityFieldConfig{orders().Fields[0], {Name: "Total", Type: "float", Column: "amount"}}},
+			expectedChanges: []string{"table changed from orders to sales.orders", "field Total column changed from total to amount"},
+		},
+		{
+			testcase:        "Field removed",
+			old:             orders(),
+			new:             config.EntityConfig{Name: "orders", Table: "orders", Fields: orders().Fields[:1]},
+			expectedChanges: []string{"field Total removed"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			changes := schema.Diff(tc.old, tc.new)
+			descriptions := make([]string, 0, len(changes))
+			compatible := true
+			for _, change := range changes {
+				descriptions = append(descriptions, change.Description)
+				compatible = compatible && change.Compatible
+			}
+			if !reflect.DeepEqual(descriptions, tc.expectedChanges) {
+				t.Errorf("expected %v, but got %v", tc.expectedChanges, descriptions)
+			}
+			if compatible != tc.expectedCompatible {
+				t.Errorf("expected %v, but got %v", tc.expectedCompatible, compatible)
+			}
+		})
+	}
+}
+
+func orders(fields ...config.EntityFieldConfig) config.EntityConfig {
+	return config.EntityConfig{
+		Name:  "orders",
+		Table: "orders",
+		Fields: append([]config.EntityFieldConfig{
+			{Name: "OrderId", Type: "int", Column: "order_id"},
+			{Name: "Total", Type: "float", Column: "total"},
+		}, fields...),
+	}
+}
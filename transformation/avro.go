@@ -0,0 +1,173 @@
+package transformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// namespace of entity record schemas
+const avroNamespace = "etl"
+
+// Avro encoding of records (https://avro.apache.org/docs/current/specification/)
+// each entity is a record schema named by entity with a nullable field named by column of each entity field,
+// and records are single object encoded (schema fingerprint before binary data), so reader can find the schema of each message
+type AvroCodecs struct {
+	entities     map[string]*avroCodec
+	fingerprints map[uint64]*avroCodec
+}
+
+type avroCodec struct {
+	schema *Schema
+	codec  *goavro.Codec
+	// avro field name and union branch of each field in schema order
+	names    []string
+	branches []string
+}
+
+// create codec of each entity schema
+func NewAvroCodecs(schemas Schemas) (*AvroCodecs, error) {
+	a := &AvroCodecs{
+		entities:     make(map[string]*avroCodec, len(schemas)),
+		fingerprints: make(map[uint64]*avroCodec, len(schemas)),
+	}
+	for _, entity := range schemas.entities() {
+		schema := schemas[entity]
+		c := &avroCodec{schema: schema}
+		fields := make([]map[string]interface{}, 0, len(schema.fields))
+		used := make(map[string]string, len(schema.fields))
+		for _, field := range schema.fields {
+			column := field.Column
+			if column == "" {
+				column = field.Name
+			}
+			name := avroName(column)
+			if other, ok := used[name]; ok {
+				return nil, fmt.Errorf("fields %s and %s of entity %s have same avro name %s", other, field.Name, entity, name)
+			}
+			used[name] = field.Name
+
+			avroType, branch := avroFieldType(field)
+			c.names = append(c.names, name)
+			c.branches = append(c.branches, branch)
+			fields = append(fields, map[string]interface{}{"name": name, "type": []interface{}{"null", avroType}, "default": nil})
+		}
+
+		spec, err := json.Marshal(map[string]interface{}{"type": "record", "name": avroName(entity), "namespace": avroNamespace, "fields": fields})
+		if err != nil {
+			return nil, err
+		}
+		if c.codec, err = goavro.NewCodec(string(spec)); err != nil {
+			return nil, fmt.Errorf("unable to create avro schema of entity %s: %w", entity, err)
+		}
+		a.entities[entity] = c
+		a.fingerprints[c.codec.Rabin] = c
+	}
+	return a, nil
+}
+
+// avro type and union branch name of field, date is timestamp in microseconds or date in days if DateOnly
+func avroFieldType(field Field) (interface{}, string) {
+	switch field.Type {
+	case FieldFloat:
+		return "double", "double"
+	case FieldInt:
+		return "long", "long"
+	case FieldBool:
+		return "boolean", "boolean"
+	case FieldDate:
+		if field.DateOnly {
+			return map[string]interface{}{"type": "int", "logicalType": "date"}, "int.date"
+		}
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-micros"}, "long.timestamp-micros"
+	}
+	return "string", "string"
+}
+
+// avro name of letters, digits and underscores not starting with digit (e.g. customer-email -> customer_email)
+func avroName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+		default:
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// avro schema of entity in JSON
+func (a *AvroCodecs) Schema(entity string) (string, bool) {
+	c, ok := a.entities[entity]
+	if !ok {
+		return "", false
+	}
+	return c.codec.Schema(), true
+}
+
+// single object encoding of record by schema of its entity
+// nil field and zero date are null, value of other type than field type (e.g. hashed date) is an error
+func (a *AvroCodecs) Encode(record Record) ([]byte, error) {
+	c, ok := a.entities[record.Entity]
+	if !ok {
+		return nil, fmt.Errorf("entity %s not found", record.Entity)
+	}
+	datum := make(map[string]interface{}, len(c.names))
+	for i, field := range c.schema.fields {
+		value := record.Get(field.Name)
+		if t, isTime := value.(time.Time); value == nil || isTime && t.IsZero() {
+			continue
+		}
+		datum[c.names[i]] = goavro.Union(c.branches[i], value)
+	}
+	data, err := c.codec.SingleFromNative(nil, datum)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode %s record in avro: %w", record.Entity, err)
+	}
+	return data, nil
+}
+
+// data starts with single object encoding marker
+func IsAvro(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0xC3 && data[1] == 0x01
+}
+
+// decode single object encoded record of entity whose schema has the fingerprint of data
+// null field is not set, so it is nil after record is conformed to entity schema
+func (a *AvroCodecs) Decode(data []byte) (Record, error) {
+	fingerprint, _, err := goavro.FingerprintFromSOE(data)
+	if err != nil {
+		return Record{}, fmt.Errorf("unable to decode avro record: %w", err)
+	}
+	c, ok := a.fingerprints[fingerprint]
+	if !ok {
+		return Record{}, fmt.Errorf("avro schema fingerprint %x is not schema of any entity", fingerprint)
+	}
+	native, _, err := c.codec.NativeFromSingle(data)
+	if err != nil {
+		return Record{}, fmt.Errorf("unable to decode avro %s record: %w", c.schema.Entity, err)
+	}
+	datum, _ := native.(map[string]interface{})
+
+	record := NewRecord(c.schema.Entity)
+	for i, field := range c.schema.fields {
+		value := datum[c.names[i]]
+		// non null value is map of union branch to value
+		if union, ok := value.(map[string]interface{}); ok {
+			value = union[c.branches[i]]
+		}
+		if value != nil {
+			record.Set(field.Name, value)
+		}
+	}
+	return record, nil
+}
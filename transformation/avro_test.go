@@ -0,0 +1,190 @@
+package transformation_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAvroCodecs(t *testing.T) {
+	type testcase struct {
+		testcase            string
+		record              transformation.Record
+		expectedFields      map[string]interface{}
+		expectedEncodeError bool
+	}
+
+	placedAt := time.Date(2024, 1, 2, 3, 4, 5, 678000, time.UTC)
+	testcases := []testcase{
+		{
+			testcase: "Every field type",
+			record: transformation.Record{Entity: "orders", Fields: map[string]interface{}{
+				"OrderId": int64(1), "Customer.Email": "a@example.com", "Total": 9.5, "Paid": true, "PlacedAt": placedAt, "DeliveryDate": time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			}},
+			expectedFields: map[string]interface{}{
+				"OrderId": int64(1), "Customer.Email": "a@example.com", "Total": 9.5, "Paid": true, "PlacedAt": placedAt, "DeliveryDate": time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			testcase: "Nil and zero date are null",
+			record: transformation.Record{Entity: "orders", Fields: map[string]interface{}{
+				"OrderId": int64(2), "Customer.Email": nil, "PlacedAt": time.Time{},
+			}},
+			expectedFields: map[string]interface{}{"OrderId": int64(2)},
+		},
+		{
+			testcase:            "Unknown entity",
+			record:              transformation.Record{Entity: "users", Fields: map[string]interface{}{"OrderId": int64(1)}},
+			expectedEncodeError: true,
+		},
+		{
+			testcase:            "Wrong type",
+			record:              transformation.Record{Entity: "orders", Fields: map[string]interface{}{"PlacedAt": "2024-01-02"}},
+			expectedEncodeError: true,
+		},
+	}
+
+	codecs := newAvroCodecs(t)
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			data, err := codecs.Encode(tc.record)
+			if tc.expectedEncodeError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if !transformation.IsAvro(data) {
+				t.Fatalf("expected single object encoding, but got %v", data)
+			}
+
+			record, err := codecs.Decode(data)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if record.Entity != tc.record.Entity {
+				t.Errorf("expected %v, but got %v", tc.record.Entity, record.Entity)
+			}
+			if !reflect.DeepEqual(record.Fields, tc.expectedFields) {
+				t.Errorf("expected %v, but got %v", tc.expectedFields, record.Fields)
+			}
+		})
+	}
+}
+
+func TestRecordTransformer(t *testing.T) {
+	type testcase struct {
+		testcase          string
+		data              func(codecs *transformation.AvroCodecs) []byte
+		expectedEntity    string
+		expectedOperation string
+		expectedFields    map[string]interface{}
+		expectedError     bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "JSON record",
+			data: func(codecs *transformation.AvroCodecs) []byte {
+				return []byte(`{"Metadata": {"CorrelationID": "a", "Position": "3"}, "Entity": "orders", "Fields": {"OrderId": 1}, "Operation": "delete"}`)
+			},
+			expectedEntity:    "orders",
+			expectedOperation: transformation.OperationDelete,
+			expectedFields:    map[string]interface{}{"OrderId": int64(1)},
+		},
+		{
+			testcase: "Avro record",
+			data: func(codecs *transformation.AvroCodecs) []byte {
+				data, _ := codecs.Encode(transformation.Record{Entity: "orders", Fields: map[string]interface{}{"OrderId": int64(1)}})
+				return data
+			},
+			expectedEntity: "orders",
+			expectedFields: map[string]interface{}{"OrderId": int64(1)},
+		},
+		{
+			testcase: "Avro record of unknown schema",
+			data: func(codecs *transformation.AvroCodecs) []byte {
+				return []byte{0xC3, 0x01, 1, 2, 3, 4, 5, 6, 7, 8, 2}
+			},
+			expectedError: true,
+		},
+		{
+			testcase: "Not record",
+			data: func(codecs *transformation.AvroCodecs) []byte {
+				return []byte(`null`)
+			},
+			expectedError: true,
+		},
+	}
+
+	codecs := newAvroCodecs(t)
+	schemas := newOrderSchemas(t)
+	transformer := transformation.NewRecordTransformer(codecs)
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			records, err := transformer(utils.NewLogrusLogger(logrus.StandardLogger()), tc.data(codecs))
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("expected 1 record, but got %v", records)
+			}
+			record := records[0]
+			if record.Metadata != (transformation.RecordMetadata{}) {
+				t.Errorf("expected empty metadata, but got %v", record.Metadata)
+			}
+			if record.Entity != tc.expectedEntity || record.Operation != tc.expectedOperation {
+				t.Errorf("expected %v %v record, but got %v %v", tc.expectedOperation, tc.expectedEntity, record.Operation, record.Entity)
+			}
+			if err := schemas.Conform(&record); err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			for name, expected := range tc.expectedFields {
+				if record.Get(name) != expected {
+					t.Errorf("expected %v, but got %v", expected, record.Get(name))
+				}
+			}
+		})
+	}
+}
+
+func newOrderSchemas(t *testing.T) transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{{
+		Name:  "orders",
+		Table: "orders",
+		Fields: []config.EntityFieldConfig{
+			{Name: "OrderId", Type: "int", Column: "order_id"},
+			{Name: "Customer.Email", Type: "string", Column: "customer_email"},
+			{Name: "Total", Type: "float", Column: "total"},
+			{Name: "Paid", Type: "bool", Column: "paid"},
+			{Name: "PlacedAt", Type: "date", Column: "placed_at"},
+			{Name: "DeliveryDate", Type: "date", Column: "delivery_date", DateOnly: true},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	return schemas
+}
+
+func newAvroCodecs(t *testing.T) *transformation.AvroCodecs {
+	codecs, err := transformation.NewAvroCodecs(newOrderSchemas(t))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	return codecs
+}
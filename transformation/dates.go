@@ -0,0 +1,145 @@
+package transformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+)
+
+// layouts detected automatically in order, day first for dd/mm/yyyy (mm/dd/yyyy should be configured as layout)
+var detectedLayouts = []string{
+	// ISO 8601 and RFC 3339
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+	"20060102",
+	"2006/01/02",
+	// day first
+	"02/01/2006",
+	"02-01-2006",
+	"02.01.2006",
+	"2/1/2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	time.RFC1123,
+	time.RFC1123Z,
+}
+
+// absolute epoch value from which epoch is milliseconds instead of seconds (year 5138 in seconds)
+const epochMillisecondsFrom = 1e11
+
+// parse date values with configured layouts followed by automatically detected formats
+// value without offset is in source timezone, parsed date is normalized to UTC
+type DateParser struct {
+	layouts  []string
+	location *time.Location
+}
+
+// parser of automatically detected formats in UTC
+var DefaultDateParser = &DateParser{location: time.UTC}
+
+// create parser from date config, timezone is IANA name (e.g. Asia/Hong_Kong)
+func NewDateParser(c config.DateConfig) (*DateParser, error) {
+	location := time.UTC
+	if c.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(c.Timezone); err != nil {
+			return nil, fmt.Errorf("timezone %s is invalid: %w", c.Timezone, err)
+		}
+	}
+	for _, layout := range c.Layouts {
+		if strings.TrimSpace(layout) == "" {
+			return nil, fmt.Errorf("layout is empty")
+		}
+	}
+
+	return &DateParser{
+		layouts:  append([]string(nil), c.Layouts...),
+		location: location,
+	}, nil
+}
+
+// source timezone of values without offset
+func (p *DateParser) Location() *time.Location {
+	return p.location
+}
+
+// parse string by layouts, or as epoch seconds / milliseconds if it is a number (except yyyymmdd)
+// empty string is zero time
+func (p *DateParser) Parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range p.layouts {
+		if t, err := time.ParseInLocation(layout, value, p.location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if len(value) != len("20060102") {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return p.epoch(n)
+		}
+	}
+	for _, layout := range detectedLayouts {
+		if t, err := time.ParseInLocation(layout, value, p.location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a supported date", value)
+}
+
+// parse date from time, string or epoch number
+func (p *DateParser) ParseValue(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v.UTC(), nil
+	case string:
+		return p.Parse(v)
+	case json.Number:
+		return p.Parse(v.String())
+	}
+	if n, ok := toFloat(value); ok {
+		return p.epoch(n)
+	}
+	return time.Time{}, fmt.Errorf("value %v is %T but not %s", value, value, FieldDate)
+}
+
+// epoch seconds or milliseconds (if absolute value is too large for seconds) in UTC
+func (p *DateParser) epoch(n float64) (time.Time, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return time.Time{}, fmt.Errorf("%v is not a supported date", n)
+	}
+	if math.Abs(n) >= epochMillisecondsFrom {
+		return time.UnixMilli(int64(n)).UTC(), nil
+	}
+	seconds, fraction := math.Modf(n)
+	return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+}
+
+// calendar date of time in source timezone as UTC midnight, so it is stored as same date in DATE column
+func (p *DateParser) Date(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	t = t.In(p.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
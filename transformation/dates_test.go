@@ -0,0 +1,105 @@
+package transformation_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+func TestParseDate(t *testing.T) {
+	type testcase struct {
+		testcase      string
+		config        config.DateConfig
+		value         interface{}
+		expectedTime  time.Time
+		expectedError bool
+	}
+
+	dob := time.Date(1981, time.August, 30, 0, 0, 0, 0, time.UTC)
+
+	testcases := []testcase{
+		{testcase: "Date", value: "1981-08-30", expectedTime: dob},
+		{testcase: "Basic date", value: "19810830", expectedTime: dob},
+		{testcase: "RFC3339 normalized to UTC", value: "1981-08-30T08:00:00+08:00", expectedTime: dob},
+		{testcase: "ISO 8601 without offset", value: "1981-08-30T12:30:00", expectedTime: dob.Add(12*time.Hour + 30*time.Minute)},
+		{testcase: "Space separated time", value: "1981-08-30 12:30:00.5", expectedTime: dob.Add(12*time.Hour + 30*time.Minute + 500*time.Millisecond)},
+		{testcase: "Day first", value: "30/08/1981", expectedTime: dob},
+		{testcase: "Day first with dot", value: "30.08.1981", expectedTime: dob},
+		{testcase: "Month name", value: "Aug 30, 1981", expectedTime: dob},
+		{testcase: "Epoch seconds", value: "367977600", expectedTime: dob},
+		{testcase: "Negative epoch seconds", value: json.Number("-86400"), expectedTime: time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{testcase: "Epoch milliseconds", value: int64(367977600000), expectedTime: dob},
+		{testcase: "Epoch float seconds", value: 367977600.5, expectedTime: dob.Add(500 * time.Millisecond)},
+		{testcase: "Configured month first layout", config: config.DateConfig{Layouts: []string{"01/02/2006"}}, value: "08/30/1981", expectedTime: dob},
+		{testcase: "Month first not detected", value: "08/30/1981", expectedError: true},
+		{testcase: "Source timezone", config: config.DateConfig{Timezone: "Asia/Hong_Kong"}, value: "1981-08-30 08:00:00", expectedTime: dob},
+		{testcase: "Offset overrides source timezone", config: config.DateConfig{Timezone: "Asia/Hong_Kong"}, value: "1981-08-30T00:00:00Z", expectedTime: dob},
+		{testcase: "Empty", value: "", expectedTime: time.Time{}},
+		{testcase: "Invalid", value: "yesterday", expectedError: true},
+		{testcase: "Bool", value: true, expectedError: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			parser, err := transformation.NewDateParser(tc.config)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			v, err := parser.ParseValue(tc.value)
+			if tc.expectedError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tc.expectedError && err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+			if !v.Equal(tc.expectedTime) || (!v.IsZero() && v.Location() != time.UTC) {
+				t.Errorf("expected %v, but got %v", tc.expectedTime, v)
+			}
+		})
+	}
+}
+
+func TestConformDateOnly(t *testing.T) {
+	parser, err := transformation.NewDateParser(config.DateConfig{Timezone: "America/New_York"})
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	schemas, err := transformation.NewSchemasWithDates([]config.EntityConfig{{
+		Name:  "orders",
+		Table: "orders",
+		Fields: []config.EntityFieldConfig{
+			{Name: "PlacedAt", Type: "date", Column: "placed_at"},
+			{Name: "PlacedOn", Type: "date", Column: "placed_on", DateOnly: true},
+		},
+	}}, parser)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	// 22:00 in New York is next day in UTC, date only field keeps the date in source timezone
+	record := transformation.Record{Entity: "orders", Fields: map[string]interface{}{
+		"PlacedAt": "2024-03-01 22:00:00",
+		"PlacedOn": "2024-03-01 22:00:00",
+	}}
+	if err := schemas.Conform(&record); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	expectedAt := time.Date(2024, time.March, 2, 3, 0, 0, 0, time.UTC)
+	expectedOn := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if !record.Time("PlacedAt").Equal(expectedAt) {
+		t.Errorf("expected %v, but got %v", expectedAt, record.Time("PlacedAt"))
+	}
+	if record.Time("PlacedOn") != expectedOn {
+		t.Errorf("expected %v, but got %v", expectedOn, record.Time("PlacedOn"))
+	}
+}
+
+func TestNewDateParserInvalidTimezone(t *testing.T) {
+	if _, err := transformation.NewDateParser(config.DateConfig{Timezone: "Mars/Olympus_Mons"}); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
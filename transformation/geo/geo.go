@@ -0,0 +1,214 @@
+package geo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// simplified timezone boundaries with country of each timezone (see data/NOTICE for source and format)
+//
+//go:embed data/boundaries.txt.gz
+var data embed.FS
+
+// timezone and country containing coordinates
+type Location struct {
+	// IANA timezone (e.g. America/New_York)
+	Timezone string
+	// ISO 3166-1 alpha-2 code
+	CountryCode string
+}
+
+type point struct {
+	lng float64
+	lat float64
+}
+
+type polygon struct {
+	zone  *Location
+	ring  []point
+	holes [][]point
+	// bounding box for skipping polygons quickly
+	min point
+	max point
+}
+
+var (
+	loadOnce sync.Once
+	polygons []*polygon
+)
+
+// reverse lookup timezone and country of coordinates on land (including territorial waters)
+// return false if coordinates are invalid or in international waters
+func Lookup(lat float64, lng float64) (Location, bool) {
+	if !Valid(lat, lng) {
+		return Location{}, false
+	}
+
+	p := point{lng: lng, lat: lat}
+	for _, polygon := range load() {
+		if polygon.contains(p) {
+			return *polygon.zone, true
+		}
+	}
+	return Location{}, false
+}
+
+// timezone of coordinates, nautical timezone (e.g. Etc/GMT+5) is used for international waters
+// return empty string if coordinates are invalid
+func Timezone(lat float64, lng float64) string {
+	if !Valid(lat, lng) {
+		return ""
+	}
+	if location, ok := Lookup(lat, lng); ok {
+		return location.Timezone
+	}
+	return NauticalTimezone(lng)
+}
+
+// timezone of international waters by longitude (15 degree per hour)
+func NauticalTimezone(lng float64) string {
+	// sign of Etc/GMT zone is inverted (Etc/GMT-8 is UTC+8)
+	offset := int(math.Round(lng / 15))
+	switch {
+	case offset > 0:
+		return fmt.Sprintf("Etc/GMT-%d", offset)
+	case offset < 0:
+		return fmt.Sprintf("Etc/GMT+%d", -offset)
+	}
+	return "Etc/GMT"
+}
+
+// latitude within [-90, 90] and longitude within [-180, 180]
+func Valid(lat float64, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// point in exterior ring but not in any hole
+func (p *polygon) contains(pt point) bool {
+	if pt.lng < p.min.lng || pt.lng > p.max.lng || pt.lat < p.min.lat || pt.lat > p.max.lat {
+		return false
+	}
+	if !inRing(pt, p.ring) {
+		return false
+	}
+	for _, hole := range p.holes {
+		if inRing(pt, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// ray casting
+func inRing(pt point, ring []point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.lat > pt.lat) != (b.lat > pt.lat) && pt.lng < (b.lng-a.lng)*(pt.lat-a.lat)/(b.lat-a.lat)+a.lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// embedded dataset is parsed on first lookup
+func load() []*polygon {
+	loadOnce.Do(func() {
+		parsed, err := parse()
+		if err != nil {
+			panic(fmt.Sprintf("invalid embedded boundary dataset: %v", err))
+		}
+		polygons = parsed
+	})
+	return polygons
+}
+
+func parse() ([]*polygon, error) {
+	f, err := data.Open("data/boundaries.txt.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := make([]*polygon, 0)
+	var zone *Location
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		kind, value, _ := strings.Cut(scanner.Text(), " ")
+		switch kind {
+		case "Z":
+			timezone, country, ok := strings.Cut(value, " ")
+			if !ok {
+				return nil, fmt.Errorf("line %d: missing country of zone", line)
+			}
+			zone = &Location{Timezone: timezone, CountryCode: country}
+		case "P", "H":
+			ring, err := parseRing(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if kind == "H" {
+				if len(parsed) == 0 {
+					return nil, fmt.Errorf("line %d: hole without polygon", line)
+				}
+				parsed[len(parsed)-1].holes = append(parsed[len(parsed)-1].holes, ring)
+				continue
+			}
+			if zone == nil {
+				return nil, fmt.Errorf("line %d: polygon without zone", line)
+			}
+			parsed = append(parsed, newPolygon(zone, ring))
+		default:
+			return nil, fmt.Errorf("line %d: unknown record %q", line, kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+func parseRing(value string) ([]point, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("ring has %d points", len(fields))
+	}
+
+	ring := make([]point, 0, len(fields))
+	for _, field := range fields {
+		lng, lat, _ := strings.Cut(field, ",")
+		x, err := strconv.ParseFloat(lng, 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(lat, 64)
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, point{lng: x, lat: y})
+	}
+	return ring, nil
+}
+
+func newPolygon(zone *Location, ring []point) *polygon {
+	p := &polygon{zone: zone, ring: ring, min: ring[0], max: ring[0]}
+	for _, pt := range ring[1:] {
+		p.min.lng = math.Min(p.min.lng, pt.lng)
+		p.min.lat = math.Min(p.min.lat, pt.lat)
+		p.max.lng = math.Max(p.max.lng, pt.lng)
+		p.max.lat = math.Max(p.max.lat, pt.lat)
+	}
+	return p
+}
@@ -0,0 +1,69 @@
+package geo_test
+
+import (
+	"testing"
+
+	"github.com/awcjack/ETL-sample/transformation/geo"
+)
+
+func TestLookup(t *testing.T) {
+	type testcase struct {
+		testcase         string
+		lat              float64
+		lng              float64
+		expected         geo.Location
+		expectedOk       bool
+		expectedTimezone string
+	}
+
+	testcases := []testcase{
+		{testcase: "New York", lat: 40.7128, lng: -74.006, expected: geo.Location{Timezone: "America/New_York", CountryCode: "US"}, expectedOk: true, expectedTimezone: "America/New_York"},
+		{testcase: "London", lat: 51.5074, lng: -0.1278, expected: geo.Location{Timezone: "Europe/London", CountryCode: "GB"}, expectedOk: true, expectedTimezone: "Europe/London"},
+		{testcase: "Tokyo", lat: 35.6762, lng: 139.6503, expected: geo.Location{Timezone: "Asia/Tokyo", CountryCode: "JP"}, expectedOk: true, expectedTimezone: "Asia/Tokyo"},
+		{testcase: "Sydney", lat: -33.8688, lng: 151.2093, expected: geo.Location{Timezone: "Australia/Sydney", CountryCode: "AU"}, expectedOk: true, expectedTimezone: "Australia/Sydney"},
+		{testcase: "Lesotho inside South Africa", lat: -29.31, lng: 27.48, expected: geo.Location{Timezone: "Africa/Maseru", CountryCode: "LS"}, expectedOk: true, expectedTimezone: "Africa/Maseru"},
+		{testcase: "Atlantic ocean", lat: 30, lng: -45, expectedOk: false, expectedTimezone: "Etc/GMT+3"},
+		{testcase: "Null island", lat: 0, lng: 0, expectedOk: false, expectedTimezone: "Etc/GMT"},
+		{testcase: "Invalid coordinates", lat: 91, lng: 0, expectedOk: false, expectedTimezone: ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			location, ok := geo.Lookup(tc.lat, tc.lng)
+			if ok != tc.expectedOk {
+				t.Fatalf("expected %v, but got %v", tc.expectedOk, ok)
+			}
+			if location != tc.expected {
+				t.Errorf("expected %+v, but got %+v", tc.expected, location)
+			}
+			if timezone := geo.Timezone(tc.lat, tc.lng); timezone != tc.expectedTimezone {
+				t.Errorf("expected %v, but got %v", tc.expectedTimezone, timezone)
+			}
+		})
+	}
+}
+
+func TestGeohash(t *testing.T) {
+	type testcase struct {
+		testcase  string
+		lat       float64
+		lng       float64
+		precision int
+		expected  string
+	}
+
+	testcases := []testcase{
+		{testcase: "Precision 5", lat: 42.6, lng: -5.6, precision: 5, expected: "ezs42"},
+		{testcase: "Precision 9", lat: 57.64911, lng: 10.40744, precision: 9, expected: "u4pruydqq"},
+		{testcase: "Precision capped", lat: 57.64911, lng: 10.40744, precision: 20, expected: "u4pruydqqvj8"},
+		{testcase: "Invalid coordinates", lat: 0, lng: 181, precision: 9, expected: ""},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			if v := geo.Geohash(tc.lat, tc.lng, tc.precision); v != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package geo
+
+import "strings"
+
+// max geohash length (about 3.7cm x 1.9cm cell)
+const MaxGeohashPrecision = 12
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// encode coordinates to geohash of precision characters (e.g. 9 is about 4.8m x 4.8m cell)
+// return empty string if coordinates are invalid
+func Geohash(lat float64, lng float64, precision int) string {
+	if !Valid(lat, lng) || precision <= 0 {
+		return ""
+	}
+	if precision > MaxGeohashPrecision {
+		precision = MaxGeohashPrecision
+	}
+
+	latRange := [2]float64{-90, 90}
+	lngRange := [2]float64{-180, 180}
+	var hash strings.Builder
+	// bits are interleaved starting from longitude, every 5 bits are encoded to a character
+	even := true
+	bit, ch := 0, 0
+	for hash.Len() < precision {
+		value, r := lat, &latRange
+		if even {
+			value, r = lng, &lngRange
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
@@ -2,8 +2,10 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
+	"github.com/awcjack/ETL-sample/config"
 	"github.com/awcjack/ETL-sample/transformation"
 	"github.com/awcjack/ETL-sample/utils"
 )
@@ -23,16 +25,16 @@ type randomDataAPIResponse struct {
 	// PhoneNumber string `json:"phone_number"`
 	// SocialInsuranceNumber string `json:"social_insurance_number"`
 	DateOfBirth string `json:"date_of_birth"`
-	// Employment randomDateAPIResponseEmployment
+	// Employment randomDataAPIResponseEmployment
 	Address randomDataAPIResponseAddress
 	// CreditCard   randomDataAPIResponseCreditCard `json:"credit_card"`
 	// Subscription randomDataAPIResponseSubscription
 }
 
-// type randomDateAPIResponseEmployment struct {
-// 	Title    string
-// 	KeySkill string `json:"key_skill"`
-// }
+type randomDataAPIResponseEmployment struct {
+	Title    string
+	KeySkill string `json:"key_skill"`
+}
 
 type randomDataAPIResponseAddress struct {
 	City          string
@@ -49,57 +51,63 @@ type randomDataAPIResponseCoordinates struct {
 	Lng float64
 }
 
-// type randomDataAPIResponseCreditCard struct {
-// 	CCNumber string `json:"cc_number"`
-// }
+type randomDataAPIResponseCreditCard struct {
+	CCNumber string `json:"cc_number"`
+}
 
-// type randomDataAPIResponseSubscription struct {
-// 	Plan          string
-// 	Status        string
-// 	PaymentMethod string `json:"payment_method"`
-// 	Term          string
-// }
+type randomDataAPIResponseSubscription struct {
+	Plan          string
+	Status        string
+	PaymentMethod string `json:"payment_method"`
+	Term          string
+}
 
 type RandomDataAPITransformer struct {
-	logger utils.Logger
+	dates *transformation.DateParser
 }
 
-func NewRandomDataAPITransformer(logger utils.Logger) *RandomDataAPITransformer {
+// date of birth is parsed by dates parser (Transformation.Dates config)
+func NewRandomDataAPITransformer(dates *transformation.DateParser) *RandomDataAPITransformer {
 	return &RandomDataAPITransformer{
-		logger: logger,
+		dates: dates,
 	}
 }
 
-// parse JSON from random data api
-func (r *RandomDataAPITransformer) Transform(rawData []byte) (transformation.TransformedData, error) {
-	r.logger.Debugf("random data API rawData", rawData)
-
+// parse JSON from random data api to users record
+func (r *RandomDataAPITransformer) Transform(logger utils.Logger, rawData []byte) (transformation.Record, error) {
 	var structedData *randomDataAPIResponse
 
 	err := json.Unmarshal(rawData, &structedData)
 	if err != nil {
-		return transformation.TransformedData{}, err
+		return transformation.Record{}, err
 	}
 
-	// parse data of birth from string to time
-	date, err := time.Parse(time.DateOnly, structedData.DateOfBirth)
+	// parse data of birth from string to time (any supported format)
+	date, err := dateOfBirth(r.dates, structedData.DateOfBirth)
 	if err != nil {
-		return transformation.TransformedData{}, err
+		return transformation.Record{}, err
 	}
 
-	return transformation.TransformedData{
-		FirstName:   structedData.FirstName,
-		LastName:    structedData.LastName,
-		DateOfBirth: date,
-		Address: transformation.StructuredAddress{
-			City:          structedData.Address.City,
-			StreetName:    structedData.Address.StreetName,
-			StreetAddress: structedData.Address.StreetAddress,
-			ZipCode:       structedData.Address.ZipCode,
-			State:         structedData.Address.State,
-			Country:       structedData.Address.Country,
-			Latitude:      structedData.Address.Coordinates.Lat,
-			Longitude:     structedData.Address.Coordinates.Lng,
-		},
-	}, nil
+	record := transformation.NewRecord(config.DefaultEntity)
+	record.Set("FirstName", structedData.FirstName)
+	record.Set("LastName", structedData.LastName)
+	record.Set("DateOfBirth", date)
+	record.Set("Address.City", structedData.Address.City)
+	record.Set("Address.StreetName", structedData.Address.StreetName)
+	record.Set("Address.StreetAddress", structedData.Address.StreetAddress)
+	record.Set("Address.ZipCode", structedData.Address.ZipCode)
+	record.Set("Address.State", structedData.Address.State)
+	record.Set("Address.Country", structedData.Address.Country)
+	record.Set("Address.Latitude", structedData.Address.Coordinates.Lat)
+	record.Set("Address.Longitude", structedData.Address.Coordinates.Lng)
+	logger.Debugf("transformed random data api user to %s record", config.DefaultEntity)
+	return record, nil
+}
+
+// parse date of birth by dates parser, missing date of birth is an error
+func dateOfBirth(dates *transformation.DateParser, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("date of birth is missing")
+	}
+	return dates.Parse(value)
 }
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// child entities of profile produced by random-data-api-v2 transformer
+const (
+	employmentEntity   = "employments"
+	subscriptionEntity = "subscriptions"
+)
+
+// prefix of credit card token to distinguish it from card number
+const tokenPrefix = "tok_"
+
+// JSON response format in random data api with every field except password
+type randomDataAPIV2Response struct {
+	Id                    int64
+	Uid                   string
+	FirstName             string `json:"first_name"`
+	LastName              string `json:"last_name"`
+	Username              string
+	Email                 string
+	Avatar                string
+	Gender                string
+	PhoneNumber           string `json:"phone_number"`
+	SocialInsuranceNumber string `json:"social_insurance_number"`
+	DateOfBirth           string `json:"date_of_birth"`
+	Employment            *randomDataAPIResponseEmployment
+	Address               randomDataAPIResponseAddress
+	CreditCard            randomDataAPIResponseCreditCard `json:"credit_card"`
+	Subscription          *randomDataAPIResponseSubscription
+}
+
+// transform random data api user to profile record with child employment and subscription records
+// credit card number is replaced by deterministic token, so same card has same token without keeping the number
+type RandomDataAPIV2Transformer struct {
+	tokenKey []byte
+	dates    *transformation.DateParser
+}
+
+// tokenKey is the HMAC-SHA256 key of credit card token (utils.LoadKey), date of birth is parsed by dates parser
+func NewRandomDataAPIV2Transformer(tokenKey []byte, dates *transformation.DateParser) *RandomDataAPIV2Transformer {
+	return &RandomDataAPIV2Transformer{
+		tokenKey: tokenKey,
+		dates:    dates,
+	}
+}
+
+// parse JSON from random data api to profile record followed by its child records
+// child records are joined to profile by Uid
+func (r *RandomDataAPIV2Transformer) Transform(logger utils.Logger, rawData []byte) ([]transformation.Record, error) {
+	var structedData *randomDataAPIV2Response
+
+	err := json.Unmarshal(rawData, &structedData)
+	if err != nil {
+		return nil, err
+	}
+	if structedData == nil || structedData.Uid == "" {
+		return nil, errors.New("uid is missing")
+	}
+
+	// parse data of birth from string to time (any supported format)
+	date, err := dateOfBirth(r.dates, structedData.DateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := transformation.NewRecord(config.ProfileEntity)
+	profile.Set("Uid", structedData.Uid)
+	profile.Set("Id", structedData.Id)
+	profile.Set("Username", structedData.Username)
+	profile.Set("Email", structedData.Email)
+	profile.Set("FirstName", structedData.FirstName)
+	profile.Set("LastName", structedData.LastName)
+	profile.Set("Gender", structedData.Gender)
+	profile.Set("PhoneNumber", structedData.PhoneNumber)
+	profile.Set("SocialInsuranceNumber", structedData.SocialInsuranceNumber)
+	profile.Set("Avatar", structedData.Avatar)
+	profile.Set("DateOfBirth", date)
+	profile.Set("Address.City", structedData.Address.City)
+	profile.Set("Address.StreetName", structedData.Address.StreetName)
+	profile.Set("Address.StreetAddress", structedData.Address.StreetAddress)
+	profile.Set("Address.ZipCode", structedData.Address.ZipCode)
+	profile.Set("Address.State", structedData.Address.State)
+	profile.Set("Address.Country", structedData.Address.Country)
+	profile.Set("Address.Latitude", structedData.Address.Coordinates.Lat)
+	profile.Set("Address.Longitude", structedData.Address.Coordinates.Lng)
+	if structedData.CreditCard.CCNumber != "" {
+		token, last4, err := r.tokenize(structedData.CreditCard.CCNumber)
+		if err != nil {
+			return nil, err
+		}
+		profile.Set("CreditCard.Token", token)
+		profile.Set("CreditCard.Last4", last4)
+	}
+	records := []transformation.Record{profile}
+
+	if structedData.Employment != nil {
+		employment := transformation.NewRecord(employmentEntity)
+		employment.Set("Uid", structedData.Uid)
+		employment.Set("Title", structedData.Employment.Title)
+		employment.Set("KeySkill", structedData.Employment.KeySkill)
+		records = append(records, employment)
+	}
+	if structedData.Subscription != nil {
+		subscription := transformation.NewRecord(subscriptionEntity)
+		subscription.Set("Uid", structedData.Uid)
+		subscription.Set("Plan", structedData.Subscription.Plan)
+		subscription.Set("Status", structedData.Subscription.Status)
+		subscription.Set("PaymentMethod", structedData.Subscription.PaymentMethod)
+		subscription.Set("Term", structedData.Subscription.Term)
+		records = append(records, subscription)
+	}
+
+	logger.Debugf("transformed profile %s with %d child records", structedData.Uid, len(records)-1)
+	return records, nil
+}
+
+// token (hex encoded HMAC-SHA256 of card digits with tok_ prefix) and last 4 digits of credit card number
+// separators are ignored, so 4403-8715-0240-9153 and 4403871502409153 have same token
+func (r *RandomDataAPIV2Transformer) tokenize(number string) (string, string, error) {
+	if len(r.tokenKey) == 0 {
+		return "", "", errors.New("credit card token key is missing")
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, number)
+	if len(digits) < 4 {
+		return "", "", errors.New("credit card number is invalid")
+	}
+
+	mac := hmac.New(sha256.New, r.tokenKey)
+	mac.Write([]byte(digits))
+	return tokenPrefix + hex.EncodeToString(mac.Sum(nil)), digits[len(digits)-4:], nil
+}
@@ -0,0 +1,156 @@
+package http_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/http"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const randomDataAPIV2User = "{\"id\":596,\"uid\":\"96bedfef-4de2-4b5f-8cb0-adafc1b8fdce\",\"password\":\"IfuewXQ4P0\",\"first_name\":\"Chasidy\",\"last_name\":\"Kirlin\",\"username\":\"chasidy.kirlin\",\"email\":\"chasidy.kirlin@email.com\",\"avatar\":\"https://robohash.org/autodiodolorem.png?size=300x300&set=set1\",\"gender\":\"Polygender\",\"phone_number\":\"+269 460.093.9024\",\"social_insurance_number\":\"357134402\",\"date_of_birth\":\"1981-08-30\",\"employment\":{\"title\":\"Administration Assistant\",\"key_skill\":\"Problem solving\"},\"address\":{\"city\":\"Marionland\",\"street_name\":\"Domingo Green\",\"street_address\":\"82204 Wisoky Canyon\",\"zip_code\":\"43072-8812\",\"state\":\"Washington\",\"country\":\"United States\",\"coordinates\":{\"lat\":-50.65341353032217,\"lng\":-93.89954802799431}},\"credit_card\":{\"cc_number\":\"4403-8715-0240-9153\"},\"subscription\":{\"plan\":\"Silver\",\"status\":\"Active\",\"payment_method\":\"Visa checkout\",\"term\":\"Annual\"}}"
+
+func TestTransformV2(t *testing.T) {
+	dep := newRandomDataAPIV2TransformerDependencies()
+
+	type testcase struct {
+		testcase         string
+		rawData          []byte
+		expectedEntities []string
+		expectedError    bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase:         "Normal",
+			rawData:          []byte(randomDataAPIV2User),
+			expectedEntities: []string{"profiles", "employments", "subscriptions"},
+		},
+		{
+			testcase:         "Missing employment and subscription",
+			rawData:          []byte("{\"uid\":\"96bedfef-4de2-4b5f-8cb0-adafc1b8fdce\",\"date_of_birth\":\"1981-08-30\"}"),
+			expectedEntities: []string{"profiles"},
+		},
+		{
+			testcase:      "Missing uid",
+			rawData:       []byte("{\"date_of_birth\":\"1981-08-30\"}"),
+			expectedError: true,
+		},
+		{
+			testcase:      "Invalid credit card number",
+			rawData:       []byte("{\"uid\":\"96bedfef-4de2-4b5f-8cb0-adafc1b8fdce\",\"date_of_birth\":\"1981-08-30\",\"credit_card\":{\"cc_number\":\"12\"}}"),
+			expectedError: true,
+		},
+		{
+			testcase:      "Empty data",
+			rawData:       nil,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			records, err := dep.randomDataAPIV2TransformerHandler.Transform(dep.logger, tc.rawData)
+			if tc.expectedError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tc.expectedError && err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+
+			entities := make([]string, 0, len(records))
+			for _, record := range records {
+				entities = append(entities, record.Entity)
+				if err := dep.schemas.Conform(&record); err != nil {
+					t.Errorf("not expected error, but got %v", err)
+				}
+			}
+			if len(entities) == 0 {
+				entities = nil
+			}
+			if !reflect.DeepEqual(entities, tc.expectedEntities) {
+				t.Errorf("expected %v, but got %v", tc.expectedEntities, entities)
+			}
+		})
+	}
+}
+
+func TestTransformV2Fields(t *testing.T) {
+	dep := newRandomDataAPIV2TransformerDependencies()
+
+	records, err := dep.randomDataAPIV2TransformerHandler.Transform(dep.logger, []byte(randomDataAPIV2User))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected %v, but got %v", 3, len(records))
+	}
+
+	profile := records[0]
+	dob, _ := time.Parse(time.DateOnly, "1981-08-30")
+	if profile.Time("DateOfBirth") != dob || profile.Int("Id") != 596 || profile.String("Email") != "chasidy.kirlin@email.com" {
+		t.Errorf("expected profile of Chasidy, but got %v", profile.Fields)
+	}
+	if profile.Has("Password") || strings.Contains(profile.String("CreditCard.Token"), "4403") {
+		t.Errorf("expected password and credit card number not kept, but got %v", profile.Fields)
+	}
+	if !strings.HasPrefix(profile.String("CreditCard.Token"), "tok_") || profile.String("CreditCard.Last4") != "9153" {
+		t.Errorf("expected token and last 4 digits, but got %v and %v", profile.String("CreditCard.Token"), profile.String("CreditCard.Last4"))
+	}
+
+	expectedSubscription := transformation.Record{
+		Entity: "subscriptions",
+		Fields: map[string]interface{}{
+			"Uid":           "96bedfef-4de2-4b5f-8cb0-adafc1b8fdce",
+			"Plan":          "Silver",
+			"Status":        "Active",
+			"PaymentMethod": "Visa checkout",
+			"Term":          "Annual",
+		},
+	}
+	if !reflect.DeepEqual(records[2], expectedSubscription) {
+		t.Errorf("expected %v, but got %v", expectedSubscription, records[2])
+	}
+
+	// same card number with different separators has same token
+	other, err := dep.randomDataAPIV2TransformerHandler.Transform(dep.logger, []byte(strings.Replace(randomDataAPIV2User, "4403-8715-0240-9153", "4403 8715 0240 9153", 1)))
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if other[0].String("CreditCard.Token") != profile.String("CreditCard.Token") {
+		t.Errorf("expected %v, but got %v", profile.String("CreditCard.Token"), other[0].String("CreditCard.Token"))
+	}
+}
+
+func TestTransformV2MissingTokenKey(t *testing.T) {
+	transformer := http.NewRandomDataAPIV2Transformer(nil, transformation.DefaultDateParser)
+	if _, err := transformer.Transform(utils.NewLogrusLogger(logrus.StandardLogger()), []byte(randomDataAPIV2User)); err == nil {
+		t.Errorf("expected error but got nil")
+	}
+}
+
+type randomDataAPIV2TransformerDependencies struct {
+	randomDataAPIV2TransformerHandler *http.RandomDataAPIV2Transformer
+	schemas                           transformation.Schemas
+	logger                            utils.Logger
+}
+
+func newRandomDataAPIV2TransformerDependencies() randomDataAPIV2TransformerDependencies {
+	logger := utils.NewLogrusLogger(logrus.StandardLogger())
+	randomDataAPIV2TransformerHandler := http.NewRandomDataAPIV2Transformer([]byte("01234567890123456789012345678901"), transformation.DefaultDateParser)
+	schemas, err := transformation.NewSchemas(config.ProfileEntities())
+	if err != nil {
+		panic(err)
+	}
+
+	return randomDataAPIV2TransformerDependencies{
+		randomDataAPIV2TransformerHandler: randomDataAPIV2TransformerHandler,
+		schemas:                           schemas,
+		logger:                            logger,
+	}
+}
@@ -1,11 +1,13 @@
 package http_test
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/awcjack/ETL-sample/transformation"
 	"github.com/awcjack/ETL-sample/transformation/http"
+	"github.com/awcjack/ETL-sample/utils"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,7 +17,7 @@ func TestTransform(t *testing.T) {
 	type testcase struct {
 		testcase       string
 		rawData        []byte
-		expectedResult transformation.TransformedData
+		expectedResult transformation.Record
 		expectedError  bool
 		isPanic        bool
 	}
@@ -26,19 +28,20 @@ func TestTransform(t *testing.T) {
 		{
 			testcase: "Normal",
 			rawData:  []byte("{\"id\":596,\"uid\":\"96bedfef-4de2-4b5f-8cb0-adafc1b8fdce\",\"password\":\"IfuewXQ4P0\",\"first_name\":\"Chasidy\",\"last_name\":\"Kirlin\",\"username\":\"chasidy.kirlin\",\"email\":\"chasidy.kirlin@email.com\",\"avatar\":\"https://robohash.org/autodiodolorem.png?size=300x300\u0026set=set1\",\"gender\":\"Polygender\",\"phone_number\":\"+269 460.093.9024\",\"social_insurance_number\":\"357134402\",\"date_of_birth\":\"1981-08-30\",\"employment\":{\"title\":\"Administration Assistant\",\"key_skill\":\"Problem solving\"},\"address\":{\"city\":\"Marionland\",\"street_name\":\"Domingo Green\",\"street_address\":\"82204 Wisoky Canyon\",\"zip_code\":\"43072-8812\",\"state\":\"Washington\",\"country\":\"United States\",\"coordinates\":{\"lat\":-50.65341353032217,\"lng\":-93.89954802799431}},\"credit_card\":{\"cc_number\":\"4403-8715-0240-9153\"},\"subscription\":{\"plan\":\"Silver\",\"status\":\"Active\",\"payment_method\":\"Visa checkout\",\"term\":\"Annual\"}}"),
-			expectedResult: transformation.TransformedData{
-				FirstName:   "Chasidy",
-				LastName:    "Kirlin",
-				DateOfBirth: dob,
-				Address: transformation.StructuredAddress{
-					City:          "Marionland",
-					StreetName:    "Domingo Green",
-					StreetAddress: "82204 Wisoky Canyon",
-					ZipCode:       "43072-8812",
-					State:         "Washington",
-					Country:       "United States",
-					Latitude:      -50.65341353032217,
-					Longitude:     -93.89954802799431,
+			expectedResult: transformation.Record{
+				Entity: "users",
+				Fields: map[string]interface{}{
+					"FirstName":             "Chasidy",
+					"LastName":              "Kirlin",
+					"DateOfBirth":           dob,
+					"Address.City":          "Marionland",
+					"Address.StreetName":    "Domingo Green",
+					"Address.StreetAddress": "82204 Wisoky Canyon",
+					"Address.ZipCode":       "43072-8812",
+					"Address.State":         "Washington",
+					"Address.Country":       "United States",
+					"Address.Latitude":      -50.65341353032217,
+					"Address.Longitude":     -93.89954802799431,
 				},
 			},
 			expectedError: false,
@@ -47,42 +50,72 @@ func TestTransform(t *testing.T) {
 		{
 			testcase: "Missing field",
 			rawData:  []byte("{\"id\":596,\"uid\":\"96bedfef-4de2-4b5f-8cb0-adafc1b8fdce\",\"password\":\"IfuewXQ4P0\",\"last_name\":\"Kirlin\",\"username\":\"chasidy.kirlin\",\"email\":\"chasidy.kirlin@email.com\",\"avatar\":\"https://robohash.org/autodiodolorem.png?size=300x300\u0026set=set1\",\"gender\":\"Polygender\",\"phone_number\":\"+269 460.093.9024\",\"social_insurance_number\":\"357134402\",\"date_of_birth\":\"1981-08-30\",\"employment\":{\"title\":\"Administration Assistant\",\"key_skill\":\"Problem solving\"},\"address\":{\"city\":\"Marionland\",\"street_name\":\"Domingo Green\",\"street_address\":\"82204 Wisoky Canyon\",\"zip_code\":\"43072-8812\",\"state\":\"Washington\",\"country\":\"United States\",\"coordinates\":{\"lat\":-50.65341353032217,\"lng\":-93.89954802799431}},\"credit_card\":{\"cc_number\":\"4403-8715-0240-9153\"},\"subscription\":{\"plan\":\"Silver\",\"status\":\"Active\",\"payment_method\":\"Visa checkout\",\"term\":\"Annual\"}}"),
-			expectedResult: transformation.TransformedData{
-				FirstName:   "",
-				LastName:    "Kirlin",
-				DateOfBirth: dob,
-				Address: transformation.StructuredAddress{
-					City:          "Marionland",
-					StreetName:    "Domingo Green",
-					StreetAddress: "82204 Wisoky Canyon",
-					ZipCode:       "43072-8812",
-					State:         "Washington",
-					Country:       "United States",
-					Latitude:      -50.65341353032217,
-					Longitude:     -93.89954802799431,
+			expectedResult: transformation.Record{
+				Entity: "users",
+				Fields: map[string]interface{}{
+					"FirstName":             "",
+					"LastName":              "Kirlin",
+					"DateOfBirth":           dob,
+					"Address.City":          "Marionland",
+					"Address.StreetName":    "Domingo Green",
+					"Address.StreetAddress": "82204 Wisoky Canyon",
+					"Address.ZipCode":       "43072-8812",
+					"Address.State":         "Washington",
+					"Address.Country":       "United States",
+					"Address.Latitude":      -50.65341353032217,
+					"Address.Longitude":     -93.89954802799431,
 				},
 			},
 			expectedError: false,
 			isPanic:       false,
 		},
+		{
+			testcase: "Day first date of birth",
+			rawData:  []byte("{\"first_name\":\"Chasidy\",\"last_name\":\"Kirlin\",\"date_of_birth\":\"30/08/1981\"}"),
+			expectedResult: transformation.Record{
+				Entity: "users",
+				Fields: map[string]interface{}{
+					"FirstName":             "Chasidy",
+					"LastName":              "Kirlin",
+					"DateOfBirth":           dob,
+					"Address.City":          "",
+					"Address.StreetName":    "",
+					"Address.StreetAddress": "",
+					"Address.ZipCode":       "",
+					"Address.State":         "",
+					"Address.Country":       "",
+					"Address.Latitude":      float64(0),
+					"Address.Longitude":     float64(0),
+				},
+			},
+			expectedError: false,
+			isPanic:       false,
+		},
+		{
+			testcase:       "Invalid date of birth field",
+			rawData:        []byte("{\"first_name\":\"Chasidy\",\"date_of_birth\":\"yesterday\"}"),
+			expectedResult: transformation.Record{},
+			expectedError:  true,
+			isPanic:        false,
+		},
 		{
 			testcase:       "Missing date of birth field",
 			rawData:        []byte("{\"id\":596,\"uid\":\"96bedfef-4de2-4b5f-8cb0-adafc1b8fdce\",\"password\":\"IfuewXQ4P0\",\"first_name\":\"Chasidy\",\"last_name\":\"Kirlin\",\"username\":\"chasidy.kirlin\",\"email\":\"chasidy.kirlin@email.com\",\"avatar\":\"https://robohash.org/autodiodolorem.png?size=300x300\u0026set=set1\",\"gender\":\"Polygender\",\"phone_number\":\"+269 460.093.9024\",\"social_insurance_number\":\"357134402\",\"employment\":{\"title\":\"Administration Assistant\",\"key_skill\":\"Problem solving\"},\"address\":{\"city\":\"Marionland\",\"street_name\":\"Domingo Green\",\"street_address\":\"82204 Wisoky Canyon\",\"zip_code\":\"43072-8812\",\"state\":\"Washington\",\"country\":\"United States\",\"coordinates\":{\"lat\":-50.65341353032217,\"lng\":-93.89954802799431}},\"credit_card\":{\"cc_number\":\"4403-8715-0240-9153\"},\"subscription\":{\"plan\":\"Silver\",\"status\":\"Active\",\"payment_method\":\"Visa checkout\",\"term\":\"Annual\"}}"),
-			expectedResult: transformation.TransformedData{},
+			expectedResult: transformation.Record{},
 			expectedError:  true,
 			isPanic:        false,
 		},
 		{
 			testcase:       "Empty data",
 			rawData:        []byte("{}"),
-			expectedResult: transformation.TransformedData{},
+			expectedResult: transformation.Record{},
 			expectedError:  true,
 			isPanic:        false,
 		},
 		{
 			testcase:       "Empty data",
 			rawData:        nil,
-			expectedResult: transformation.TransformedData{},
+			expectedResult: transformation.Record{},
 			expectedError:  true,
 			isPanic:        false,
 		},
@@ -90,7 +123,7 @@ func TestTransform(t *testing.T) {
 
 	for _, tc := range testcases {
 		t.Run(tc.testcase, func(t *testing.T) {
-			v, err := dep.randomDataAPITransformerHandler.Transform(tc.rawData)
+			v, err := dep.randomDataAPITransformerHandler.Transform(dep.logger, tc.rawData)
 			isPanic := false
 			defer func() {
 				if err := recover(); err != nil {
@@ -107,7 +140,7 @@ func TestTransform(t *testing.T) {
 				t.Errorf("not expected error, but got %v", err)
 			}
 
-			if v != tc.expectedResult {
+			if !reflect.DeepEqual(v, tc.expectedResult) {
 				t.Errorf("expected %v, but got %v", tc.expectedResult, v)
 			}
 		})
@@ -116,13 +149,15 @@ func TestTransform(t *testing.T) {
 
 type randomDataAPITransformerDependencies struct {
 	randomDataAPITransformerHandler *http.RandomDataAPITransformer
+	logger                          utils.Logger
 }
 
 func newRandomDataAPITransformerDependencies() randomDataAPITransformerDependencies {
-	logger := logrus.NewEntry(logrus.StandardLogger())
-	randomDataAPITransformerHandler := http.NewRandomDataAPITransformer(logger)
+	logger := utils.NewLogrusLogger(logrus.StandardLogger())
+	randomDataAPITransformerHandler := http.NewRandomDataAPITransformer(transformation.DefaultDateParser)
 
 	return randomDataAPITransformerDependencies{
 		randomDataAPITransformerHandler: randomDataAPITransformerHandler,
+		logger:                          logger,
 	}
 }
@@ -0,0 +1,178 @@
+package iso3166
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ISO 3166-1 countries and ISO 3166-2 subdivisions generated from Debian iso-codes 4.15.0
+// aliases are separated by |
+//
+//go:embed data/countries.csv data/subdivisions.csv
+var data embed.FS
+
+// ISO 3166-1 country
+type Country struct {
+	Alpha2  string
+	Alpha3  string
+	Numeric string
+	Name    string
+}
+
+// ISO 3166-2 subdivision (state, province, region etc) of country
+type Subdivision struct {
+	// code with country prefix (e.g. US-TX)
+	Code string
+	Name string
+}
+
+type dataset struct {
+	// country by normalized name, alias, alpha-2, alpha-3 and numeric code
+	countries map[string]*Country
+	// subdivisions by alpha-2 country code, then by normalized name, alias, code and code without country prefix
+	subdivisions map[string]map[string]*Subdivision
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *dataset
+)
+
+// resolve country name, alias or code (case, accent and punctuation insensitive)
+func LookupCountry(value string) (Country, bool) {
+	country, ok := load().countries[normalize(value)]
+	if !ok || country == nil {
+		return Country{}, false
+	}
+	return *country, true
+}
+
+// resolve subdivision name, alias or code of country (alpha-2)
+// country can be empty if value is full subdivision code (e.g. US-TX)
+func LookupSubdivision(countryCode string, value string) (Subdivision, bool) {
+	d := load()
+	if countryCode == "" {
+		prefix, _, ok := strings.Cut(strings.TrimSpace(value), "-")
+		if !ok {
+			return Subdivision{}, false
+		}
+		countryCode = prefix
+	}
+
+	subdivision, ok := d.subdivisions[strings.ToUpper(countryCode)][normalize(value)]
+	if !ok || subdivision == nil {
+		return Subdivision{}, false
+	}
+	return *subdivision, true
+}
+
+// embedded dataset is parsed on first lookup
+func load() *dataset {
+	loadOnce.Do(func() {
+		d, err := parse()
+		if err != nil {
+			panic(fmt.Sprintf("invalid embedded ISO 3166 dataset: %v", err))
+		}
+		loaded = d
+	})
+	return loaded
+}
+
+func parse() (*dataset, error) {
+	d := &dataset{
+		countries:    make(map[string]*Country),
+		subdivisions: make(map[string]map[string]*Subdivision),
+	}
+
+	countries, err := readCSV("data/countries.csv")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range countries {
+		country := &Country{Alpha2: row[0], Alpha3: row[1], Numeric: row[2], Name: row[3]}
+		for _, key := range append([]string{row[0], row[1], row[2], row[3]}, aliases(row[4])...) {
+			add(d.countries, normalize(key), country)
+		}
+	}
+
+	subdivisions, err := readCSV("data/subdivisions.csv")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range subdivisions {
+		countryCode, code, ok := strings.Cut(row[0], "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid subdivision code %s", row[0])
+		}
+		if d.subdivisions[countryCode] == nil {
+			d.subdivisions[countryCode] = make(map[string]*Subdivision)
+		}
+		subdivision := &Subdivision{Code: row[0], Name: row[1]}
+		for _, key := range append([]string{row[0], code, row[1]}, aliases(row[2])...) {
+			add(d.subdivisions[countryCode], normalize(key), subdivision)
+		}
+	}
+
+	return d, nil
+}
+
+// key shared by different entries is ambiguous and kept as nil so it is never resolved
+func add[T comparable](m map[string]*T, key string, value *T) {
+	if existing, ok := m[key]; ok && existing != value {
+		m[key] = nil
+		return
+	}
+	m[key] = value
+}
+
+// rows without header
+func readCSV(name string) ([][]string, error) {
+	f, err := data.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", name)
+	}
+	return rows[1:], nil
+}
+
+func aliases(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "|")
+}
+
+// latin letters with diacritic and their base letters
+var foldReplacer = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "ā", "a", "ă", "a", "ą", "a",
+	"æ", "ae", "ç", "c", "ć", "c", "č", "c", "ď", "d", "đ", "d", "ð", "d",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e", "ę", "e", "ě", "e",
+	"ğ", "g", "ģ", "g", "ì", "i", "í", "i", "î", "i", "ï", "i", "ī", "i", "į", "i", "ı", "i",
+	"ķ", "k", "ĺ", "l", "ļ", "l", "ľ", "l", "ł", "l", "ñ", "n", "ń", "n", "ņ", "n", "ň", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "ō", "o", "ő", "o", "œ", "oe",
+	"ŕ", "r", "ř", "r", "ś", "s", "ş", "s", "š", "s", "ș", "s", "ß", "ss",
+	"ţ", "t", "ť", "t", "ț", "t", "þ", "th", "ù", "u", "ú", "u", "û", "u", "ü", "u", "ū", "u", "ů", "u", "ű", "u", "ų", "u",
+	"ý", "y", "ÿ", "y", "ź", "z", "ż", "z", "ž", "z",
+	"&", " and ", "'", "", "’", "",
+)
+
+// lowercase, remove diacritics and punctuation and collapse spaces (e.g. "Côte d'Ivoire" -> "cote divoire")
+func normalize(value string) string {
+	value = foldReplacer.Replace(strings.ToLower(value))
+	value = strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+	return strings.TrimPrefix(value, "the ")
+}
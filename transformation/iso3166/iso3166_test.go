@@ -0,0 +1,76 @@
+package iso3166_test
+
+import (
+	"testing"
+
+	"github.com/awcjack/ETL-sample/transformation/iso3166"
+)
+
+func TestLookupCountry(t *testing.T) {
+	type testcase struct {
+		testcase   string
+		value      string
+		expected   string
+		expectedOk bool
+	}
+
+	testcases := []testcase{
+		{testcase: "Name", value: "United States", expected: "US", expectedOk: true},
+		{testcase: "Official name", value: "United States of America", expected: "US", expectedOk: true},
+		{testcase: "Alias", value: "UK", expected: "GB", expectedOk: true},
+		{testcase: "Common name", value: "South Korea", expected: "KR", expectedOk: true},
+		{testcase: "Alpha-2 code", value: "de", expected: "DE", expectedOk: true},
+		{testcase: "Alpha-3 code", value: "FRA", expected: "FR", expectedOk: true},
+		{testcase: "Numeric code", value: "392", expected: "JP", expectedOk: true},
+		{testcase: "Case, accent and punctuation insensitive", value: "  cote D'IVOIRE ", expected: "CI", expectedOk: true},
+		{testcase: "Leading article", value: "The Bahamas", expected: "BS", expectedOk: true},
+		{testcase: "Unknown country", value: "Atlantis", expectedOk: false},
+		{testcase: "Empty", value: "", expectedOk: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			country, ok := iso3166.LookupCountry(tc.value)
+			if ok != tc.expectedOk {
+				t.Fatalf("expected %v, but got %v", tc.expectedOk, ok)
+			}
+			if country.Alpha2 != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, country.Alpha2)
+			}
+		})
+	}
+}
+
+func TestLookupSubdivision(t *testing.T) {
+	type testcase struct {
+		testcase   string
+		country    string
+		value      string
+		expected   string
+		expectedOk bool
+	}
+
+	testcases := []testcase{
+		{testcase: "Name", country: "US", value: "Texas", expected: "US-TX", expectedOk: true},
+		{testcase: "Code without country", country: "US", value: "ny", expected: "US-NY", expectedOk: true},
+		{testcase: "Full code", country: "US", value: "US-CA", expected: "US-CA", expectedOk: true},
+		{testcase: "Full code without country", country: "", value: "CA-QC", expected: "CA-QC", expectedOk: true},
+		{testcase: "Alias", country: "US", value: "Washington D.C.", expected: "US-DC", expectedOk: true},
+		{testcase: "Accent insensitive", country: "FR", value: "Ile-de-France", expected: "FR-IDF", expectedOk: true},
+		{testcase: "Subdivision of other country", country: "CA", value: "Texas", expectedOk: false},
+		{testcase: "Ambiguous name", country: "FR", value: "Martinique", expectedOk: false},
+		{testcase: "Name without country", country: "", value: "Texas", expectedOk: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			subdivision, ok := iso3166.LookupSubdivision(tc.country, tc.value)
+			if ok != tc.expectedOk {
+				t.Fatalf("expected %v, but got %v", tc.expectedOk, ok)
+			}
+			if subdivision.Code != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, subdivision.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package transformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+)
+
+// type of field value
+type FieldType string
+
+const (
+	// string
+	FieldString FieldType = "string"
+	// float64
+	FieldFloat FieldType = "float"
+	// int64
+	FieldInt FieldType = "int"
+	// bool
+	FieldBool FieldType = "bool"
+	// time.Time
+	FieldDate FieldType = "date"
+)
+
+// zero value of field type
+func (t FieldType) Zero() interface{} {
+	switch t {
+	case FieldFloat:
+		return float64(0)
+	case FieldInt:
+		return int64(0)
+	case FieldBool:
+		return false
+	case FieldDate:
+		return time.Time{}
+	}
+	return ""
+}
+
+// field of entity
+type Field struct {
+	Name string
+	Type FieldType
+	// date field keeps calendar date only (stored in DATE column)
+	DateOnly bool
+	// column of entity table
+	Column string
+}
+
+// fields of entity
+type Schema struct {
+	Entity string
+	fields []Field
+	// field index by lowercase field name
+	index map[string]int
+	// field index by lowercase column name
+	columns map[string]int
+	// parser of date field values
+	dates *DateParser
+}
+
+// create schema from entity config, date field values are parsed by dates parser
+func NewSchema(c config.EntityConfig, dates *DateParser) (*Schema, error) {
+	s := &Schema{
+		Entity:  c.Name,
+		fields:  make([]Field, 0, len(c.Fields)),
+		index:   make(map[string]int, len(c.Fields)),
+		columns: make(map[string]int, len(c.Fields)),
+		dates:   dates,
+	}
+	for _, field := range c.Fields {
+		fieldType := FieldType(field.Type)
+		switch fieldType {
+		case FieldString, FieldFloat, FieldInt, FieldBool, FieldDate:
+		default:
+			return nil, fmt.Errorf("field %s of entity %s has unsupported type %s", field.Name, c.Name, field.Type)
+		}
+		if _, ok := s.index[strings.ToLower(field.Name)]; ok {
+			return nil, fmt.Errorf("field %s of entity %s is duplicated", field.Name, c.Name)
+		}
+		s.index[strings.ToLower(field.Name)] = len(s.fields)
+		s.columns[strings.ToLower(field.Column)] = len(s.fields)
+		if field.DateOnly && fieldType != FieldDate {
+			return nil, fmt.Errorf("field %s of entity %s is DateOnly but not date", field.Name, c.Name)
+		}
+		s.fields = append(s.fields, Field{Name: field.Name, Type: fieldType, DateOnly: field.DateOnly, Column: field.Column})
+	}
+
+	return s, nil
+}
+
+// fields in config order
+func (s *Schema) Fields() []Field {
+	return append([]Field(nil), s.fields...)
+}
+
+// resolve field by name case insensitively (e.g. address.latitude -> Address.Latitude)
+func (s *Schema) Field(name string) (Field, bool) {
+	i, ok := s.index[strings.ToLower(name)]
+	if !ok {
+		return Field{}, false
+	}
+	return s.fields[i], true
+}
+
+// convert field values of record to field types and set missing fields to nil (NULL in sinks)
+// field names are resolved case insensitively, then as column names (e.g. row of change data capture), unknown field is an error to report typo in transformer
+func (s *Schema) Conform(record *Record) error {
+	fields := make(map[string]interface{}, len(s.fields))
+	for name, value := range record.Fields {
+		field, ok := s.Field(name)
+		if !ok {
+			if i, isColumn := s.columns[strings.ToLower(name)]; isColumn {
+				field, ok = s.fields[i], true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("field %s not found in entity %s", name, s.Entity)
+		}
+		converted, err := s.convert(field, value)
+		if err != nil {
+			return fmt.Errorf("field %s %w", field.Name, err)
+		}
+		fields[field.Name] = converted
+	}
+	for _, field := range s.fields {
+		if _, ok := fields[field.Name]; !ok {
+			fields[field.Name] = nil
+		}
+	}
+
+	record.Entity = s.Entity
+	record.Fields = fields
+	return nil
+}
+
+// convert value to field type, date is parsed by date parser of schema and truncated to calendar date if DateOnly
+func (s *Schema) convert(field Field, value interface{}) (interface{}, error) {
+	if value == nil || field.Type != FieldDate {
+		return Convert(field.Type, value)
+	}
+	t, err := s.dates.ParseValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if field.DateOnly {
+		t = s.dates.Date(t)
+	}
+	return t, nil
+}
+
+// convert value to field type, nil is kept as nil
+// number is converted to int only if it has no fraction, date is parsed by DefaultDateParser (e.g. 2006-01-02, RFC3339 time or epoch)
+func Convert(fieldType FieldType, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case FieldString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case FieldFloat:
+		if f, ok := toFloat(value); ok {
+			return f, nil
+		}
+	case FieldInt:
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+		}
+		if f, ok := toFloat(value); ok {
+			if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+				return nil, fmt.Errorf("value %v is not an int", value)
+			}
+			return int64(f), nil
+		}
+	case FieldBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case FieldDate:
+		return DefaultDateParser.ParseValue(value)
+	}
+
+	return nil, fmt.Errorf("value %v is %T but not %s", value, value, fieldType)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanFloat():
+		return rv.Float(), true
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// parse date by DefaultDateParser (e.g. 2006-01-02, RFC3339 time or epoch), empty string is zero time
+func ParseDate(value string) (time.Time, error) {
+	return DefaultDateParser.Parse(value)
+}
+
+// format date as date (2006-01-02) if it has no time of day, otherwise RFC3339 time
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Equal(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())) && t.Location() == time.UTC {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// value is zero value of its type (nil, empty string, 0, false or zero time)
+func IsZero(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	return reflect.ValueOf(value).IsZero()
+}
+
+// schemas of all entities keyed by entity name
+type Schemas map[string]*Schema
+
+// create schemas of entities from config, date field values are parsed by DefaultDateParser
+func NewSchemas(entities []config.EntityConfig) (Schemas, error) {
+	return NewSchemasWithDates(entities, DefaultDateParser)
+}
+
+// create schemas of entities from config, date field values are parsed by dates parser (Transformation.Dates config)
+func NewSchemasWithDates(entities []config.EntityConfig, dates *DateParser) (Schemas, error) {
+	schemas := make(Schemas, len(entities))
+	for _, entity := range entities {
+		schema, err := NewSchema(entity, dates)
+		if err != nil {
+			return nil, err
+		}
+		schemas[entity.Name] = schema
+	}
+	return schemas, nil
+}
+
+// conform record to schema of its entity
+func (s Schemas) Conform(record *Record) error {
+	schema, ok := s[record.Entity]
+	if !ok {
+		return fmt.Errorf("entity %s not found", record.Entity)
+	}
+	return schema.Conform(record)
+}
+
+// resolve field of any entity by name case insensitively (e.g. Address.Latitude)
+// field with same name must have same type in every entity, so it can be handled by steps and rules of any entity
+func (s Schemas) Field(name string) (Field, error) {
+	var found *Field
+	for _, entity := range s.entities() {
+		field, ok := s[entity].Field(name)
+		if !ok {
+			continue
+		}
+		if found != nil && (found.Type != field.Type || found.Name != field.Name) {
+			return Field{}, fmt.Errorf("field %s has different name or type in entities", name)
+		}
+		found = &field
+	}
+	if found == nil {
+		return Field{}, fmt.Errorf("field %s not found", name)
+	}
+	return *found, nil
+}
+
+// names of fields of type in any entity sorted by name
+func (s Schemas) FieldsOfType(fieldType FieldType) []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, schema := range s {
+		for _, field := range schema.fields {
+			if field.Type == fieldType && !seen[field.Name] {
+				seen[field.Name] = true
+				names = append(names, field.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// entity names sorted for deterministic field resolution
+func (s Schemas) entities() []string {
+	entities := make([]string, 0, len(s))
+	for entity := range s {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	return entities
+}
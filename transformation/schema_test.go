@@ -0,0 +1,92 @@
+package transformation_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+func TestConform(t *testing.T) {
+	type testcase struct {
+		testcase       string
+		fields         map[string]interface{}
+		expectedFields map[string]interface{}
+		expectedError  bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Field names",
+			fields:   map[string]interface{}{"orderid": json.Number("1"), "Customer.Email": "a@example.com"},
+			expectedFields: map[string]interface{}{
+				"OrderId":        int64(1),
+				"Customer.Email": "a@example.com",
+				"PlacedAt":       nil,
+			},
+		},
+		{
+			testcase: "Null fields",
+			fields:   map[string]interface{}{"order_id": json.Number("1"), "email": nil, "placed_at": nil},
+			expectedFields: map[string]interface{}{
+				"OrderId":        int64(1),
+				"Customer.Email": nil,
+				"PlacedAt":       nil,
+			},
+		},
+		{
+			testcase: "Column names",
+			fields:   map[string]interface{}{"order_id": json.Number("1"), "email": "a@example.com", "placed_at": "2024-01-02T03:04:05Z"},
+			expectedFields: map[string]interface{}{
+				"OrderId":        int64(1),
+				"Customer.Email": "a@example.com",
+				"PlacedAt":       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		},
+		{
+			testcase:      "Unknown field",
+			fields:        map[string]interface{}{"total": json.Number("1")},
+			expectedError: true,
+		},
+		{
+			testcase:      "Wrong type",
+			fields:        map[string]interface{}{"order_id": "1"},
+			expectedError: true,
+		},
+	}
+
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{{
+		Name:  "orders",
+		Table: "orders",
+		Fields: []config.EntityFieldConfig{
+			{Name: "OrderId", Type: "int", Column: "order_id"},
+			{Name: "Customer.Email", Type: "string", Column: "email"},
+			{Name: "PlacedAt", Type: "date", Column: "placed_at"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			record := transformation.Record{Entity: "orders", Fields: tc.fields}
+			err := schemas.Conform(&record)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if !reflect.DeepEqual(record.Fields, tc.expectedFields) {
+				t.Errorf("expected %v, but got %v", tc.expectedFields, record.Fields)
+			}
+		})
+	}
+}
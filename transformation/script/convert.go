@@ -0,0 +1,128 @@
+package script
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"go.starlark.net/starlark"
+)
+
+// convert script result (record, list of records or None) to records
+// record is dict of field values, nested field is nested dict (e.g. {"Address": {"City": "Boston"}})
+// field values are conformed to entity schema after transformation, date is date (2006-01-02) or RFC3339 time string
+func toRecords(value starlark.Value) ([]transformation.Record, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *starlark.Dict:
+		record, err := toRecord(v)
+		if err != nil {
+			return nil, err
+		}
+		return []transformation.Record{record}, nil
+	case *starlark.List:
+		records := make([]transformation.Record, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			dict, ok := v.Index(i).(*starlark.Dict)
+			if !ok {
+				return nil, fmt.Errorf("record %d is %s but not dict", i, v.Index(i).Type())
+			}
+			record, err := toRecord(dict)
+			if err != nil {
+				return nil, fmt.Errorf("record %d %w", i, err)
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	return nil, fmt.Errorf("expected dict, list of dict or None but got %s", value.Type())
+}
+
+func toRecord(dict *starlark.Dict) (transformation.Record, error) {
+	value, err := toGo(dict)
+	if err != nil {
+		return transformation.Record{}, err
+	}
+
+	return transformation.Record{Fields: transformation.Flatten(value.(map[string]interface{}))}, nil
+}
+
+// convert record to dict passed to script, date is formatted as date (2006-01-02) or RFC3339 time string
+func toStarlark(record transformation.Record) starlark.Value {
+	fields := make(map[string]interface{}, len(record.Fields))
+	for name, value := range record.Fields {
+		if t, ok := value.(time.Time); ok {
+			value = transformation.FormatDate(t)
+		}
+		fields[name] = value
+	}
+	return fromGo(transformation.Nest(fields))
+}
+
+func toGo(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		n, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("int %s out of range", v)
+		}
+		return n, nil
+	case starlark.Float:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, fmt.Errorf("float %s is not a number", v)
+		}
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict key %s is not string", item[0])
+			}
+			value, err := toGo(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(key)] = value
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value type %s", value.Type())
+}
+
+func fromGo(value interface{}) starlark.Value {
+	switch v := value.(type) {
+	case bool:
+		return starlark.Bool(v)
+	case int64:
+		return starlark.MakeInt64(v)
+	case float64:
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case map[string]interface{}:
+		dict := starlark.NewDict(len(v))
+		for key, value := range v {
+			dict.SetKey(starlark.String(key), fromGo(value))
+		}
+		return dict
+	case []interface{}:
+		list := make([]starlark.Value, 0, len(v))
+		for _, value := range v {
+			list = append(list, fromGo(value))
+		}
+		return starlark.NewList(list)
+	}
+
+	return starlark.None
+}
@@ -0,0 +1,162 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	starlarkjson "go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+const (
+	// function called with raw payload when script is used as transformer
+	transformFunction = "transform"
+	// function called with transformed record when script is used as transformation step
+	transformRecordFunction = "transform_record"
+)
+
+// modules available to script, script has no access to file system, network or environment
+var predeclared = starlark.StringDict{
+	"json": starlarkjson.Module,
+	"math": starlarkmath.Module,
+	"time": starlarktime.Module,
+}
+
+// Starlark script running in sandbox with CPU limits (execution steps and timeout)
+// memory allocated by script is not limited, a single step can allocate large string or list (e.g. "a" * n)
+type Script struct {
+	name     string
+	globals  starlark.StringDict
+	maxSteps uint64
+	timeout  time.Duration
+	schemas  transformation.Schemas
+	logger   utils.Logger
+}
+
+// load script and run its top level statements
+// records changed by transformation step are conformed to schema of their entity
+// error contains script line number
+func NewScript(c config.ScriptConfig, schemas transformation.Schemas, logger utils.Logger) (*Script, error) {
+	src, err := os.ReadFile(c.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read script %s: %w", c.Name, err)
+	}
+
+	s := &Script{
+		name:     c.Name,
+		maxSteps: uint64(c.MaxSteps),
+		timeout:  time.Duration(c.Timeout) * time.Millisecond,
+		schemas:  schemas,
+		logger:   logger.WithFields(utils.Fields{"script": c.Name}),
+	}
+
+	thread, stop := s.newThread(s.logger)
+	defer stop()
+	globals, err := starlark.ExecFile(thread, c.Path, src, predeclared)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	// frozen globals can be shared by concurrent calls
+	globals.Freeze()
+	s.globals = globals
+
+	return s, nil
+}
+
+// transform raw payload by transform(payload) function of script
+// function return a record (dict), list of records or None
+// records are conformed to schema of datasource entity after transformation
+// print of script is logged by logger of the raw data
+func (s *Script) Transform(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+	result, err := s.call(logger.WithFields(utils.Fields{"script": s.name}), transformFunction, starlark.String(data))
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := toRecords(result)
+	if err != nil {
+		return nil, fmt.Errorf("script %s %s returned invalid records: %w", s.name, transformFunction, err)
+	}
+	return records, nil
+}
+
+// transformation step calling transform_record(record) function of script
+// function return the changed record (dict) or None to drop the record
+func (s *Script) Step() transformation.Step {
+	return func(record *transformation.Record) error {
+		logger := s.logger.WithFields(utils.Fields{utils.FieldDatasource: record.Metadata.Datasource, utils.FieldRecordID: record.Metadata.CorrelationID})
+		result, err := s.call(logger, transformRecordFunction, toStarlark(*record))
+		if err != nil {
+			return err
+		}
+		if result == starlark.None {
+			return fmt.Errorf("%w by script %s", transformation.ErrRecordDropped, s.name)
+		}
+
+		records, err := toRecords(result)
+		if err != nil || len(records) != 1 {
+			return fmt.Errorf("script %s %s must return a record or None", s.name, transformRecordFunction)
+		}
+		records[0].Metadata = record.Metadata
+		records[0].Entity = record.Entity
+		if err := s.schemas.Conform(&records[0]); err != nil {
+			return fmt.Errorf("script %s %s returned invalid record: %w", s.name, transformRecordFunction, err)
+		}
+		*record = records[0]
+		return nil
+	}
+}
+
+// call function of script with argument
+func (s *Script) call(logger utils.Logger, function string, arg starlark.Value) (starlark.Value, error) {
+	fn, ok := s.globals[function]
+	if !ok {
+		return nil, fmt.Errorf("script %s has no %s function", s.name, function)
+	}
+	thread, stop := s.newThread(logger)
+	defer stop()
+	result, err := starlark.Call(thread, fn, starlark.Tuple{arg}, nil)
+	if err != nil {
+		return nil, s.error(err)
+	}
+	return result, nil
+}
+
+// create thread with execution steps limit and cancel it on timeout, print of script is logged by logger
+// stop must be called after thread finished
+func (s *Script) newThread(logger utils.Logger) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{
+		Name: s.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			logger.Debugf("script print %s", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(s.maxSteps)
+
+	timer := time.AfterFunc(s.timeout, func() {
+		thread.Cancel(fmt.Sprintf("timeout after %v", s.timeout))
+	})
+	return thread, func() { timer.Stop() }
+}
+
+// format error with script position (file:line:column) of innermost frame
+func (s *Script) error(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		for i := len(evalErr.CallStack) - 1; i >= 0; i-- {
+			frame := evalErr.CallStack[i]
+			// skip frames of builtin functions (e.g. json.decode) which have no position in script
+			if frame.Pos.IsValid() && frame.Pos.Filename() != "<builtin>" {
+				return fmt.Errorf("script %s %s: in %s: %s", s.name, frame.Pos, frame.Name, evalErr.Msg)
+			}
+		}
+	}
+	return fmt.Errorf("script %s %v", s.name, err)
+}
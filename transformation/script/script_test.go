@@ -0,0 +1,241 @@
+package script_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/script"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const usersScript = `
+def transform(payload):
+    data = json.decode(payload)
+    if data.get("deleted"):
+        return None
+    records = []
+    for user in data["users"]:
+        first, last = user["name"].split(" ")
+        records.append({
+            "FirstName": first,
+            "LastName": last,
+            "DateOfBirth": user["dob"],
+            "Address": {"City": user["city"], "Latitude": user["lat"]},
+        })
+    return records
+
+def transform_record(record):
+    if record["FirstName"] == "test":
+        return None
+    record["Address"]["Country"] = record["Address"]["Country"] or "United States"
+    return record
+`
+
+func TestTransform(t *testing.T) {
+	type testcase struct {
+		testcase      string
+		payload       string
+		expected      []transformation.Record
+		expectedError string
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Multiple records",
+			payload:  `{"users": [{"name": "John Doe", "dob": "1990-01-02", "city": "Boston", "lat": 42.3}, {"name": "Jane Roe", "dob": "", "city": "", "lat": 0}]}`,
+			expected: []transformation.Record{
+				user(fields{"FirstName": "John", "LastName": "Doe", "DateOfBirth": time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC), "Address.City": "Boston", "Address.Latitude": 42.3}),
+				user(fields{"FirstName": "Jane", "LastName": "Roe", "DateOfBirth": time.Time{}, "Address.City": "", "Address.Latitude": 0.0}),
+			},
+		},
+		{
+			testcase: "No record",
+			payload:  `{"deleted": true}`,
+			expected: []transformation.Record{},
+		},
+		{
+			testcase:      "Runtime error with line number",
+			payload:       `{"users": [{"name": "Cher"}]}`,
+			expectedError: "users.star:8:21: in transform",
+		},
+		{
+			testcase:      "Invalid payload",
+			payload:       `not json`,
+			expectedError: "users.star:3:23: in transform: json.decode",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newScriptDependencies(t, usersScript)
+			s, err := script.NewScript(dep.config, dep.schemas, dep.logger)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+
+			v, err := s.Transform(dep.logger, []byte(tc.payload))
+			if tc.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+					t.Errorf("expected error %v, but got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if len(v) != len(tc.expected) {
+				t.Fatalf("expected %+v, but got %+v", tc.expected, v)
+			}
+			for i := range v {
+				v[i].Entity = config.DefaultEntity
+				if err := dep.schemas.Conform(&v[i]); err != nil {
+					t.Fatalf("not expected error, but got %v", err)
+				}
+				if !reflect.DeepEqual(v[i], tc.expected[i]) {
+					t.Errorf("expected %+v, but got %+v", tc.expected[i], v[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStep(t *testing.T) {
+	dep := newScriptDependencies(t, usersScript)
+	s, err := script.NewScript(dep.config, dep.schemas, dep.logger)
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	john := user(fields{"FirstName": "John", "DateOfBirth": time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)})
+	john.Metadata = transformation.RecordMetadata{Datasource: "a"}
+	records, dropped, err := transformation.ApplySteps([]transformation.Record{
+		john,
+		user(fields{"FirstName": "test"}),
+	}, []transformation.Step{s.Step()})
+	if err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+
+	expected := user(fields{"FirstName": "John", "DateOfBirth": time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC), "Address.Country": "United States"})
+	expected.Metadata = transformation.RecordMetadata{Datasource: "a"}
+	if len(records) != 1 || !reflect.DeepEqual(records[0], expected) {
+		t.Errorf("expected %+v, but got %+v", expected, records)
+	}
+	if dropped != 1 {
+		t.Errorf("expected 1 dropped record, but got %v", dropped)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	type testcase struct {
+		testcase      string
+		script        string
+		modify        func(c *config.ScriptConfig)
+		expectedError string
+	}
+
+	testcases := []testcase{
+		{
+			testcase:      "Syntax error",
+			script:        "def transform(payload)\n    return None\n",
+			expectedError: "users.star:2:1",
+		},
+		{
+			testcase: "Execution steps limit",
+			script:   "def transform(payload):\n    for i in range(1000000):\n        pass\n",
+			modify: func(c *config.ScriptConfig) {
+				c.MaxSteps = 1000
+			},
+			expectedError: "too many steps",
+		},
+		{
+			testcase: "Timeout",
+			script:   "def transform(payload):\n    for i in range(100000000):\n        pass\n",
+			modify: func(c *config.ScriptConfig) {
+				c.MaxSteps = 1000000000
+				c.Timeout = 10
+			},
+			expectedError: "timeout",
+		},
+		{
+			testcase:      "No file system access",
+			script:        "load(\"os\", \"open\")\ndef transform(payload):\n    return None\n",
+			expectedError: "load not implemented",
+		},
+		{
+			testcase:      "Invalid record",
+			script:        "def transform(payload):\n    return {\"Nickname\": \"Johnny\"}\n",
+			expectedError: "field Nickname not found in entity users",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newScriptDependencies(t, tc.script)
+			if tc.modify != nil {
+				tc.modify(&dep.config)
+			}
+
+			s, err := script.NewScript(dep.config, dep.schemas, dep.logger)
+			var records []transformation.Record
+			if err == nil {
+				records, err = s.Transform(dep.logger, []byte("{}"))
+			}
+			for i := 0; err == nil && i < len(records); i++ {
+				records[i].Entity = config.DefaultEntity
+				err = dep.schemas.Conform(&records[i])
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("expected error %v, but got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+type fields map[string]interface{}
+
+// users record conformed to schema of users entity
+func user(f fields) transformation.Record {
+	record := transformation.Record{Entity: config.DefaultEntity, Fields: f}
+	if err := schemas.Conform(&record); err != nil {
+		panic(err)
+	}
+	return record
+}
+
+var schemas = func() transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{config.UserEntity()})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+type scriptDependencies struct {
+	config  config.ScriptConfig
+	schemas transformation.Schemas
+	logger  utils.Logger
+}
+
+func newScriptDependencies(t *testing.T, content string) scriptDependencies {
+	path := filepath.Join(t.TempDir(), "users.star")
+	os.WriteFile(path, []byte(content), 0o644)
+
+	return scriptDependencies{
+		config: config.ScriptConfig{
+			Name:     "users",
+			Path:     path,
+			MaxSteps: 1000000,
+			Timeout:  1000,
+		},
+		schemas: schemas,
+		logger:  utils.NewLogrusLogger(logrus.StandardLogger()),
+	}
+}
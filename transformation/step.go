@@ -0,0 +1,43 @@
+package transformation
+
+import "errors"
+
+// returned by step to drop record without failing the datasource
+var ErrRecordDropped = errors.New("record dropped")
+
+// transformation step applied to transformed record after parsing
+type Step func(record *Record) error
+
+// apply steps in order to each record, record dropped by step is removed
+// return remaining records and number of dropped records
+func ApplySteps(records []Record, steps []Step) ([]Record, int, error) {
+	if len(steps) == 0 {
+		return records, 0, nil
+	}
+
+	remaining := records[:0]
+	dropped := 0
+	for _, record := range records {
+		err := applySteps(&record, steps)
+		if errors.Is(err, ErrRecordDropped) {
+			dropped++
+			continue
+		}
+		if err != nil {
+			return nil, dropped, err
+		}
+		remaining = append(remaining, record)
+	}
+
+	return remaining, dropped, nil
+}
+
+// record is not passed to remaining steps if any step return error
+func applySteps(record *Record, steps []Step) error {
+	for _, step := range steps {
+		if err := step(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package steps
+
+import (
+	"fmt"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/geo"
+	"github.com/awcjack/ETL-sample/transformation/iso3166"
+)
+
+const (
+	defaultGeohashPrecision = 9
+
+	// coordinates inside claimed country
+	geoCheckMatch = "match"
+	// coordinates outside claimed country (in other country or international waters)
+	geoCheckMismatch = "mismatch"
+	// coordinates or claimed country missing or invalid
+	geoCheckUnresolved = "unresolved"
+)
+
+// address fields set by geo step
+const (
+	fieldLatitude       = "Address.Latitude"
+	fieldLongitude      = "Address.Longitude"
+	fieldGeoCountryCode = "Address.GeoCountryCode"
+	fieldGeoCheck       = "Address.GeoCheck"
+	fieldTimezone       = "Address.Timezone"
+	fieldGeohash        = "Address.Geohash"
+)
+
+// enrich address with country, timezone and geohash of coordinates and check coordinates against claimed country
+// claimed country is Address.CountryCode (set by iso3166 step) or resolved from Address.Country
+// record of entity without these fields is skipped
+func geoStep(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+	precision := c.Precision
+	if precision == 0 {
+		precision = defaultGeohashPrecision
+	}
+	if precision < 0 || precision > geo.MaxGeohashPrecision {
+		return nil, fmt.Errorf("Precision must be between 1 and %d but got %d", geo.MaxGeohashPrecision, precision)
+	}
+	if err := requireFields(schemas, transformation.FieldFloat, fieldLatitude, fieldLongitude); err != nil {
+		return nil, err
+	}
+	if err := requireFields(schemas, transformation.FieldString, fieldCountry, fieldCountryCode, fieldGeoCountryCode, fieldGeoCheck, fieldTimezone, fieldGeohash); err != nil {
+		return nil, err
+	}
+
+	return func(record *transformation.Record) error {
+		if !hasFields(record, fieldLatitude, fieldLongitude, fieldCountry, fieldCountryCode, fieldGeoCountryCode, fieldGeoCheck, fieldTimezone, fieldGeohash) {
+			return nil
+		}
+		geoCountryCode, geoCheck, timezone, geohash := "", geoCheckUnresolved, "", ""
+		defer func() {
+			record.Set(fieldGeoCountryCode, nullIfEmpty(geoCountryCode))
+			record.Set(fieldGeoCheck, geoCheck)
+			record.Set(fieldTimezone, nullIfEmpty(timezone))
+			record.Set(fieldGeohash, nullIfEmpty(geohash))
+		}()
+
+		lat, lng := record.Float(fieldLatitude), record.Float(fieldLongitude)
+		// zero coordinates are treated as missing like null coordinates
+		if (lat == 0 && lng == 0) || !geo.Valid(lat, lng) {
+			return nil
+		}
+		geohash = geo.Geohash(lat, lng, precision)
+		if location, ok := geo.Lookup(lat, lng); ok {
+			geoCountryCode = location.CountryCode
+			timezone = location.Timezone
+		} else {
+			timezone = geo.NauticalTimezone(lng)
+		}
+
+		claimed := record.String(fieldCountryCode)
+		if claimed == "" {
+			if country, ok := iso3166.LookupCountry(record.String(fieldCountry)); ok {
+				claimed = country.Alpha2
+			}
+		}
+		switch {
+		case claimed == "":
+		case claimed == geoCountryCode:
+			geoCheck = geoCheckMatch
+		default:
+			geoCheck = geoCheckMismatch
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,85 @@
+package steps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/iso3166"
+)
+
+// address fields used by iso3166 step
+const (
+	fieldCountry     = "Address.Country"
+	fieldCountryCode = "Address.CountryCode"
+	fieldState       = "Address.State"
+	fieldStateCode   = "Address.StateCode"
+)
+
+// set Address.CountryCode (ISO 3166-1 alpha-2) and Address.StateCode (ISO 3166-2) from free text Address.Country and Address.State
+// code is cleared (null) if value can't be resolved, so unresolvable record can be flagged by validation rule of code field
+// record of entity without these fields is skipped
+func iso3166Step(schemas transformation.Schemas) (transformation.Step, error) {
+	if err := requireFields(schemas, transformation.FieldString, fieldCountry, fieldCountryCode, fieldState, fieldStateCode); err != nil {
+		return nil, err
+	}
+
+	return func(record *transformation.Record) error {
+		if !hasFields(record, fieldCountry, fieldCountryCode, fieldState, fieldStateCode) {
+			return nil
+		}
+		country, state := record.String(fieldCountry), record.String(fieldState)
+		countryCode, stateCode := "", ""
+		defer func() {
+			record.Set(fieldCountryCode, nullIfEmpty(countryCode))
+			record.Set(fieldStateCode, nullIfEmpty(stateCode))
+		}()
+
+		if strings.TrimSpace(country) != "" {
+			if resolved, ok := iso3166.LookupCountry(country); ok {
+				countryCode = resolved.Alpha2
+			}
+		}
+		if strings.TrimSpace(state) == "" {
+			return nil
+		}
+		// subdivision code (e.g. US-TX) can be resolved without country
+		if countryCode == "" && strings.TrimSpace(country) == "" {
+			if subdivision, ok := iso3166.LookupSubdivision("", state); ok {
+				stateCode = subdivision.Code
+				countryCode = subdivision.Code[:2]
+			}
+			return nil
+		}
+		if countryCode != "" {
+			if subdivision, ok := iso3166.LookupSubdivision(countryCode, state); ok {
+				stateCode = subdivision.Code
+			}
+		}
+		return nil
+	}, nil
+}
+
+// fields must exist in any entity with type
+func requireFields(schemas transformation.Schemas, fieldType transformation.FieldType, names ...string) error {
+	for _, name := range names {
+		field, err := schemas.Field(name)
+		if err != nil {
+			return err
+		}
+		if field.Name != name || field.Type != fieldType {
+			return fmt.Errorf("field %s must be %s field named %s", field.Name, fieldType, name)
+		}
+	}
+	return nil
+}
+
+// entity of record has every field
+func hasFields(record *transformation.Record, names ...string) bool {
+	for _, name := range names {
+		if !record.Has(name) {
+			return false
+		}
+	}
+	return true
+}
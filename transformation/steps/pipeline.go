@@ -0,0 +1,77 @@
+package steps
+
+import (
+	"fmt"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// compiled transformation step of a datasource
+type datasourceStep struct {
+	datasource string
+	step       transformation.Step
+}
+
+// transformation steps of all datasources in config order
+type Pipeline struct {
+	steps []datasourceStep
+}
+
+// compile steps from config and collect every problem (unknown field, invalid regex etc) into a single error
+// scripts are steps of script step keyed by script name, fields are resolved from schemas of entities
+func NewPipeline(steps []config.TransformationStepConfig, scripts map[string]transformation.Step, schemas transformation.Schemas) (*Pipeline, error) {
+	p := &Pipeline{
+		steps: make([]datasourceStep, 0, len(steps)),
+	}
+	problems := make([]string, 0)
+	for i, stepConfig := range steps {
+		if stepConfig.Step == "script" {
+			step, ok := scripts[stepConfig.Script]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("Transformation.Steps[%d] (%s) script %s not found", i, stepConfig.Step, stepConfig.Script))
+				continue
+			}
+			p.steps = append(p.steps, datasourceStep{
+				datasource: stepConfig.Datasource,
+				step:       step,
+			})
+			continue
+		}
+
+		factory, ok := factories[stepConfig.Step]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Transformation.Steps[%d] (%s) step not implemented", i, stepConfig.Step))
+			continue
+		}
+		step, err := factory(stepConfig, schemas)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Transformation.Steps[%d] (%s) %v", i, stepConfig.Step, err))
+			continue
+		}
+		p.steps = append(p.steps, datasourceStep{
+			datasource: stepConfig.Datasource,
+			step:       step,
+		})
+	}
+
+	if len(problems) != 0 {
+		return nil, &config.ValidationError{Problems: problems}
+	}
+	return p, nil
+}
+
+// steps of datasource in config order, steps without datasource apply to every datasource
+func (p *Pipeline) For(datasource string) []transformation.Step {
+	if p == nil {
+		return nil
+	}
+
+	steps := make([]transformation.Step, 0, len(p.steps))
+	for _, s := range p.steps {
+		if s.datasource == "" || s.datasource == datasource {
+			steps = append(steps, s.step)
+		}
+	}
+	return steps
+}
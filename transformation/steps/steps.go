@@ -0,0 +1,218 @@
+package steps
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// step factory keyed by step name
+var factories = map[string]func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error){
+	// remove leading and trailing spaces
+	"trim": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return stringStep(schemas, c.Fields, true, strings.TrimSpace)
+	},
+	// trim and collapse consecutive spaces into single space
+	"normalize-space": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return stringStep(schemas, c.Fields, true, func(s string) string {
+			return strings.Join(strings.Fields(s), " ")
+		})
+	},
+	// capitalize first letter of each word and lowercase the rest (e.g. "mary-jane o'brien" -> "Mary-Jane O'Brien")
+	"title-case": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return stringStep(schemas, c.Fields, false, titleCase)
+	},
+	"uppercase": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return stringStep(schemas, c.Fields, false, strings.ToUpper)
+	},
+	"lowercase": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return stringStep(schemas, c.Fields, false, strings.ToLower)
+	},
+	// set field from template if field is empty
+	"default": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return templateStep(schemas, c.Fields, c.Value, true)
+	},
+	// set field from template
+	"derive": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return templateStep(schemas, c.Fields, c.Value, false)
+	},
+	// resolve ISO 3166 country and subdivision codes of address
+	"iso3166": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		return iso3166Step(schemas)
+	},
+	// country, timezone and geohash of coordinates
+	"geo": geoStep,
+	// drop record if any field match pattern
+	"drop": func(c config.TransformationStepConfig, schemas transformation.Schemas) (transformation.Step, error) {
+		if c.Pattern == "" {
+			return nil, fmt.Errorf("Pattern is missing")
+		}
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Pattern is invalid: %v", err)
+		}
+		fields, err := stringFields(schemas, c.Fields, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(record *transformation.Record) error {
+			for _, field := range fields {
+				if value, ok := stringValue(record, field); ok && pattern.MatchString(value) {
+					return fmt.Errorf("%w: %s match %s", transformation.ErrRecordDropped, field, c.Pattern)
+				}
+			}
+			return nil
+		}, nil
+	},
+}
+
+// apply fn to string fields, fields not in entity of record are skipped
+func stringStep(schemas transformation.Schemas, names []string, allowAllFields bool, fn func(string) string) (transformation.Step, error) {
+	fields, err := stringFields(schemas, names, allowAllFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(record *transformation.Record) error {
+		for _, field := range fields {
+			if value, ok := stringValue(record, field); ok {
+				record.Set(field, fn(value))
+			}
+		}
+		return nil
+	}, nil
+}
+
+// set string fields from template, {Field} in template is replaced by value of field
+func templateStep(schemas transformation.Schemas, names []string, template string, onlyEmpty bool) (transformation.Step, error) {
+	if template == "" {
+		return nil, fmt.Errorf("Value is missing")
+	}
+	fields, err := stringFields(schemas, names, false)
+	if err != nil {
+		return nil, err
+	}
+	render, err := compileTemplate(schemas, template)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(record *transformation.Record) error {
+		// render before setting any field so derived fields don't affect each other
+		rendered := render(*record)
+		for _, field := range fields {
+			// null field is empty
+			if !record.Has(field) {
+				continue
+			}
+			if value, _ := stringValue(record, field); onlyEmpty && strings.TrimSpace(value) != "" {
+				continue
+			}
+			record.Set(field, rendered)
+		}
+		return nil
+	}, nil
+}
+
+// {Field} placeholder in template
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// compile template into function rendering template with field values of record
+// placeholder of field not in entity of record is rendered as empty string
+func compileTemplate(schemas transformation.Schemas, template string) (func(record transformation.Record) string, error) {
+	fields := make(map[string]string)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		field, err := schemas.Field(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("Value is invalid: %v", err)
+		}
+		fields[match[1]] = field.Name
+	}
+
+	return func(record transformation.Record) string {
+		return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+			return format(record.Get(fields[placeholder[1:len(placeholder)-1]]))
+		})
+	}, nil
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.DateOnly)
+	}
+	return fmt.Sprint(value)
+}
+
+// resolve names of string fields, every string field of every entity is used if fields is empty and allowAllFields is set
+func stringFields(schemas transformation.Schemas, names []string, allowAllFields bool) ([]string, error) {
+	if len(names) == 0 {
+		if !allowAllFields {
+			return nil, fmt.Errorf("Fields is missing")
+		}
+		return schemas.FieldsOfType(transformation.FieldString), nil
+	}
+
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		field, err := schemas.Field(name)
+		if err != nil {
+			return nil, err
+		}
+		if field.Type != transformation.FieldString {
+			return nil, fmt.Errorf("field %s is not a string", name)
+		}
+		fields = append(fields, field.Name)
+	}
+
+	return fields, nil
+}
+
+// unresolved value is cleared to null, so it is stored as NULL
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// value of string field, null field, field not in entity of record or with other type in entity of record is not found
+func stringValue(record *transformation.Record, field string) (string, bool) {
+	value, ok := record.Get(field).(string)
+	return value, ok
+}
+
+// uppercase first letter of each word and lowercase the rest, word is separated by character other than letter and digit
+func titleCase(s string) string {
+	runes := []rune(s)
+	startOfWord := true
+	for i, r := range runes {
+		if unicode.IsDigit(r) {
+			startOfWord = false
+			continue
+		}
+		if !unicode.IsLetter(r) {
+			startOfWord = true
+			continue
+		}
+		if startOfWord {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+		startOfWord = false
+	}
+
+	return string(runes)
+}
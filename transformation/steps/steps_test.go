@@ -0,0 +1,262 @@
+package steps_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/steps"
+)
+
+func TestPipeline(t *testing.T) {
+	type testcase struct {
+		testcase     string
+		steps        []config.TransformationStepConfig
+		datasource   string
+		input        transformation.Record
+		expected     []transformation.Record
+		expectedDrop int
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Trim and normalize every string field",
+			steps: []config.TransformationStepConfig{
+				{Step: "trim"},
+				{Step: "normalize-space", Fields: []string{"Address.StreetAddress"}},
+			},
+			datasource: "a",
+			input:      user(fields{"FirstName": "  John ", "Address.StreetAddress": " 82204   Wisoky  Canyon", "Address.City": "\tMarionland\n"}),
+			expected:   []transformation.Record{user(fields{"FirstName": "John", "Address.StreetAddress": "82204 Wisoky Canyon", "Address.City": "Marionland"})},
+		},
+		{
+			testcase: "Title case names and uppercase zip code",
+			steps: []config.TransformationStepConfig{
+				{Step: "title-case", Fields: []string{"FirstName", "LastName"}},
+				{Step: "uppercase", Fields: []string{"address.zipcode"}},
+			},
+			datasource: "a",
+			input:      user(fields{"FirstName": "mary-jane", "LastName": "o'BRIEN", "Address.ZipCode": "sw1a 1aa"}),
+			expected:   []transformation.Record{user(fields{"FirstName": "Mary-Jane", "LastName": "O'Brien", "Address.ZipCode": "SW1A 1AA"})},
+		},
+		{
+			testcase: "Default and derive fields",
+			steps: []config.TransformationStepConfig{
+				{Step: "default", Fields: []string{"Address.Country"}, Value: "United States"},
+				{Step: "default", Fields: []string{"Address.State"}, Value: "unknown"},
+				{Step: "derive", Fields: []string{"Address.StreetName"}, Value: "{Address.City} ({DateOfBirth})"},
+			},
+			datasource: "a",
+			input:      user(fields{"DateOfBirth": time.Date(1981, 8, 30, 0, 0, 0, 0, time.UTC), "Address.City": "Marionland", "Address.State": "Washington"}),
+			expected:   []transformation.Record{user(fields{"DateOfBirth": time.Date(1981, 8, 30, 0, 0, 0, 0, time.UTC), "Address.City": "Marionland", "Address.State": "Washington", "Address.Country": "United States", "Address.StreetName": "Marionland (1981-08-30)"})},
+		},
+		{
+			testcase: "Drop matching record",
+			steps: []config.TransformationStepConfig{
+				{Step: "drop", Fields: []string{"FirstName"}, Pattern: "^test"},
+				{Step: "uppercase", Fields: []string{"FirstName"}},
+			},
+			datasource:   "a",
+			input:        user(fields{"FirstName": "test user"}),
+			expected:     []transformation.Record{},
+			expectedDrop: 1,
+		},
+		{
+			testcase:   "Resolve ISO 3166 codes",
+			steps:      []config.TransformationStepConfig{{Step: "iso3166"}},
+			datasource: "a",
+			input:      user(fields{"Address.State": "texas", "Address.Country": "United States of America"}),
+			expected:   []transformation.Record{user(fields{"Address.State": "texas", "Address.StateCode": "US-TX", "Address.Country": "United States of America", "Address.CountryCode": "US"})},
+		},
+		{
+			testcase:   "Resolve ISO 3166 codes from subdivision code",
+			steps:      []config.TransformationStepConfig{{Step: "iso3166"}},
+			datasource: "a",
+			input:      user(fields{"Address.State": "GB-SCT"}),
+			expected:   []transformation.Record{user(fields{"Address.State": "GB-SCT", "Address.StateCode": "GB-SCT", "Address.CountryCode": "GB"})},
+		},
+		{
+			testcase:   "Unresolvable country and state cleared",
+			steps:      []config.TransformationStepConfig{{Step: "iso3166"}},
+			datasource: "a",
+			input:      user(fields{"Address.State": "Texas", "Address.StateCode": "US-TX", "Address.Country": "Republic of Texas", "Address.CountryCode": "US"}),
+			expected:   []transformation.Record{user(fields{"Address.State": "Texas", "Address.Country": "Republic of Texas"})},
+		},
+		{
+			testcase:   "Coordinates inside claimed country",
+			steps:      []config.TransformationStepConfig{{Step: "iso3166"}, {Step: "geo", Precision: 5}},
+			datasource: "a",
+			input:      user(fields{"Address.Country": "United States", "Address.Latitude": 40.7128, "Address.Longitude": -74.006}),
+			expected:   []transformation.Record{user(fields{"Address.Country": "United States", "Address.CountryCode": "US", "Address.Latitude": 40.7128, "Address.Longitude": -74.006, "Address.GeoCountryCode": "US", "Address.GeoCheck": "match", "Address.Timezone": "America/New_York", "Address.Geohash": "dr5re"})},
+		},
+		{
+			testcase:   "Coordinates outside claimed country",
+			steps:      []config.TransformationStepConfig{{Step: "geo"}},
+			datasource: "a",
+			input:      user(fields{"Address.Country": "Canada", "Address.Latitude": 30.0, "Address.Longitude": -45.0}),
+			expected:   []transformation.Record{user(fields{"Address.Country": "Canada", "Address.Latitude": 30.0, "Address.Longitude": -45.0, "Address.GeoCheck": "mismatch", "Address.Timezone": "Etc/GMT+3", "Address.Geohash": "ej248j248"})},
+		},
+		{
+			testcase:   "Missing coordinates",
+			steps:      []config.TransformationStepConfig{{Step: "geo"}},
+			datasource: "a",
+			input:      user(fields{"Address.Country": "Canada", "Address.Timezone": "America/Toronto"}),
+			expected:   []transformation.Record{user(fields{"Address.Country": "Canada", "Address.GeoCheck": "unresolved"})},
+		},
+		{
+			testcase: "Step of other datasource ignored",
+			steps: []config.TransformationStepConfig{
+				{Datasource: "b", Step: "uppercase", Fields: []string{"FirstName"}},
+				{Datasource: "a", Step: "lowercase", Fields: []string{"LastName"}},
+			},
+			datasource: "a",
+			input:      user(fields{"FirstName": "John", "LastName": "DOE"}),
+			expected:   []transformation.Record{user(fields{"FirstName": "John", "LastName": "doe"})},
+		},
+		{
+			testcase: "Field not in entity of record skipped",
+			steps: []config.TransformationStepConfig{
+				{Step: "trim"},
+				{Step: "uppercase", Fields: []string{"FirstName", "Status"}},
+				{Step: "default", Fields: []string{"Address.Country"}, Value: "United States"},
+				{Step: "iso3166"},
+				{Step: "geo"},
+			},
+			datasource: "a",
+			input:      order(fields{"OrderId": 1, "Status": " paid "}),
+			expected:   []transformation.Record{order(fields{"OrderId": 1, "Status": "PAID"})},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			pipeline, err := steps.NewPipeline(tc.steps, nil, schemas)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			v, dropped, err := transformation.ApplySteps([]transformation.Record{tc.input}, pipeline.For(tc.datasource))
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if dropped != tc.expectedDrop {
+				t.Errorf("expected %v dropped records, but got %v", tc.expectedDrop, dropped)
+			}
+			if len(v) != len(tc.expected) {
+				t.Fatalf("expected %+v, but got %+v", tc.expected, v)
+			}
+			for i := range v {
+				if !reflect.DeepEqual(v[i], tc.expected[i]) {
+					t.Errorf("expected %+v, but got %+v", tc.expected[i], v[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewPipelineInvalidStep(t *testing.T) {
+	type testcase struct {
+		testcase         string
+		step             config.TransformationStepConfig
+		expectedProblems []string
+	}
+
+	testcases := []testcase{
+		{
+			testcase:         "Unknown step",
+			step:             config.TransformationStepConfig{Step: "reverse", Fields: []string{"FirstName"}},
+			expectedProblems: []string{"step not implemented"},
+		},
+		{
+			testcase:         "Unknown script",
+			step:             config.TransformationStepConfig{Step: "script", Script: "users"},
+			expectedProblems: []string{"script users not found"},
+		},
+		{
+			testcase:         "Invalid geohash precision",
+			step:             config.TransformationStepConfig{Step: "geo", Precision: 13},
+			expectedProblems: []string{"Precision must be between 1 and 12"},
+		},
+		{
+			testcase:         "Unknown field",
+			step:             config.TransformationStepConfig{Step: "uppercase", Fields: []string{"MiddleName"}},
+			expectedProblems: []string{"field MiddleName not found"},
+		},
+		{
+			testcase:         "Not string field",
+			step:             config.TransformationStepConfig{Step: "trim", Fields: []string{"Address.Latitude"}},
+			expectedProblems: []string{"is not a string"},
+		},
+		{
+			testcase:         "Field with different type in entities",
+			step:             config.TransformationStepConfig{Step: "trim", Fields: []string{"Total"}},
+			expectedProblems: []string{"field Total has different name or type in entities"},
+		},
+		{
+			testcase:         "Missing fields",
+			step:             config.TransformationStepConfig{Step: "title-case"},
+			expectedProblems: []string{"Fields is missing"},
+		},
+		{
+			testcase:         "Invalid pattern",
+			step:             config.TransformationStepConfig{Step: "drop", Fields: []string{"FirstName"}, Pattern: "("},
+			expectedProblems: []string{"Pattern is invalid"},
+		},
+		{
+			testcase:         "Unknown template field",
+			step:             config.TransformationStepConfig{Step: "derive", Fields: []string{"FirstName"}, Value: "{Nickname}"},
+			expectedProblems: []string{"Value is invalid"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			_, err := steps.NewPipeline([]config.TransformationStepConfig{tc.step}, nil, schemas)
+			var validationErr *config.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected validation error, but got %v", err)
+			}
+			for _, expected := range tc.expectedProblems {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected problem %v, but got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+type fields map[string]interface{}
+
+// users and orders entities, Total is string in users and float in orders
+var schemas = func() transformation.Schemas {
+	users := config.UserEntity()
+	users.Fields = append(users.Fields, config.EntityFieldConfig{Name: "Total", Type: "string"})
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{
+		users,
+		{Name: "orders", Fields: []config.EntityFieldConfig{{Name: "OrderId", Type: "int"}, {Name: "Status", Type: "string"}, {Name: "Total", Type: "float"}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+func user(f fields) transformation.Record {
+	return newRecord("users", f)
+}
+
+func order(f fields) transformation.Record {
+	return newRecord("orders", f)
+}
+
+// record conformed to schema of entity
+func newRecord(entity string, f fields) transformation.Record {
+	record := transformation.Record{Entity: entity, Fields: f}
+	if err := schemas.Conform(&record); err != nil {
+		panic(err)
+	}
+	return record
+}
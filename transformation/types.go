@@ -1,26 +1,268 @@
 package transformation
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
 
-// transformed (unified) data format
-type TransformedData struct {
-	FirstName   string
-	LastName    string
-	DateOfBirth time.Time
-	Address     StructuredAddress
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// transformed (unified) record of an entity
+// field values are typed by entity schema: string, float64, int64, bool or time.Time (date)
+type Record struct {
+	// metadata attached by extraction stage, not part of the transformed payload
+	Metadata RecordMetadata
+	// entity of record (e.g. users, orders), entity is mapped to table by Entities config
+	Entity string
+	// field values keyed by field name of entity schema (e.g. FirstName, Address.City)
+	Fields map[string]interface{}
+	// change applied by loader, empty is insert (only change data capture datasource produce update and delete)
+	Operation string `json:",omitempty"`
+}
+
+// change of record applied by loader
+const (
+	OperationInsert = "insert"
+	// replace fields of loaded record with same key (inserted if not loaded)
+	OperationUpdate = "update"
+	// remove loaded record with same key, only key fields are set
+	OperationDelete = "delete"
+)
+
+// metadata for tracing a record across extraction, transformation and loading
+type RecordMetadata struct {
+	// correlation id generated when the record is extracted
+	CorrelationID string
+	// name of the datasource which produce the record
+	Datasource string
+	// extraction position (offset, cursor, file position etc) after this record
+	// saved to checkpoint store once the record is committed by the loader
+	Position string
+	// name of checkpoint which position is saved to, datasource name if empty
+	// (e.g. kafka position is saved to checkpoint of its partition)
+	Checkpoint string
+	// offset in durable queue between extraction and loading (only set if disk queue is enabled)
+	// acknowledged once the record is committed by the loader
+	QueueOffset int64
+}
+
+// create empty record of entity
+func NewRecord(entity string) Record {
+	return Record{
+		Entity: entity,
+		Fields: make(map[string]interface{}),
+	}
+}
+
+// record is inserted by loader
+func (r Record) IsInsert() bool {
+	return r.Operation == "" || r.Operation == OperationInsert
+}
+
+// record has field if entity schema has the field (every field of schema is set after conforming to schema)
+func (r Record) Has(name string) bool {
+	_, ok := r.Fields[name]
+	return ok
+}
+
+// field value, nil if record has no such field
+func (r Record) Get(name string) interface{} {
+	return r.Fields[name]
+}
+
+// string field value, empty string if record has no such string field
+func (r Record) String(name string) string {
+	s, _ := r.Fields[name].(string)
+	return s
+}
+
+// float field value, 0 if record has no such float field
+func (r Record) Float(name string) float64 {
+	f, _ := r.Fields[name].(float64)
+	return f
+}
+
+// int field value, 0 if record has no such int field
+func (r Record) Int(name string) int64 {
+	n, _ := r.Fields[name].(int64)
+	return n
+}
+
+// bool field value, false if record has no such bool field
+func (r Record) Bool(name string) bool {
+	b, _ := r.Fields[name].(bool)
+	return b
+}
+
+// date field value, zero time if record has no such date field
+func (r Record) Time(name string) time.Time {
+	t, _ := r.Fields[name].(time.Time)
+	return t
 }
 
-type StructuredAddress struct {
-	City          string
-	StreetName    string
-	StreetAddress string
-	ZipCode       string
-	State         string
-	Country       string
-	Latitude      float64
-	Longitude     float64
+// set field value
+func (r *Record) Set(name string, value interface{}) {
+	if r.Fields == nil {
+		r.Fields = make(map[string]interface{})
+	}
+	r.Fields[name] = value
+}
+
+// copy of record which fields can be changed without affecting the original record
+func (r Record) Clone() Record {
+	fields := make(map[string]interface{}, len(r.Fields))
+	for name, value := range r.Fields {
+		fields[name] = value
+	}
+	r.Fields = fields
+	return r
+}
+
+// decode record with numbers kept as json.Number, so int field is not rounded before conforming to schema
+// record written before entities were introduced (user fields at top level) is decoded as users record
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	record := Record{Fields: make(map[string]interface{})}
+	legacy := raw["Entity"] == nil && raw["Fields"] == nil
+	for key, value := range raw {
+		switch {
+		case key == "Metadata":
+			if err := json.Unmarshal(value, &record.Metadata); err != nil {
+				return err
+			}
+		case !legacy && key == "Entity":
+			if err := json.Unmarshal(value, &record.Entity); err != nil {
+				return err
+			}
+		case !legacy && key == "Fields":
+			if err := decodeJSON(value, &record.Fields); err != nil {
+				return err
+			}
+		case !legacy && key == "Operation":
+			if err := json.Unmarshal(value, &record.Operation); err != nil {
+				return err
+			}
+		case legacy:
+			var v interface{}
+			if err := decodeJSON(value, &v); err != nil {
+				return err
+			}
+			flatten(record.Fields, key, v)
+		}
+	}
+	if legacy {
+		record.Entity = legacyEntity
+	}
+
+	*r = record
+	return nil
+}
+
+// entity of record written before entities were introduced
+const legacyEntity = "users"
+
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// nested object is flattened to fields separated by dot (e.g. {"Address": {"City": "Boston"}} -> Address.City)
+func flatten(fields map[string]interface{}, name string, value interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		fields[name] = value
+		return
+	}
+	for key, v := range object {
+		flatten(fields, name+"."+key, v)
+	}
+}
+
+// nested object of fields separated by dot, reverse of flatten
+func Nest(fields map[string]interface{}) map[string]interface{} {
+	nested := make(map[string]interface{})
+	for name, value := range fields {
+		parts := strings.Split(name, ".")
+		object := nested
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := object[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				object[part] = child
+			}
+			object = child
+		}
+		object[parts[len(parts)-1]] = value
+	}
+	return nested
+}
+
+// flattened fields of nested object, reverse of Nest
+func Flatten(object map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		flatten(fields, key, value)
+	}
+	return fields
+}
+
+// transform raw data to zero or more records in unified data format
+// entity of record is set to entity of datasource if transformer didn't set it
+// logger has record id of the raw data, so transformer logs can be traced with load logs of the records
+type TransformFunc func(logger utils.Logger, data []byte) ([]Record, error)
+
+// transform JSON object whose keys are field names (e.g. row of sql datasource with columns aliased to field names)
+// nested object is flattened to fields separated by dot, and values are converted to field types when record is conformed to entity schema
+func FieldsTransformer(logger utils.Logger, data []byte) ([]Record, error) {
+	var object map[string]interface{}
+	if err := decodeJSON(data, &object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, errors.New("payload is not JSON object")
+	}
+	return []Record{{Fields: Flatten(object)}}, nil
+}
+
+// transform record published by kafka sink, avro single object encoding is decoded by codecs and other payload is JSON record (archive format)
+// metadata of published record is replaced by metadata of extraction
+func NewRecordTransformer(codecs *AvroCodecs) TransformFunc {
+	return func(logger utils.Logger, data []byte) ([]Record, error) {
+		if IsAvro(data) {
+			record, err := codecs.Decode(data)
+			if err != nil {
+				return nil, err
+			}
+			return []Record{record}, nil
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		if len(record.Fields) == 0 {
+			return nil, errors.New("payload is not record with fields")
+		}
+		record.Metadata = RecordMetadata{}
+		return []Record{record}, nil
+	}
 }
 
-type Transformer interface {
-	Transform(data string) TransformedData
+// adapt transformer of a single record to TransformFunc
+func Single(transform func(logger utils.Logger, data []byte) (Record, error)) TransformFunc {
+	return func(logger utils.Logger, data []byte) ([]Record, error) {
+		record, err := transform(logger, data)
+		if err != nil {
+			return nil, err
+		}
+		return []Record{record}, nil
+	}
 }
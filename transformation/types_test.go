@@ -0,0 +1,64 @@
+package transformation_test
+
+import (
+	"testing"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func TestFieldsTransformer(t *testing.T) {
+	type testcase struct {
+		testcase       string
+		data           string
+		expectedFields map[string]string
+		expectedError  bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase:       "Field names",
+			data:           `{"firstname": "Alice", "Address.City": "Boston"}`,
+			expectedFields: map[string]string{"firstname": "Alice", "Address.City": "Boston"},
+		},
+		{
+			testcase:       "Nested object",
+			data:           `{"FirstName": "Alice", "Address": {"City": "Boston"}}`,
+			expectedFields: map[string]string{"FirstName": "Alice", "Address.City": "Boston"},
+		},
+		{
+			testcase:      "Not object",
+			data:          `["Alice"]`,
+			expectedError: true,
+		},
+		{
+			testcase:      "Null",
+			data:          `null`,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			records, err := transformation.FieldsTransformer(utils.NewLogrusLogger(logrus.StandardLogger()), []byte(tc.data))
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			if len(records) != 1 || len(records[0].Fields) != len(tc.expectedFields) {
+				t.Fatalf("expected a record with %v, but got %v", tc.expectedFields, records)
+			}
+			for name, expected := range tc.expectedFields {
+				if records[0].String(name) != expected {
+					t.Errorf("expected %v, but got %v", expected, records[0].String(name))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AES-256 key size in bytes
+const KeySize = 32
+
+// load base64 encoded AES-256 key from key file
+func LoadKey(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read key file")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, errors.Wrap(err, "key file is not base64 encoded")
+	}
+	if len(key) != KeySize {
+		return nil, errors.Errorf("key must be %d bytes but got %d bytes", KeySize, len(key))
+	}
+
+	return key, nil
+}
+
+// generate random AES-256 key and write it base64 encoded to key file
+func GenerateKey(path string) error {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return errors.Wrap(err, "unable to generate key")
+	}
+
+	// fail if key file already exist to avoid overwriting key of existing encrypted values
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "unable to create key file")
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(base64.StdEncoding.EncodeToString(key) + "\n")
+	return errors.Wrap(err, "unable to write key file")
+}
+
+// encrypt plaintext with AES-256-GCM, random nonce is prepended to ciphertext
+func Encrypt(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to generate nonce")
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt AES-256-GCM ciphertext with prepended nonce
+func Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt")
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key")
+	}
+
+	return cipher.NewGCM(block)
+}
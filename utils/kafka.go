@@ -0,0 +1,14 @@
+package utils
+
+import "strings"
+
+// comma separated kafka brokers (e.g. localhost:9092, kafka-2:9092) with spaces trimmed
+func SplitBrokers(brokers string) []string {
+	list := make([]string, 0)
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			list = append(list, broker)
+		}
+	}
+	return list
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrus implementation of Logger interface
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// wrap logrus logger to Logger interface
+func NewLogrusLogger(logger *logrus.Logger) Logger {
+	return &logrusLogger{
+		entry: logrus.NewEntry(logger),
+	}
+}
+
+func (l *logrusLogger) Panicf(format string, v ...interface{}) {
+	l.entry.Panicf(format, v...)
+}
+
+func (l *logrusLogger) Errorf(format string, v ...interface{}) {
+	l.entry.Errorf(format, v...)
+}
+
+func (l *logrusLogger) Warningf(format string, v ...interface{}) {
+	l.entry.Warningf(format, v...)
+}
+
+func (l *logrusLogger) Infof(format string, v ...interface{}) {
+	l.entry.Infof(format, v...)
+}
+
+func (l *logrusLogger) Debugf(format string, v ...interface{}) {
+	l.entry.Debugf(format, v...)
+}
+
+func (l *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{
+		entry: l.entry.WithFields(logrus.Fields(fields)),
+	}
+}
+
+// set logrus output formatter based on format name (possible value: text, json)
+func SetLogFormat(logger *logrus.Logger, format string) error {
+	switch format {
+	case "text":
+		logger.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %s", format)
+	}
+
+	return nil
+}
+
+// generate random (version 4) UUID as correlation id of a record
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	// crypto/rand Read never return error on supported platform
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
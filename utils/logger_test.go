@@ -0,0 +1,107 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithFields(t *testing.T) {
+	dep := newLoggerDependencies()
+
+	dep.logger.WithFields(utils.Fields{
+		utils.FieldDatasource: "random-data-api",
+		utils.FieldRecordID:   "record",
+	}).WithFields(utils.Fields{
+		utils.FieldBatchID: "batch",
+	}).Infof("inserted %d users", 5)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(dep.output.Bytes(), &line); err != nil {
+		t.Fatalf("expected JSON log line, but got %v", err)
+	}
+
+	expected := map[string]string{
+		"msg":                 "inserted 5 users",
+		utils.FieldDatasource: "random-data-api",
+		utils.FieldRecordID:   "record",
+		utils.FieldBatchID:    "batch",
+	}
+	for key, value := range expected {
+		if line[key] != value {
+			t.Errorf("expected %v to be %v, but got %v", key, value, line[key])
+		}
+	}
+}
+
+func TestSetLogFormat(t *testing.T) {
+	type testcase struct {
+		testcase      string
+		format        string
+		expectedError bool
+	}
+
+	testcases := []testcase{
+		{
+			testcase:      "Text",
+			format:        "text",
+			expectedError: false,
+		},
+		{
+			testcase:      "JSON",
+			format:        "json",
+			expectedError: false,
+		},
+		{
+			testcase:      "Unknown",
+			format:        "xml",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			err := utils.SetLogFormat(logrus.New(), tc.format)
+			if tc.expectedError && err == nil {
+				t.Errorf("expected error but got nil")
+			}
+			if !tc.expectedError && err != nil {
+				t.Errorf("not expected error, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewCorrelationID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	first := utils.NewCorrelationID()
+	second := utils.NewCorrelationID()
+	if !uuidPattern.MatchString(first) {
+		t.Errorf("expected UUID v4, but got %v", first)
+	}
+	if first == second {
+		t.Errorf("expected unique correlation id, but got %v twice", first)
+	}
+}
+
+type loggerDependencies struct {
+	output *bytes.Buffer
+	logger utils.Logger
+}
+
+func newLoggerDependencies() loggerDependencies {
+	output := &bytes.Buffer{}
+	logrusLogger := logrus.New()
+	logrusLogger.SetOutput(output)
+	utils.SetLogFormat(logrusLogger, "json")
+
+	return loggerDependencies{
+		output: output,
+		logger: utils.NewLogrusLogger(logrusLogger),
+	}
+}
@@ -1,5 +1,18 @@
 package utils
 
+// structured fields attached to log lines
+type Fields map[string]interface{}
+
+// common structured field keys
+const (
+	// name of the datasource which produce the record
+	FieldDatasource = "datasource"
+	// correlation id of a record (generated at extraction time)
+	FieldRecordID = "record_id"
+	// id of a bulk insert batch
+	FieldBatchID = "batch_id"
+)
+
 // common logger interface
 type Logger interface {
 	Panicf(format string, v ...interface{})
@@ -7,4 +20,6 @@ type Logger interface {
 	Warningf(format string, v ...interface{})
 	Infof(format string, v ...interface{})
 	Debugf(format string, v ...interface{})
+	// return a child logger which attach fields to every log line
+	WithFields(fields Fields) Logger
 }
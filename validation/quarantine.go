@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/pkg/errors"
+)
+
+// append quarantined records to file in JSON lines format
+// records can be loaded by replay command after fixing
+type FileQuarantine struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// file is created on first quarantined record
+func NewFileQuarantine(path string) *FileQuarantine {
+	return &FileQuarantine{
+		path: path,
+	}
+}
+
+func (q *FileQuarantine) Write(record transformation.Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal record")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		file, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return errors.Wrap(err, "unable to open quarantine file")
+		}
+		q.file = file
+	}
+
+	_, err = q.file.Write(append(line, '\n'))
+	return errors.Wrap(err, "unable to write quarantine file")
+}
+
+func (q *FileQuarantine) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	return q.file.Close()
+}
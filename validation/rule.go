@@ -0,0 +1,225 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+)
+
+// action when record violate rule
+const (
+	// drop record
+	ActionReject = "reject"
+	// log violation and load record
+	ActionWarn = "warn"
+	// write record to quarantine file instead of loading
+	ActionQuarantine = "quarantine"
+)
+
+// date bound value meaning the time of validation
+const boundNow = "now"
+
+// compiled validation rule of a single field
+type Rule struct {
+	datasource string
+	field      transformation.Field
+	action     string
+	required   bool
+	min        *float64
+	max        *float64
+	pattern    *regexp.Regexp
+	allowed    map[string]bool
+	after      *bound
+	before     *bound
+}
+
+// fixed date or time of validation
+type bound struct {
+	now  bool
+	time time.Time
+}
+
+func (b *bound) value(now time.Time) time.Time {
+	if b.now {
+		return now
+	}
+	return b.time
+}
+
+// compile rules from config and collect every problem (unknown field, invalid regex etc) into a single error
+// field is resolved in every entity, so a rule applies to records of any entity having the field
+func CompileRules(rules []config.ValidationRuleConfig, schemas transformation.Schemas) ([]Rule, error) {
+	compiled := make([]Rule, 0, len(rules))
+	problems := make([]string, 0)
+	for i, ruleConfig := range rules {
+		rule, ruleProblems := compileRule(ruleConfig, schemas)
+		for _, problem := range ruleProblems {
+			problems = append(problems, fmt.Sprintf("Validation.Rules[%d] (%s) %s", i, ruleConfig.Field, problem))
+		}
+		compiled = append(compiled, rule)
+	}
+
+	if len(problems) != 0 {
+		return nil, &config.ValidationError{Problems: problems}
+	}
+	return compiled, nil
+}
+
+func compileRule(c config.ValidationRuleConfig, schemas transformation.Schemas) (Rule, []string) {
+	problems := make([]string, 0)
+	rule := Rule{
+		datasource: c.Datasource,
+		action:     c.Action,
+		required:   c.Required,
+		min:        c.Min,
+		max:        c.Max,
+	}
+	if rule.action == "" {
+		rule.action = ActionReject
+	}
+
+	field, err := schemas.Field(c.Field)
+	if err != nil {
+		return rule, append(problems, err.Error())
+	}
+	rule.field = field
+
+	numeric := false
+	switch field.Type {
+	case transformation.FieldString, transformation.FieldDate:
+	case transformation.FieldFloat, transformation.FieldInt:
+		numeric = true
+	default:
+		return rule, append(problems, "field is not a string, number or date")
+	}
+
+	if (c.Min != nil || c.Max != nil) && !numeric {
+		problems = append(problems, "Min and Max are only supported by number field")
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		problems = append(problems, "Min is greater than Max")
+	}
+
+	if c.Pattern != "" || len(c.Allowed) != 0 {
+		if field.Type != transformation.FieldString {
+			problems = append(problems, "Pattern and Allowed are only supported by string field")
+		}
+	}
+	if c.Pattern != "" {
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Pattern is invalid: %v", err))
+		}
+		rule.pattern = pattern
+	}
+	if len(c.Allowed) != 0 {
+		rule.allowed = make(map[string]bool, len(c.Allowed))
+		for _, value := range c.Allowed {
+			rule.allowed[value] = true
+		}
+	}
+
+	if (c.After != "" || c.Before != "") && field.Type != transformation.FieldDate {
+		problems = append(problems, "After and Before are only supported by date field")
+	}
+	if rule.after, err = parseBound(c.After); err != nil {
+		problems = append(problems, fmt.Sprintf("After is invalid: %v", err))
+	}
+	if rule.before, err = parseBound(c.Before); err != nil {
+		problems = append(problems, fmt.Sprintf("Before is invalid: %v", err))
+	}
+
+	return rule, problems
+}
+
+// parse "now", date (2006-01-02) or RFC3339 time
+func parseBound(value string) (*bound, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case boundNow:
+		return &bound{now: true}, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return &bound{time: t}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not now, date or RFC3339 time", value)
+	}
+	return &bound{time: t}, nil
+}
+
+// rule applies to every datasource if datasource is not set
+func (r *Rule) appliesTo(datasource string) bool {
+	return r.datasource == "" || r.datasource == datasource
+}
+
+// return checks violated by record (required, min, max, pattern, allowed, after, before)
+// optional checks are skipped if field is empty or null, record of entity without the field is skipped
+func (r *Rule) check(record transformation.Record, now time.Time) []string {
+	violations := make([]string, 0)
+	if !record.Has(r.field.Name) {
+		return violations
+	}
+	if record.Get(r.field.Name) == nil {
+		if r.required {
+			violations = append(violations, "required")
+		}
+		return violations
+	}
+
+	switch r.field.Type {
+	case transformation.FieldString:
+		s := record.String(r.field.Name)
+		if strings.TrimSpace(s) == "" {
+			if r.required {
+				violations = append(violations, "required")
+			}
+			return violations
+		}
+		if r.pattern != nil && !r.pattern.MatchString(s) {
+			violations = append(violations, "pattern")
+		}
+		if r.allowed != nil && !r.allowed[s] {
+			violations = append(violations, "allowed")
+		}
+	case transformation.FieldFloat, transformation.FieldInt:
+		var n float64
+		if r.field.Type == transformation.FieldFloat {
+			n = record.Float(r.field.Name)
+		} else {
+			n = float64(record.Int(r.field.Name))
+		}
+		if r.required && n == 0 {
+			violations = append(violations, "required")
+		}
+		if r.min != nil && n < *r.min {
+			violations = append(violations, "min")
+		}
+		if r.max != nil && n > *r.max {
+			violations = append(violations, "max")
+		}
+	case transformation.FieldDate:
+		t := record.Time(r.field.Name)
+		if t.IsZero() {
+			if r.required {
+				violations = append(violations, "required")
+			}
+			return violations
+		}
+		if r.after != nil && !t.After(r.after.value(now)) {
+			violations = append(violations, "after")
+		}
+		if r.before != nil && !t.Before(r.before.value(now)) {
+			violations = append(violations, "before")
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,172 @@
+package validation
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+)
+
+// severity of action, the most severe action of all violated rules is applied to record
+var actionSeverity = map[string]int{
+	ActionWarn:       1,
+	ActionQuarantine: 2,
+	ActionReject:     3,
+}
+
+// quarantine storage of records violating quarantine rule
+type quarantine interface {
+	Write(record transformation.Record) error
+}
+
+// validation result of a datasource in current run
+type Summary struct {
+	Datasource string `json:"datasource"`
+	// records without violation
+	Passed int64 `json:"passed"`
+	// records loaded with violation of warn rule
+	Warned int64 `json:"warned"`
+	// records dropped
+	Rejected int64 `json:"rejected"`
+	// records written to quarantine instead of loading
+	Quarantined int64 `json:"quarantined"`
+	// number of violation keyed by "<field> <check>"
+	Violations map[string]int64 `json:"violations"`
+}
+
+// validation stage between transformation and loading
+type Validator struct {
+	rules      []Rule
+	quarantine quarantine
+	logger     utils.Logger
+
+	mu        sync.Mutex
+	summaries map[string]*Summary
+}
+
+func NewValidator(rules []Rule, quarantine quarantine, logger utils.Logger) *Validator {
+	return &Validator{
+		rules:      rules,
+		quarantine: quarantine,
+		logger:     logger,
+		summaries:  make(map[string]*Summary),
+	}
+}
+
+// validate records from input channel and pass records which should be loaded to output channel
+// output channel is closed after input channel is closed
+func (v *Validator) Run(ctx context.Context, in <-chan transformation.Record, out chan<- transformation.Record) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case record, ok := <-in:
+			if !ok {
+				close(out)
+				return
+			}
+			if !v.Validate(record) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- record:
+			}
+		}
+	}
+}
+
+// apply rules of record datasource and return if record should be loaded
+// delete has key fields only, so rules are not applied and it always passes
+func (v *Validator) Validate(record transformation.Record) bool {
+	logger := v.logger.WithFields(utils.Fields{
+		utils.FieldDatasource: record.Metadata.Datasource,
+		utils.FieldRecordID:   record.Metadata.CorrelationID,
+	})
+
+	now := time.Now()
+	action := ""
+	violations := make([]string, 0)
+	for i := range v.rules {
+		rule := &v.rules[i]
+		if record.Operation == transformation.OperationDelete || !rule.appliesTo(record.Metadata.Datasource) {
+			continue
+		}
+		for _, check := range rule.check(record, now) {
+			violation := rule.field.Name + " " + check
+			violations = append(violations, violation)
+			// field value is not logged as it may contain personal data
+			logger.Warningf("record violate %s rule (%s)", violation, rule.action)
+			if actionSeverity[rule.action] > actionSeverity[action] {
+				action = rule.action
+			}
+		}
+	}
+
+	if action == ActionQuarantine {
+		if err := v.quarantine.Write(record); err != nil {
+			logger.Errorf("unable to quarantine record, record is rejected %v", err)
+			action = ActionReject
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	summary, ok := v.summaries[record.Metadata.Datasource]
+	if !ok {
+		summary = &Summary{
+			Datasource: record.Metadata.Datasource,
+			Violations: make(map[string]int64),
+		}
+		v.summaries[record.Metadata.Datasource] = summary
+	}
+	for _, violation := range violations {
+		summary.Violations[violation]++
+	}
+
+	switch action {
+	case "":
+		summary.Passed++
+	case ActionWarn:
+		summary.Warned++
+	case ActionQuarantine:
+		summary.Quarantined++
+		return false
+	case ActionReject:
+		summary.Rejected++
+		return false
+	}
+	return true
+}
+
+// validation summary of each datasource sorted by datasource name
+func (v *Validator) Summaries() []Summary {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	summaries := make([]Summary, 0, len(v.summaries))
+	for _, summary := range v.summaries {
+		s := *summary
+		s.Violations = make(map[string]int64, len(summary.Violations))
+		for violation, count := range summary.Violations {
+			s.Violations[violation] = count
+		}
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Datasource < summaries[j].Datasource })
+
+	return summaries
+}
+
+// log validation summary of each datasource
+func (v *Validator) LogSummary() {
+	for _, summary := range v.Summaries() {
+		v.logger.WithFields(utils.Fields{utils.FieldDatasource: summary.Datasource}).Infof(
+			"validation summary: %d passed, %d warned, %d rejected, %d quarantined, violations %v",
+			summary.Passed, summary.Warned, summary.Rejected, summary.Quarantined, summary.Violations)
+	}
+}
@@ -0,0 +1,342 @@
+package validation_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/validation"
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidate(t *testing.T) {
+	type testcase struct {
+		testcase            string
+		rules               []config.ValidationRuleConfig
+		record              transformation.Record
+		quarantineErr       error
+		expectedLoad        bool
+		expectedQuarantined int
+		expectedSummary     validation.Summary
+	}
+
+	testcases := []testcase{
+		{
+			testcase:        "Valid record",
+			rules:           defaultRules(),
+			record:          validRecord(),
+			expectedLoad:    true,
+			expectedSummary: validation.Summary{Datasource: "a", Passed: 1, Violations: map[string]int64{}},
+		},
+		{
+			testcase: "Reject empty name",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("FirstName", " ")
+				return r
+			}(),
+			expectedLoad:    false,
+			expectedSummary: validation.Summary{Datasource: "a", Rejected: 1, Violations: map[string]int64{"FirstName required": 1}},
+		},
+		{
+			testcase: "Reject null name",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("FirstName", nil)
+				return r
+			}(),
+			expectedLoad:    false,
+			expectedSummary: validation.Summary{Datasource: "a", Rejected: 1, Violations: map[string]int64{"FirstName required": 1}},
+		},
+		{
+			testcase: "Null latitude skip range check",
+			rules:    []config.ValidationRuleConfig{{Field: "Address.Latitude", Min: float(10), Action: validation.ActionReject}},
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("Address.Latitude", nil)
+				return r
+			}(),
+			expectedLoad:    true,
+			expectedSummary: validation.Summary{Datasource: "a", Passed: 1, Violations: map[string]int64{}},
+		},
+		{
+			testcase: "Quarantine latitude out of range",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("Address.Latitude", 91.0)
+				return r
+			}(),
+			expectedLoad:        false,
+			expectedQuarantined: 1,
+			expectedSummary:     validation.Summary{Datasource: "a", Quarantined: 1, Violations: map[string]int64{"Address.Latitude max": 1}},
+		},
+		{
+			testcase: "Reject if quarantine failed",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("Address.Latitude", -91.0)
+				return r
+			}(),
+			quarantineErr:   errors.New("disk full"),
+			expectedLoad:    false,
+			expectedSummary: validation.Summary{Datasource: "a", Rejected: 1, Violations: map[string]int64{"Address.Latitude min": 1}},
+		},
+		{
+			testcase: "Warn future birth date",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("DateOfBirth", time.Now().AddDate(1, 0, 0))
+				return r
+			}(),
+			expectedLoad:    true,
+			expectedSummary: validation.Summary{Datasource: "a", Warned: 1, Violations: map[string]int64{"DateOfBirth before": 1}},
+		},
+		{
+			testcase: "Most severe action applied",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("FirstName", "")
+				r.Set("Address.Country", "Atlantis")
+				r.Set("DateOfBirth", time.Now().AddDate(1, 0, 0))
+				return r
+			}(),
+			expectedLoad:    false,
+			expectedSummary: validation.Summary{Datasource: "a", Rejected: 1, Violations: map[string]int64{"FirstName required": 1, "Address.Country allowed": 1, "DateOfBirth before": 1}},
+		},
+		{
+			testcase:        "Rule of other datasource ignored",
+			rules:           []config.ValidationRuleConfig{{Datasource: "b", Field: "FirstName", Pattern: "^[0-9]+$", Action: validation.ActionReject}},
+			record:          validRecord(),
+			expectedLoad:    true,
+			expectedSummary: validation.Summary{Datasource: "a", Passed: 1, Violations: map[string]int64{}},
+		},
+		{
+			testcase: "Delete with key field only",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := validRecord()
+				r.Set("FirstName", "")
+				r.Operation = transformation.OperationDelete
+				return r
+			}(),
+			expectedLoad:    true,
+			expectedSummary: validation.Summary{Datasource: "a", Passed: 1, Violations: map[string]int64{}},
+		},
+		{
+			testcase: "Record of entity without field skipped",
+			rules:    defaultRules(),
+			record: func() transformation.Record {
+				r := transformation.Record{Entity: "orders", Fields: map[string]interface{}{"OrderId": 1}}
+				schemas.Conform(&r)
+				r.Metadata = transformation.RecordMetadata{Datasource: "a"}
+				return r
+			}(),
+			expectedLoad:    true,
+			expectedSummary: validation.Summary{Datasource: "a", Passed: 1, Violations: map[string]int64{}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			dep := newValidationDependencies()
+			dep.quarantine.err = tc.quarantineErr
+			rules, err := validation.CompileRules(tc.rules, schemas)
+			if err != nil {
+				t.Fatalf("not expected error, but got %v", err)
+			}
+			v := validation.NewValidator(rules, dep.quarantine, dep.logger)
+
+			load := v.Validate(tc.record)
+			if load != tc.expectedLoad {
+				t.Errorf("expected load %v, but got %v", tc.expectedLoad, load)
+			}
+			if len(dep.quarantine.records) != tc.expectedQuarantined {
+				t.Errorf("expected %v quarantined records, but got %v", tc.expectedQuarantined, len(dep.quarantine.records))
+			}
+
+			summaries := v.Summaries()
+			if len(summaries) != 1 {
+				t.Fatalf("expected 1 summary, but got %+v", summaries)
+			}
+			s := summaries[0]
+			expected := tc.expectedSummary
+			if s.Datasource != expected.Datasource || s.Passed != expected.Passed || s.Warned != expected.Warned || s.Rejected != expected.Rejected || s.Quarantined != expected.Quarantined {
+				t.Errorf("expected %+v, but got %+v", expected, s)
+			}
+			if len(s.Violations) != len(expected.Violations) {
+				t.Errorf("expected violations %v, but got %v", expected.Violations, s.Violations)
+			}
+			for violation, count := range expected.Violations {
+				if s.Violations[violation] != count {
+					t.Errorf("expected violations %v, but got %v", expected.Violations, s.Violations)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	type testcase struct {
+		testcase         string
+		rule             config.ValidationRuleConfig
+		expectedProblems []string
+	}
+
+	testcases := []testcase{
+		{
+			testcase: "Nested field case insensitive",
+			rule:     config.ValidationRuleConfig{Field: "address.latitude", Min: float(-90), Max: float(90)},
+		},
+		{
+			testcase:         "Unknown field",
+			rule:             config.ValidationRuleConfig{Field: "Address.Planet", Required: true},
+			expectedProblems: []string{"field Address.Planet not found"},
+		},
+		{
+			testcase:         "Bool field",
+			rule:             config.ValidationRuleConfig{Field: "Paid", Required: true},
+			expectedProblems: []string{"field is not a string, number or date"},
+		},
+		{
+			testcase:         "Invalid regex",
+			rule:             config.ValidationRuleConfig{Field: "FirstName", Pattern: "("},
+			expectedProblems: []string{"Pattern is invalid"},
+		},
+		{
+			testcase:         "Check not supported by field",
+			rule:             config.ValidationRuleConfig{Field: "FirstName", Min: float(1), Before: "now"},
+			expectedProblems: []string{"Min and Max", "After and Before"},
+		},
+		{
+			testcase:         "Invalid date bound",
+			rule:             config.ValidationRuleConfig{Field: "DateOfBirth", After: "yesterday"},
+			expectedProblems: []string{"After is invalid"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testcase, func(t *testing.T) {
+			_, err := validation.CompileRules([]config.ValidationRuleConfig{tc.rule}, schemas)
+			if len(tc.expectedProblems) == 0 {
+				if err != nil {
+					t.Errorf("not expected error, but got %v", err)
+				}
+				return
+			}
+
+			var validationErr *config.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected validation error, but got %v", err)
+			}
+			for _, expected := range tc.expectedProblems {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected problem %v, but got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatorRun(t *testing.T) {
+	dep := newValidationDependencies()
+	rules, _ := validation.CompileRules(defaultRules(), schemas)
+	v := validation.NewValidator(rules, dep.quarantine, dep.logger)
+
+	invalid := validRecord()
+	invalid.Set("FirstName", "")
+	in := make(chan transformation.Record, 3)
+	out := make(chan transformation.Record, 3)
+	in <- validRecord()
+	in <- invalid
+	in <- validRecord()
+	close(in)
+
+	v.Run(context.Background(), in, out)
+
+	loaded := 0
+	for range out {
+		loaded++
+	}
+	if loaded != 2 {
+		t.Errorf("expected 2 records, but got %v", loaded)
+	}
+}
+
+func defaultRules() []config.ValidationRuleConfig {
+	return []config.ValidationRuleConfig{
+		{Field: "FirstName", Required: true, Action: validation.ActionReject},
+		{Datasource: "a", Field: "Address.Latitude", Min: float(-90), Max: float(90), Action: validation.ActionQuarantine},
+		{Field: "Address.Country", Allowed: []string{"United States", "Canada"}, Action: validation.ActionReject},
+		{Field: "DateOfBirth", After: "1900-01-01", Before: "now", Action: validation.ActionWarn},
+	}
+}
+
+// users and orders entities
+var schemas = func() transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{
+		config.UserEntity(),
+		{Name: "orders", Fields: []config.EntityFieldConfig{{Name: "OrderId", Type: "int"}, {Name: "Paid", Type: "bool"}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+func validRecord() transformation.Record {
+	record := transformation.Record{
+		Metadata: transformation.RecordMetadata{Datasource: "a", CorrelationID: "record"},
+		Entity:   config.DefaultEntity,
+		Fields: map[string]interface{}{
+			"FirstName":        "John",
+			"LastName":         "Doe",
+			"DateOfBirth":      time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+			"Address.Country":  "Canada",
+			"Address.Latitude": 45.0,
+		},
+	}
+	if err := schemas.Conform(&record); err != nil {
+		panic(err)
+	}
+	return record
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+type quarantineMock struct {
+	err     error
+	records []transformation.Record
+}
+
+func (q *quarantineMock) Write(record transformation.Record) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.records = append(q.records, record)
+	return nil
+}
+
+type validationDependencies struct {
+	quarantine *quarantineMock
+	logger     utils.Logger
+}
+
+func newValidationDependencies() validationDependencies {
+	return validationDependencies{
+		quarantine: &quarantineMock{},
+		logger:     utils.NewLogrusLogger(logrus.StandardLogger()),
+	}
+}
@@ -0,0 +1,315 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/transformation/steps"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/pkg/errors"
+)
+
+// worker status
+const (
+	// extraction is running
+	StatusRunning = "running"
+	// extraction is stopped by operator and can be resumed
+	StatusPaused = "paused"
+	// extraction stopped after source is exhausted
+	StatusCompleted = "completed"
+	// extraction stopped by error
+	StatusFailed = "failed"
+)
+
+var (
+	ErrNotFound       = errors.New("datasource not found")
+	ErrAlreadyExists  = errors.New("datasource already exists")
+	ErrInvalidState   = errors.New("datasource is not in valid state for this operation")
+	ErrNotImplemented = errors.New("datasource type, transformer or entity not yet implemented")
+)
+
+// runtime status and counters of a datasource worker
+type Status struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Transformer string    `json:"transformer"`
+	Entity      string    `json:"entity"`
+	Source      string    `json:"source"`
+	Status      string    `json:"status"`
+	StartedAt   time.Time `json:"startedAt"`
+	// number of records transformed successfully
+	Extracted int64 `json:"extracted"`
+	// number of records failed to transform
+	Failed int64 `json:"failed"`
+	// number of records dropped by transformation step
+	Dropped   int64  `json:"dropped"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// running extraction of a datasource
+type worker struct {
+	datasource config.DataSourceConfig
+	status     string
+	startedAt  time.Time
+	lastError  string
+	extracted  atomic.Int64
+	failed     atomic.Int64
+	dropped    atomic.Int64
+	// cancel current run
+	cancel context.CancelFunc
+	// closed when current run stopped
+	done chan struct{}
+}
+
+// manager for starting, pausing, resuming and removing datasource workers at runtime
+type Manager struct {
+	ctx          context.Context
+	logger       utils.Logger
+	extractors   map[string]extraction.DataSourceExtration
+	transformers map[string]transformation.TransformFunc
+	pipeline     *steps.Pipeline
+	schemas      transformation.Schemas
+	dataPipeline chan<- transformation.Record
+
+	mu      sync.Mutex
+	workers map[string]*worker
+	wg      sync.WaitGroup
+}
+
+// create manager with extractors keyed by datasource type and transformers keyed by transformer name
+// transformer of each datasource is chained with its steps in pipeline (no step if pipeline is nil)
+// transformed records are conformed to schema of datasource entity before steps are applied
+// all workers are stopped when context is cancelled
+func NewManager(ctx context.Context, logger utils.Logger, extractors map[string]extraction.DataSourceExtration, transformers map[string]transformation.TransformFunc, pipeline *steps.Pipeline, schemas transformation.Schemas, dataPipeline chan<- transformation.Record) *Manager {
+	return &Manager{
+		ctx:          ctx,
+		logger:       logger,
+		extractors:   extractors,
+		transformers: transformers,
+		pipeline:     pipeline,
+		schemas:      schemas,
+		dataPipeline: dataPipeline,
+		workers:      make(map[string]*worker),
+	}
+}
+
+// add datasource and start extraction
+// records of datasource without entity are of default entity
+func (m *Manager) Add(datasource config.DataSourceConfig) error {
+	if datasource.Entity == "" {
+		datasource.Entity = config.DefaultEntity
+	}
+	if _, ok := m.schemas[datasource.Entity]; !ok {
+		return errors.Wrapf(ErrNotImplemented, "entity %s", datasource.Entity)
+	}
+	if _, ok := m.extractors[datasource.Type]; !ok {
+		return errors.Wrapf(ErrNotImplemented, "type %s", datasource.Type)
+	}
+	if _, ok := m.transformers[datasource.Transformer]; !ok {
+		return errors.Wrapf(ErrNotImplemented, "transformer %s", datasource.Transformer)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workers[datasource.Name]; ok {
+		return errors.Wrapf(ErrAlreadyExists, "datasource %s", datasource.Name)
+	}
+
+	w := &worker{
+		datasource: datasource,
+	}
+	m.workers[datasource.Name] = w
+	m.start(w, datasource)
+
+	return nil
+}
+
+// stop extraction and remove datasource
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	w, ok := m.workers[name]
+	if !ok {
+		m.mu.Unlock()
+		return errors.Wrapf(ErrNotFound, "datasource %s", name)
+	}
+	delete(m.workers, name)
+	m.mu.Unlock()
+
+	m.stop(w, StatusPaused)
+	m.logger.WithFields(utils.Fields{utils.FieldDatasource: name}).Infof("datasource removed")
+	return nil
+}
+
+// stop running extraction, which can be resumed later
+func (m *Manager) Pause(name string) error {
+	m.mu.Lock()
+	w, ok := m.workers[name]
+	if !ok {
+		m.mu.Unlock()
+		return errors.Wrapf(ErrNotFound, "datasource %s", name)
+	}
+	if w.status != StatusRunning {
+		m.mu.Unlock()
+		return errors.Wrapf(ErrInvalidState, "datasource %s is %s", name, w.status)
+	}
+	m.mu.Unlock()
+
+	m.stop(w, StatusPaused)
+	m.logger.WithFields(utils.Fields{utils.FieldDatasource: name}).Infof("datasource paused")
+	return nil
+}
+
+// restart extraction of stopped datasource (continue from last checkpoint)
+func (m *Manager) Resume(name string) error {
+	return m.restart(name, false)
+}
+
+// run extraction of stopped datasource once until source is exhausted
+func (m *Manager) Trigger(name string) error {
+	return m.restart(name, true)
+}
+
+// list status of all datasources sorted by name
+// password in source (e.g. connection string of sql datasource) is masked
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]Status, 0, len(m.workers))
+	for _, w := range m.workers {
+		statuses = append(statuses, Status{
+			Name:        w.datasource.Name,
+			Type:        w.datasource.Type,
+			Transformer: w.datasource.Transformer,
+			Entity:      w.datasource.Entity,
+			Source:      config.RedactSecrets(utils.RedactConnectionString(w.datasource.Source)),
+			Status:      w.status,
+			StartedAt:   w.startedAt,
+			Extracted:   w.extracted.Load(),
+			Failed:      w.failed.Load(),
+			Dropped:     w.dropped.Load(),
+			LastError:   w.lastError,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	return statuses
+}
+
+// wait until all running extraction stopped
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *Manager) restart(name string, once bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.workers[name]
+	if !ok {
+		return errors.Wrapf(ErrNotFound, "datasource %s", name)
+	}
+	if w.status == StatusRunning {
+		return errors.Wrapf(ErrInvalidState, "datasource %s is %s", name, w.status)
+	}
+
+	datasource := w.datasource
+	datasource.Once = datasource.Once || once
+	m.start(w, datasource)
+
+	return nil
+}
+
+// start extraction in dedicated go routine (caller must hold lock)
+func (m *Manager) start(w *worker, datasource config.DataSourceConfig) {
+	logger := m.logger.WithFields(utils.Fields{utils.FieldDatasource: datasource.Name})
+	logger.Debugf("datasource is starting")
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	done := make(chan struct{})
+	w.status = StatusRunning
+	w.startedAt = time.Now()
+	w.lastError = ""
+	w.cancel = cancel
+	w.done = done
+
+	extractor := m.extractors[datasource.Type]
+	transformer := m.transformers[datasource.Transformer]
+	datasourceSteps := m.pipeline.For(datasource.Name)
+	// conform records to entity schema, apply transformation steps and count transformed records of this datasource
+	countedTransformer := func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+		records, err := transformer(logger, data)
+		if err != nil {
+			w.failed.Add(1)
+			return nil, err
+		}
+		for i := range records {
+			if records[i].Entity == "" {
+				records[i].Entity = datasource.Entity
+			}
+			if err := m.schemas.Conform(&records[i]); err != nil {
+				w.failed.Add(1)
+				return nil, err
+			}
+		}
+		records, dropped, err := transformation.ApplySteps(records, datasourceSteps)
+		w.dropped.Add(int64(dropped))
+		if dropped > 0 {
+			logger.Debugf("%d records dropped by transformation steps", dropped)
+		}
+		if err != nil {
+			w.failed.Add(1)
+			return nil, err
+		}
+		w.extracted.Add(int64(len(records)))
+		return records, nil
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(done)
+		defer cancel()
+
+		// extractor mark its own wait group done, manager wait group is done after status is updated
+		var extractWg sync.WaitGroup
+		extractWg.Add(1)
+		err := extractor.Extract(ctx, datasource, countedTransformer, m.dataPipeline, &extractWg)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// status already changed if stopped by operator
+		if w.status != StatusRunning {
+			return
+		}
+		if err != nil {
+			w.status = StatusFailed
+			w.lastError = err.Error()
+			logger.Errorf("datasource stopped %v", err)
+			return
+		}
+		w.status = StatusCompleted
+		logger.Infof("datasource completed")
+	}()
+}
+
+// cancel current run and wait until it stopped
+func (m *Manager) stop(w *worker, status string) {
+	m.mu.Lock()
+	if w.status == StatusRunning {
+		w.status = status
+	}
+	cancel := w.cancel
+	done := w.done
+	m.mu.Unlock()
+
+	cancel()
+	<-done
+}
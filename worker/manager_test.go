@@ -0,0 +1,183 @@
+package worker_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awcjack/ETL-sample/config"
+	"github.com/awcjack/ETL-sample/extraction"
+	"github.com/awcjack/ETL-sample/transformation"
+	"github.com/awcjack/ETL-sample/utils"
+	"github.com/awcjack/ETL-sample/worker"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+func TestManagerLifecycle(t *testing.T) {
+	dep := newManagerDependencies()
+
+	if err := dep.manager.Add(dep.datasource); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	<-dep.dataChan
+	expectStatus(t, dep.manager, worker.StatusRunning)
+
+	if err := dep.manager.Add(dep.datasource); !errors.Is(err, worker.ErrAlreadyExists) {
+		t.Errorf("expected already exists error, but got %v", err)
+	}
+	if err := dep.manager.Trigger(dep.datasource.Name); !errors.Is(err, worker.ErrInvalidState) {
+		t.Errorf("expected invalid state error, but got %v", err)
+	}
+
+	if err := dep.manager.Pause(dep.datasource.Name); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	expectStatus(t, dep.manager, worker.StatusPaused)
+
+	if err := dep.manager.Resume(dep.datasource.Name); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	<-dep.dataChan
+	expectStatus(t, dep.manager, worker.StatusRunning)
+
+	if err := dep.manager.Remove(dep.datasource.Name); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	if statuses := dep.manager.List(); len(statuses) != 0 {
+		t.Errorf("expected no datasource, but got %+v", statuses)
+	}
+	if err := dep.manager.Pause(dep.datasource.Name); !errors.Is(err, worker.ErrNotFound) {
+		t.Errorf("expected not found error, but got %v", err)
+	}
+	dep.manager.Wait()
+}
+
+func TestManagerTrigger(t *testing.T) {
+	dep := newManagerDependencies()
+
+	dep.manager.Add(dep.datasource)
+	<-dep.dataChan
+	dep.manager.Pause(dep.datasource.Name)
+
+	if err := dep.manager.Trigger(dep.datasource.Name); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	<-dep.dataChan
+	dep.manager.Wait()
+	expectStatus(t, dep.manager, worker.StatusCompleted)
+
+	status := dep.manager.List()[0]
+	if status.Extracted != 2 {
+		t.Errorf("expected 2 extracted records, but got %v", status.Extracted)
+	}
+}
+
+func TestManagerNotImplemented(t *testing.T) {
+	dep := newManagerDependencies()
+
+	dep.datasource.Type = "ftp"
+	if err := dep.manager.Add(dep.datasource); !errors.Is(err, worker.ErrNotImplemented) {
+		t.Errorf("expected not implemented error, but got %v", err)
+	}
+
+	dep.datasource.Type = "mock"
+	dep.datasource.Entity = "orders"
+	if err := dep.manager.Add(dep.datasource); !errors.Is(err, worker.ErrNotImplemented) {
+		t.Errorf("expected not implemented error, but got %v", err)
+	}
+}
+
+func TestManagerConformRecord(t *testing.T) {
+	dep := newManagerDependencies()
+
+	dep.datasource.Once = true
+	if err := dep.manager.Add(dep.datasource); err != nil {
+		t.Fatalf("not expected error, but got %v", err)
+	}
+	record := <-dep.dataChan
+	dep.manager.Wait()
+
+	// record without entity is of datasource entity, missing fields are set to zero value
+	if record.Entity != config.DefaultEntity {
+		t.Errorf("expected %v, but got %v", config.DefaultEntity, record.Entity)
+	}
+	if record.String("FirstName") != "John" || !record.Has("LastName") || !record.Has("Address.Latitude") {
+		t.Errorf("expected record conformed to users schema, but got %+v", record)
+	}
+	if status := dep.manager.List()[0]; status.Entity != config.DefaultEntity {
+		t.Errorf("expected %v, but got %v", config.DefaultEntity, status.Entity)
+	}
+}
+
+func expectStatus(t *testing.T, manager *worker.Manager, expected string) {
+	t.Helper()
+
+	statuses := manager.List()
+	if len(statuses) != 1 || statuses[0].Status != expected {
+		t.Errorf("expected status %v, but got %+v", expected, statuses)
+	}
+}
+
+// extractor push a record every 10ms until context cancelled (or a single record if run once)
+type extractorMock struct{}
+
+func (e *extractorMock) Extract(ctx context.Context, datasource config.DataSourceConfig, transformer transformation.TransformFunc, dataPipeline chan<- transformation.Record, wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	for {
+		records, _ := transformer(utils.NewLogrusLogger(logrus.StandardLogger()), nil)
+		select {
+		case <-ctx.Done():
+			return nil
+		case dataPipeline <- records[0]:
+		}
+		if datasource.Once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+var schemas = func() transformation.Schemas {
+	schemas, err := transformation.NewSchemas([]config.EntityConfig{config.UserEntity()})
+	if err != nil {
+		panic(err)
+	}
+	return schemas
+}()
+
+type managerDependencies struct {
+	datasource config.DataSourceConfig
+	dataChan   chan transformation.Record
+	manager    *worker.Manager
+}
+
+func newManagerDependencies() managerDependencies {
+	dataChan := make(chan transformation.Record)
+	extractors := map[string]extraction.DataSourceExtration{
+		"mock": &extractorMock{},
+	}
+	transformers := map[string]transformation.TransformFunc{
+		"mock": func(logger utils.Logger, data []byte) ([]transformation.Record, error) {
+			return []transformation.Record{{Fields: map[string]interface{}{"FirstName": "John"}}}, nil
+		},
+	}
+
+	return managerDependencies{
+		datasource: config.DataSourceConfig{
+			Name:        "source",
+			Type:        "mock",
+			Transformer: "mock",
+			Source:      "mock://",
+		},
+		dataChan: dataChan,
+		manager:  worker.NewManager(context.Background(), utils.NewLogrusLogger(logrus.StandardLogger()), extractors, transformers, nil, schemas, dataChan),
+	}
+}